@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"notification-service/internal/config"
+	"notification-service/internal/encryption"
+	"notification-service/internal/repository"
+	"notification-service/internal/retention"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	log "github.com/sirupsen/logrus"
+)
+
+// migrationsURL is the source of the schema migrations
+const migrationsURL = "file://db/migrations"
+
+// checkSchema returns an error when migrations are pending or the last one
+// failed; the service applies them when it starts
+func checkSchema(m *migrate.Migrate, sourceURL string) error {
+	current, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty, fix the failed migration first", current)
+	}
+
+	src, err := source.Open(sourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to open migrations: %w", err)
+	}
+	defer src.Close()
+
+	latest, err := src.First()
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(latest)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read migrations: %w", err)
+		}
+		latest = next
+	}
+
+	if current < latest {
+		return fmt.Errorf("schema is at version %d but migrations up to %d are pending, start the service to apply them", current, latest)
+	}
+	return nil
+}
+
+// runCommand runs a one-off maintenance subcommand instead of the service
+func runCommand(cfg *config.Config, db *sql.DB, name string, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	switch name {
+	case "purge":
+		return runPurge(ctx, cfg, db, args)
+	case "rotate-keys":
+		return runRotateKeys(ctx, cfg, db, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runPurge applies the retention policy once: notification-service purge [-dry-run]
+func runPurge(ctx context.Context, cfg *config.Config, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report how many rows would be removed")
+	archiveDir := flags.String("archive-dir", cfg.Retention.ArchiveDir, "write purged rows as gzip NDJSON to this directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	retentionCfg := cfg.Retention
+	retentionCfg.ArchiveDir = *archiveDir
+
+	var bodies retention.BodyRepository
+	if cfg.Audit.StoreBodies {
+		bodies = repository.NewPostgresBodyRepository(db, cfg.Audit.BodyRetention)
+	}
+
+	worker, err := newRetentionWorker(retentionCfg, db, bodies)
+	if err != nil {
+		return err
+	}
+
+	report, err := worker.Purge(ctx, *dryRun)
+	report.Log()
+	return err
+}
+
+func newRetentionWorker(cfg config.Retention, db *sql.DB, bodies retention.BodyRepository) (*retention.Worker, error) {
+	policy, err := retention.ParsePolicy(cfg.TTLs)
+	if err != nil {
+		return nil, err
+	}
+	return retention.NewWorker(repository.NewPostgresRetentionRepository(db), bodies, policy, retention.Config{
+		Interval:   cfg.Interval,
+		BatchSize:  cfg.BatchSize,
+		BatchPause: cfg.BatchPause,
+		ArchiveDir: cfg.ArchiveDir,
+	}), nil
+}
+
+// runRotateKeys rewraps the stored keys under the current master key, starts
+// a new data key and re-encrypts every recipient with it. Recipients still
+// in plaintext are encrypted too. Instances started before the rotation keep
+// writing with the old key until restarted, so run it again afterwards:
+// notification-service rotate-keys [-new-data-key=false] [-batch-size N]
+func runRotateKeys(ctx context.Context, cfg *config.Config, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	newDataKey := flags.Bool("new-data-key", true, "create a new data key before re-encrypting")
+	batchSize := flags.Int("batch-size", 500, "rows re-encrypted per transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	keyring, err := loadKeyring(ctx, cfg.Encryption, db)
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errors.New("encryption is not configured, set PII_MASTER_KEY or PII_KEY_FILE")
+	}
+
+	rewrapped, err := keyring.RewrapKeys(ctx)
+	if err != nil {
+		return err
+	}
+	log.WithField("keys", rewrapped).Info("Rewrapped keys under the current master key")
+
+	if *newDataKey {
+		if err := keyring.RotateDataKey(ctx); err != nil {
+			return err
+		}
+		log.Info("Created a new data key")
+	}
+
+	repo := repository.NewPostgresEncryptionRepository(db)
+	var total int64
+	for {
+		n, err := repo.Reencrypt(ctx, keyring, *batchSize)
+		total += n
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		log.WithField("rows", total).Info("Re-encrypting recipients...")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	log.WithField("rows", total).Info("Re-encryption finished")
+	return nil
+}
+
+// loadKeyring returns nil when encryption of PII columns is not configured
+func loadKeyring(ctx context.Context, cfg config.Encryption, db *sql.DB) (*encryption.Keyring, error) {
+	var (
+		master encryption.MasterKey
+		err    error
+	)
+	switch {
+	case cfg.MasterKey != "":
+		master, err = encryption.ParseMasterKey(cfg.MasterKey)
+	case cfg.KeyFile != "":
+		master, err = encryption.LoadKeyFile(cfg.KeyFile)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []encryption.MasterKey
+	for _, encoded := range cfg.PreviousMasterKeys {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		key, err := encryption.ParseMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous master key: %w", err)
+		}
+		previous = append(previous, key)
+	}
+
+	return encryption.LoadKeyring(ctx, repository.NewPostgresEncryptionRepository(db), master, previous...)
+}
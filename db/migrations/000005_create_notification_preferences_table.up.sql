@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT PRIMARY KEY,
+    transactional_opt_in BOOLEAN NOT NULL DEFAULT TRUE,
+    marketing_opt_in BOOLEAN NOT NULL DEFAULT FALSE,
+    alerts_opt_in BOOLEAN NOT NULL DEFAULT TRUE,
+    preferred_channel TEXT,
+    preferred_language TEXT,
+    quiet_hours_start TEXT,
+    quiet_hours_end TEXT,
+    timezone TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE TABLE IF NOT EXISTS preference_overrides (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    transaction_id TEXT,
+    category TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_preference_overrides_user_id ON preference_overrides (user_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS suppressed_recipients;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS suppressed_recipients (
+    address TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,
+    source TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
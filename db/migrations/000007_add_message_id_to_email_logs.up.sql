@@ -0,0 +1,3 @@
+ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS message_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_email_logs_message_id ON email_logs (message_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS delivery_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider TEXT NOT NULL,
+    provider_event_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    recipient TEXT,
+    reason TEXT,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    payload JSONB,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_event_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_delivery_events_message_id ON delivery_events (message_id);
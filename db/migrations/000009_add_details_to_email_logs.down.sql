@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_email_logs_provider_message_id;
+DROP INDEX IF EXISTS idx_email_logs_user_id;
+
+ALTER TABLE email_logs
+    DROP COLUMN IF EXISTS event_type,
+    DROP COLUMN IF EXISTS refund_id,
+    DROP COLUMN IF EXISTS user_id,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS provider,
+    DROP COLUMN IF EXISTS provider_message_id,
+    DROP COLUMN IF EXISTS template_name,
+    DROP COLUMN IF EXISTS template_version,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS duration_ms,
+    DROP COLUMN IF EXISTS body_hash;
@@ -0,0 +1,15 @@
+ALTER TABLE email_logs
+    ADD COLUMN IF NOT EXISTS event_type TEXT,
+    ADD COLUMN IF NOT EXISTS refund_id TEXT,
+    ADD COLUMN IF NOT EXISTS user_id TEXT,
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS provider TEXT,
+    ADD COLUMN IF NOT EXISTS provider_message_id TEXT,
+    ADD COLUMN IF NOT EXISTS template_name TEXT,
+    ADD COLUMN IF NOT EXISTS template_version INTEGER,
+    ADD COLUMN IF NOT EXISTS locale TEXT,
+    ADD COLUMN IF NOT EXISTS duration_ms INTEGER,
+    ADD COLUMN IF NOT EXISTS body_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_email_logs_user_id ON email_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_provider_message_id ON email_logs (provider_message_id);
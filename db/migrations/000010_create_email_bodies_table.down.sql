@@ -0,0 +1,2 @@
+ALTER TABLE email_logs DROP COLUMN IF EXISTS body_id;
+DROP TABLE IF EXISTS email_bodies;
\ No newline at end of file
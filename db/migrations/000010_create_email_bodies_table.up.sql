@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS email_bodies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subject TEXT NOT NULL,
+    text_gz BYTEA NOT NULL,
+    html_gz BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ
+    );
+
+CREATE INDEX IF NOT EXISTS idx_email_bodies_expires_at ON email_bodies (expires_at) WHERE expires_at IS NOT NULL;
+
+ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS body_id UUID REFERENCES email_bodies (id) ON DELETE SET NULL;
@@ -0,0 +1,40 @@
+CREATE TABLE email_logs_unpartitioned (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id TEXT,
+    recipient_email TEXT NOT NULL,
+    subject TEXT,
+    status TEXT NOT NULL,
+    error_message TEXT,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    message_id TEXT,
+    event_type TEXT,
+    refund_id TEXT,
+    user_id TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    provider TEXT,
+    provider_message_id TEXT,
+    template_name TEXT,
+    template_version INTEGER,
+    locale TEXT,
+    duration_ms INTEGER,
+    body_hash TEXT,
+    body_id UUID REFERENCES email_bodies (id) ON DELETE SET NULL
+    );
+
+INSERT INTO email_logs_unpartitioned (id, transaction_id, recipient_email, subject, status, error_message, sent_at, message_id,
+                                      event_type, refund_id, user_id, attempts, provider, provider_message_id, template_name,
+                                      template_version, locale, duration_ms, body_hash, body_id)
+SELECT id, transaction_id, recipient_email, subject, status, error_message, sent_at, message_id,
+       event_type, refund_id, user_id, attempts, provider, provider_message_id, template_name,
+       template_version, locale, duration_ms, body_hash, body_id
+FROM email_logs;
+
+DROP TABLE email_logs CASCADE;
+ALTER TABLE email_logs_unpartitioned RENAME TO email_logs;
+ALTER TABLE email_logs RENAME CONSTRAINT email_logs_unpartitioned_pkey TO email_logs_pkey;
+ALTER TABLE email_logs RENAME CONSTRAINT email_logs_unpartitioned_body_id_fkey TO email_logs_body_id_fkey;
+
+CREATE INDEX IF NOT EXISTS idx_email_logs_transaction_id ON email_logs (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_message_id ON email_logs (message_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_user_id ON email_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_provider_message_id ON email_logs (provider_message_id);
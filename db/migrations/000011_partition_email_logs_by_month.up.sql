@@ -0,0 +1,73 @@
+ALTER TABLE email_logs RENAME TO email_logs_legacy;
+ALTER TABLE email_logs_legacy RENAME CONSTRAINT email_logs_pkey TO email_logs_legacy_pkey;
+ALTER TABLE email_logs_legacy RENAME CONSTRAINT email_logs_body_id_fkey TO email_logs_legacy_body_id_fkey;
+ALTER INDEX IF EXISTS idx_email_logs_transaction_id RENAME TO idx_email_logs_legacy_transaction_id;
+ALTER INDEX IF EXISTS idx_email_logs_message_id RENAME TO idx_email_logs_legacy_message_id;
+ALTER INDEX IF EXISTS idx_email_logs_user_id RENAME TO idx_email_logs_legacy_user_id;
+ALTER INDEX IF EXISTS idx_email_logs_provider_message_id RENAME TO idx_email_logs_legacy_provider_message_id;
+
+-- The partition key has to be part of the primary key
+CREATE TABLE email_logs (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    transaction_id TEXT,
+    recipient_email TEXT NOT NULL,
+    subject TEXT,
+    status TEXT NOT NULL,
+    error_message TEXT,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    message_id TEXT,
+    event_type TEXT,
+    refund_id TEXT,
+    user_id TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    provider TEXT,
+    provider_message_id TEXT,
+    template_name TEXT,
+    template_version INTEGER,
+    locale TEXT,
+    duration_ms INTEGER,
+    body_hash TEXT,
+    body_id UUID REFERENCES email_bodies (id) ON DELETE SET NULL,
+    PRIMARY KEY (id, sent_at)
+    ) PARTITION BY RANGE (sent_at);
+
+-- Catches rows outside of the managed monthly partitions
+CREATE TABLE email_logs_default PARTITION OF email_logs DEFAULT;
+
+-- Monthly partitions named email_logs_YYYY_MM covering existing rows and the next three months
+DO $$
+DECLARE
+    month_start TIMESTAMPTZ;
+BEGIN
+    FOR month_start IN
+        SELECT generate_series(
+            date_trunc('month', COALESCE((SELECT MIN(sent_at) FROM email_logs_legacy), NOW()) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+            date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' + INTERVAL '3 months',
+            INTERVAL '1 month'
+        )
+    LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF email_logs FOR VALUES FROM (%L) TO (%L)',
+            'email_logs_' || to_char(month_start AT TIME ZONE 'UTC', 'YYYY_MM'),
+            month_start,
+            month_start + INTERVAL '1 month'
+        );
+    END LOOP;
+END $$;
+
+INSERT INTO email_logs (id, transaction_id, recipient_email, subject, status, error_message, sent_at, message_id,
+                        event_type, refund_id, user_id, attempts, provider, provider_message_id, template_name,
+                        template_version, locale, duration_ms, body_hash, body_id)
+SELECT id, transaction_id, recipient_email, subject, status, error_message, sent_at, message_id,
+       event_type, refund_id, user_id, attempts, provider, provider_message_id, template_name,
+       template_version, locale, duration_ms, body_hash, body_id
+FROM email_logs_legacy;
+
+DROP TABLE email_logs_legacy;
+
+-- Indexes on the parent are created on every current and future partition
+CREATE INDEX IF NOT EXISTS idx_email_logs_transaction_id ON email_logs (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_message_id ON email_logs (message_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_user_id ON email_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_provider_message_id ON email_logs (provider_message_id);
+CREATE INDEX IF NOT EXISTS idx_email_logs_status_sent_at ON email_logs (status, sent_at);
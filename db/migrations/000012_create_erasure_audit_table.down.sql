@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_delivery_events_recipient_lower;
+DROP INDEX IF EXISTS idx_notification_logs_recipient_lower;
+DROP INDEX IF EXISTS idx_email_logs_recipient_email_lower;
+DROP TABLE IF EXISTS erasure_audit;
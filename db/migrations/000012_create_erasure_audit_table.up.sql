@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS erasure_audit (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    affected_rows JSONB NOT NULL,
+    erased_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_erasure_audit_user_id ON erasure_audit (user_id, erased_at DESC);
+
+-- Erasure looks recipients up case-insensitively
+CREATE INDEX IF NOT EXISTS idx_email_logs_recipient_email_lower ON email_logs (lower(recipient_email));
+CREATE INDEX IF NOT EXISTS idx_notification_logs_recipient_lower ON notification_logs (lower(recipient));
+CREATE INDEX IF NOT EXISTS idx_delivery_events_recipient_lower ON delivery_events (lower(recipient));
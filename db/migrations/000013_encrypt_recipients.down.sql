@@ -0,0 +1,16 @@
+-- Ciphertexts cannot be decrypted here; encrypted recipients are lost
+UPDATE notification_logs SET recipient = '[encrypted]' WHERE recipient IS NULL;
+DROP INDEX IF EXISTS idx_notification_logs_recipient_bidx;
+ALTER TABLE notification_logs
+    DROP COLUMN IF EXISTS recipient_bidx,
+    DROP COLUMN IF EXISTS recipient_enc,
+    ALTER COLUMN recipient SET NOT NULL;
+
+UPDATE email_logs SET recipient_email = '[encrypted]' WHERE recipient_email IS NULL;
+DROP INDEX IF EXISTS idx_email_logs_recipient_email_bidx;
+ALTER TABLE email_logs
+    DROP COLUMN IF EXISTS recipient_email_bidx,
+    DROP COLUMN IF EXISTS recipient_email_enc,
+    ALTER COLUMN recipient_email SET NOT NULL;
+
+DROP TABLE IF EXISTS encryption_keys;
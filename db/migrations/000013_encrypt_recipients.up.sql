@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    purpose TEXT NOT NULL,
+    master_key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ
+    );
+
+-- Blind indexes are only comparable under a single index key
+CREATE UNIQUE INDEX IF NOT EXISTS idx_encryption_keys_index_key ON encryption_keys (purpose) WHERE purpose = 'index';
+
+-- Encrypted rows keep the plaintext column NULL
+ALTER TABLE email_logs
+    ALTER COLUMN recipient_email DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS recipient_email_enc BYTEA,
+    ADD COLUMN IF NOT EXISTS recipient_email_bidx TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_email_logs_recipient_email_bidx ON email_logs (recipient_email_bidx);
+
+ALTER TABLE notification_logs
+    ALTER COLUMN recipient DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS recipient_enc BYTEA,
+    ADD COLUMN IF NOT EXISTS recipient_bidx TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notification_logs_recipient_bidx ON notification_logs (recipient_bidx);
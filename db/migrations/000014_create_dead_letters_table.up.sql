@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    topic TEXT NOT NULL,
+    message_partition INTEGER NOT NULL,
+    message_offset BIGINT NOT NULL,
+    message_key BYTEA,
+    -- The payload is kept encrypted in payload_enc when PII encryption is enabled
+    payload BYTEA,
+    payload_enc BYTEA,
+    headers JSONB,
+    error_message TEXT NOT NULL,
+    permanent BOOLEAN NOT NULL,
+    attempts INTEGER NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_topic_failed_at ON dead_letters (topic, failed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_failed_at ON dead_letters (failed_at DESC);
@@ -0,0 +1,2 @@
+-- SMS and push bodies are stored in email_bodies as well; subject is the push title or empty for SMS
+ALTER TABLE notification_logs ADD COLUMN IF NOT EXISTS body_id UUID REFERENCES email_bodies (id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_dead_letters_user_id_bidx;
+DROP INDEX IF EXISTS idx_dead_letters_user_id;
+
+ALTER TABLE dead_letters
+    DROP COLUMN IF EXISTS user_id_bidx,
+    DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,7 @@
+-- Erasure finds dead letters by their user; user_id_bidx replaces user_id when payloads are encrypted
+ALTER TABLE dead_letters
+    ADD COLUMN IF NOT EXISTS user_id TEXT,
+    ADD COLUMN IF NOT EXISTS user_id_bidx TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_user_id ON dead_letters (user_id);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_user_id_bidx ON dead_letters (user_id_bidx);
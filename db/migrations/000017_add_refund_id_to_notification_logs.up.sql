@@ -0,0 +1,2 @@
+-- Tells the refunds of one transaction apart, so each is notified once
+ALTER TABLE notification_logs ADD COLUMN IF NOT EXISTS refund_id TEXT;
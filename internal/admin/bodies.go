@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"notification-service/internal/domain"
+	"notification-service/internal/repository"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BodyRepository defines the interface for reading stored message bodies
+type BodyRepository interface {
+	GetByLogID(ctx context.Context, logID string) (domain.EmailBody, error)
+	GetByNotificationLogID(ctx context.Context, logID string) (domain.EmailBody, error)
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?[0-9][0-9 ()-]{8,}[0-9]`)
+)
+
+// redactPII masks email addresses and phone numbers in a message
+func redactPII(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[redacted email]")
+	return phonePattern.ReplaceAllString(s, "[redacted phone]")
+}
+
+// RegisterEmailBodies exposes
+// GET /admin/email-logs/{logID}/body?redact=true and
+// GET /admin/notification-logs/{logID}/body?redact=true for every channel
+func (s *Server) RegisterEmailBodies(repo BodyRepository) {
+	s.mux.Handle("GET /admin/email-logs/{logID}/body", bodyHandler(repo.GetByLogID))
+	s.mux.Handle("GET /admin/notification-logs/{logID}/body", bodyHandler(repo.GetByNotificationLogID))
+}
+
+func bodyHandler(get func(ctx context.Context, logID string) (domain.EmailBody, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := get(r.Context(), r.PathValue("logID"))
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "body not found or expired")
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to load email body")
+			writeError(w, http.StatusInternalServerError, "failed to load body")
+			return
+		}
+
+		if r.URL.Query().Get("redact") == "true" {
+			body.Subject = redactPII(body.Subject)
+			body.Text = redactPII(body.Text)
+			body.HTML = redactPII(body.HTML)
+		}
+		writeJSON(w, http.StatusOK, body)
+	}
+}
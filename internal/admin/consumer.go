@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"net/http"
+	"notification-service/internal/consumer"
+)
+
+// ConsumerStats defines the interface for reading per-topic consumer counters
+type ConsumerStats interface {
+	Stats() map[string]consumer.TopicStats
+}
+
+// RegisterConsumerStats exposes GET /admin/consumer/stats
+func (s *Server) RegisterConsumerStats(stats ConsumerStats) {
+	s.mux.HandleFunc("GET /admin/consumer/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, stats.Stats())
+	})
+}
+
+// ConsumerFlow defines the interface for reading the consumer's health-aware flow control
+type ConsumerFlow interface {
+	FlowStatus() (consumer.FlowStatus, bool)
+}
+
+// RegisterConsumerFlow exposes GET /admin/consumer/flow with the pause and
+// resume transitions caused by unhealthy dependencies
+func (s *Server) RegisterConsumerFlow(flow ConsumerFlow) {
+	s.mux.HandleFunc("GET /admin/consumer/flow", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := flow.FlowStatus()
+		if !ok {
+			writeError(w, http.StatusNotFound, "flow control is disabled")
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"notification-service/internal/domain"
+	"strconv"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDeadLettersLimit = 50
+	maxDeadLettersLimit     = 500
+)
+
+// DeadLetterRepository defines the interface for inspecting dead letters
+type DeadLetterRepository interface {
+	ListDeadLetters(ctx context.Context, topic string, limit, offset int) ([]domain.DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, id string) (bool, error)
+}
+
+// RegisterDeadLetters exposes
+// GET /admin/dead-letters?topic=T&limit=N&offset=M and
+// DELETE /admin/dead-letters/{id}
+func (s *Server) RegisterDeadLetters(repo DeadLetterRepository) {
+	s.mux.HandleFunc("GET /admin/dead-letters", func(w http.ResponseWriter, r *http.Request) {
+		limit, offset := defaultDeadLettersLimit, 0
+		q := r.URL.Query()
+		if raw := q.Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			limit = min(n, maxDeadLettersLimit)
+		}
+		if raw := q.Get("offset"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+				return
+			}
+			offset = n
+		}
+
+		letters, err := repo.ListDeadLetters(r.Context(), q.Get("topic"), limit, offset)
+		if err != nil {
+			log.WithError(err).Error("Failed to list dead letters")
+			writeError(w, http.StatusInternalServerError, "failed to list dead letters")
+			return
+		}
+		writeJSON(w, http.StatusOK, letters)
+	})
+
+	s.mux.HandleFunc("DELETE /admin/dead-letters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, err := uuid.Parse(id); err != nil {
+			writeError(w, http.StatusBadRequest, "id must be a UUID")
+			return
+		}
+
+		removed, err := repo.DeleteDeadLetter(r.Context(), id)
+		if err != nil {
+			log.WithError(err).Error("Failed to delete dead letter")
+			writeError(w, http.StatusInternalServerError, "failed to delete dead letter")
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, "dead letter not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"notification-service/internal/domain"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DeviceTokenRepository defines the interface for managing push device tokens
+type DeviceTokenRepository interface {
+	Register(ctx context.Context, t domain.DeviceToken) error
+	ListByUser(ctx context.Context, userID string) ([]domain.DeviceToken, error)
+	Unregister(ctx context.Context, userID, token string) (bool, error)
+}
+
+type registerDeviceRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+// RegisterDevices exposes
+// GET /admin/users/{userID}/devices,
+// POST /admin/users/{userID}/devices and
+// DELETE /admin/users/{userID}/devices/{token}.
+// Registering a known token moves it to the user and refreshes its last seen time.
+func (s *Server) RegisterDevices(repo DeviceTokenRepository) {
+	s.mux.HandleFunc("GET /admin/users/{userID}/devices", func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := repo.ListByUser(r.Context(), r.PathValue("userID"))
+		if err != nil {
+			log.WithError(err).Error("Failed to list device tokens")
+			writeError(w, http.StatusInternalServerError, "failed to list devices")
+			return
+		}
+		if tokens == nil {
+			tokens = []domain.DeviceToken{}
+		}
+		writeJSON(w, http.StatusOK, tokens)
+	})
+
+	s.mux.HandleFunc("POST /admin/users/{userID}/devices", func(w http.ResponseWriter, r *http.Request) {
+		var req registerDeviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if strings.TrimSpace(req.Token) == "" {
+			writeError(w, http.StatusBadRequest, "token is required")
+			return
+		}
+		if req.Platform != "ios" && req.Platform != "android" {
+			writeError(w, http.StatusBadRequest, "platform must be ios or android")
+			return
+		}
+
+		token := domain.DeviceToken{UserID: r.PathValue("userID"), Token: strings.TrimSpace(req.Token), Platform: req.Platform}
+		if err := repo.Register(r.Context(), token); err != nil {
+			log.WithError(err).Error("Failed to register device token")
+			writeError(w, http.StatusInternalServerError, "failed to register device")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.mux.HandleFunc("DELETE /admin/users/{userID}/devices/{token}", func(w http.ResponseWriter, r *http.Request) {
+		removed, err := repo.Unregister(r.Context(), r.PathValue("userID"), r.PathValue("token"))
+		if err != nil {
+			log.WithError(err).Error("Failed to unregister device token")
+			writeError(w, http.StatusInternalServerError, "failed to unregister device")
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, "device is not registered")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"notification-service/internal/domain"
+	"notification-service/internal/erasure"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Eraser defines the interface for the right-to-erasure workflow
+type Eraser interface {
+	Erase(ctx context.Context, req domain.UserDeletion, source domain.ErasureSource) (domain.ErasureRecord, error)
+}
+
+// ErasureAuditRepository defines the interface for reading erasure audit records
+type ErasureAuditRepository interface {
+	ListErasures(ctx context.Context, userID string) ([]domain.ErasureRecord, error)
+}
+
+// RegisterErasures exposes
+// POST /admin/erasures and
+// GET /admin/erasures/{user_id}
+func (s *Server) RegisterErasures(eraser Eraser, audit ErasureAuditRepository) {
+	s.mux.HandleFunc("POST /admin/erasures", func(w http.ResponseWriter, r *http.Request) {
+		var req domain.UserDeletion
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		record, err := eraser.Erase(r.Context(), req, domain.ErasureAdmin)
+		if errors.Is(err, erasure.ErrMissingUserID) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).WithField("user_id", req.UserID).Error("Failed to erase user data")
+			writeError(w, http.StatusInternalServerError, "failed to erase user data")
+			return
+		}
+		writeJSON(w, http.StatusOK, record)
+	})
+
+	s.mux.HandleFunc("GET /admin/erasures/{user_id}", func(w http.ResponseWriter, r *http.Request) {
+		records, err := audit.ListErasures(r.Context(), r.PathValue("user_id"))
+		if err != nil {
+			log.WithError(err).Error("Failed to list erasure audit records")
+			writeError(w, http.StatusInternalServerError, "failed to list erasures")
+			return
+		}
+		writeJSON(w, http.StatusOK, records)
+	})
+}
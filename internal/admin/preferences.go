@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"notification-service/internal/domain"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PreferenceRepository defines the interface for reading and saving user preferences
+type PreferenceRepository interface {
+	Get(ctx context.Context, userID string) (domain.NotificationPreferences, error)
+	Save(ctx context.Context, p domain.NotificationPreferences) error
+}
+
+// RegisterPreferences exposes
+// GET and PUT /admin/users/{userID}/preferences.
+// PUT merges the fields present in the body into the saved preferences, so a
+// partial body never resets the other opt-ins.
+func (s *Server) RegisterPreferences(repo PreferenceRepository) {
+	s.mux.HandleFunc("GET /admin/users/{userID}/preferences", func(w http.ResponseWriter, r *http.Request) {
+		prefs, err := repo.Get(r.Context(), r.PathValue("userID"))
+		if err != nil {
+			log.WithError(err).Error("Failed to load notification preferences")
+			writeError(w, http.StatusInternalServerError, "failed to load preferences")
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	})
+
+	s.mux.HandleFunc("PUT /admin/users/{userID}/preferences", func(w http.ResponseWriter, r *http.Request) {
+		userID := r.PathValue("userID")
+		prefs, err := repo.Get(r.Context(), userID)
+		if err != nil {
+			log.WithError(err).Error("Failed to load notification preferences")
+			writeError(w, http.StatusInternalServerError, "failed to load preferences")
+			return
+		}
+
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&prefs); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		prefs.UserID = userID
+
+		if msg := validatePreferences(prefs); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		if err := repo.Save(r.Context(), prefs); err != nil {
+			log.WithError(err).Error("Failed to save notification preferences")
+			writeError(w, http.StatusInternalServerError, "failed to save preferences")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func validatePreferences(p domain.NotificationPreferences) string {
+	switch p.PreferredChannel {
+	case "", domain.ChannelEmail, domain.ChannelSMS, domain.ChannelPush:
+	default:
+		return "unknown preferred_channel"
+	}
+	if (p.QuietHoursStart == "") != (p.QuietHoursEnd == "") {
+		return "quiet_hours_start and quiet_hours_end must be set together"
+	}
+	for _, t := range []string{p.QuietHoursStart, p.QuietHoursEnd} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil {
+			return "quiet hours must be in HH:MM format"
+		}
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return "unknown timezone"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"net/http"
+	"notification-service/internal/domain"
+	"notification-service/internal/schema"
+)
+
+// PayloadStats defines the interface for reading payload schema counters
+type PayloadStats interface {
+	Stats() map[domain.EventType]schema.PayloadStats
+}
+
+// RegisterPayloadStats exposes GET /admin/schemas/stats
+func (s *Server) RegisterPayloadStats(stats PayloadStats) {
+	s.mux.HandleFunc("GET /admin/schemas/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, stats.Stats())
+	})
+}
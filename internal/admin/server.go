@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Server exposes operational endpoints for operators under /admin/ and
+// callbacks from external providers under /webhooks/
+type Server struct {
+	mux    *http.ServeMux
+	public *http.ServeMux
+	server *http.Server
+	token  string
+}
+
+// NewServer creates an admin server listening on addr. Every /admin/ request
+// must carry token as a bearer token, so an empty token rejects them all;
+// /webhooks/ handlers authenticate callers themselves.
+func NewServer(addr, token string) *Server {
+	s := &Server{mux: http.NewServeMux(), public: http.NewServeMux(), token: token}
+
+	root := http.NewServeMux()
+	root.Handle("/admin/", s.authenticate(s.mux))
+	root.Handle("/webhooks/", s.public)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           root,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// HandlePublic registers a handler outside of the admin authentication.
+// The pattern must start with /webhooks/.
+func (s *Server) HandlePublic(pattern string, handler http.Handler) {
+	s.public.Handle(pattern, handler)
+}
+
+func (s *Server) Start() error {
+	log.WithField("addr", s.server.Addr).Info("Starting admin server")
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := "Bearer " + s.token
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("Failed to write admin response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"notification-service/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSuppressionsLimit = 100
+	maxSuppressionsLimit     = 1000
+)
+
+// SuppressionRepository defines the interface for managing the suppression list
+type SuppressionRepository interface {
+	Add(ctx context.Context, s domain.SuppressedRecipient) error
+	Remove(ctx context.Context, address string) (bool, error)
+	List(ctx context.Context, limit, offset int) ([]domain.SuppressedRecipient, error)
+}
+
+type addSuppressionRequest struct {
+	Address   string     `json:"address"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// RegisterSuppressions exposes
+// GET /admin/suppressions?limit=N&offset=M,
+// POST /admin/suppressions and
+// DELETE /admin/suppressions/{address}
+func (s *Server) RegisterSuppressions(repo SuppressionRepository) {
+	s.mux.HandleFunc("GET /admin/suppressions", func(w http.ResponseWriter, r *http.Request) {
+		limit, offset := defaultSuppressionsLimit, 0
+		q := r.URL.Query()
+		if raw := q.Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			limit = min(n, maxSuppressionsLimit)
+		}
+		if raw := q.Get("offset"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+				return
+			}
+			offset = n
+		}
+
+		recipients, err := repo.List(r.Context(), limit, offset)
+		if err != nil {
+			log.WithError(err).Error("Failed to list suppressed recipients")
+			writeError(w, http.StatusInternalServerError, "failed to list suppressions")
+			return
+		}
+		writeJSON(w, http.StatusOK, recipients)
+	})
+
+	s.mux.HandleFunc("POST /admin/suppressions", func(w http.ResponseWriter, r *http.Request) {
+		var req addSuppressionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if strings.TrimSpace(req.Address) == "" {
+			writeError(w, http.StatusBadRequest, "address is required")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "added by operator"
+		}
+
+		entry := domain.SuppressedRecipient{
+			Address:   req.Address,
+			Reason:    req.Reason,
+			Source:    domain.SuppressionManual,
+			ExpiresAt: req.ExpiresAt,
+		}
+		if err := repo.Add(r.Context(), entry); err != nil {
+			log.WithError(err).Error("Failed to add suppressed recipient")
+			writeError(w, http.StatusInternalServerError, "failed to add suppression")
+			return
+		}
+		log.WithField("source", entry.Source).Info("Recipient suppressed by operator")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.mux.HandleFunc("DELETE /admin/suppressions/{address}", func(w http.ResponseWriter, r *http.Request) {
+		removed, err := repo.Remove(r.Context(), r.PathValue("address"))
+		if err != nil {
+			log.WithError(err).Error("Failed to remove suppressed recipient")
+			writeError(w, http.StatusInternalServerError, "failed to remove suppression")
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, "address is not suppressed")
+			return
+		}
+		log.Info("Recipient removed from suppression list by operator")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
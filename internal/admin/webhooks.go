@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"notification-service/internal/domain"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// WebhookDeliveryRepository defines the interface for querying webhook deliveries
+type WebhookDeliveryRepository interface {
+	ListDeliveries(ctx context.Context, subscriberID string, limit int) ([]domain.WebhookDelivery, error)
+}
+
+// RegisterWebhookDeliveries exposes
+// GET /admin/webhooks/subscribers/{subscriberID}/deliveries?limit=N
+func (s *Server) RegisterWebhookDeliveries(repo WebhookDeliveryRepository) {
+	s.mux.HandleFunc("GET /admin/webhooks/subscribers/{subscriberID}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultDeliveriesLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			limit = min(n, maxDeliveriesLimit)
+		}
+
+		deliveries, err := repo.ListDeliveries(r.Context(), r.PathValue("subscriberID"), limit)
+		if err != nil {
+			log.WithError(err).Error("Failed to list webhook deliveries")
+			writeError(w, http.StatusInternalServerError, "failed to list deliveries")
+			return
+		}
+		writeJSON(w, http.StatusOK, deliveries)
+	})
+}
+
+// WebhookEndpointRepository defines the interface for managing subscriber endpoints
+type WebhookEndpointRepository interface {
+	CreateEndpoint(ctx context.Context, e domain.WebhookEndpoint) (domain.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, subscriberID string) ([]domain.WebhookEndpoint, error)
+	EnableEndpoint(ctx context.Context, subscriberID, endpointID string) (bool, error)
+	DeleteEndpoint(ctx context.Context, subscriberID, endpointID string) (bool, error)
+}
+
+type createEndpointRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"event_types,omitempty"`
+}
+
+// RegisterWebhookEndpoints exposes
+// GET and POST /admin/webhooks/subscribers/{subscriberID}/endpoints,
+// POST /admin/webhooks/subscribers/{subscriberID}/endpoints/{endpointID}/enable and
+// DELETE /admin/webhooks/subscribers/{subscriberID}/endpoints/{endpointID}.
+// A secret is generated when none is given; it is only returned on creation.
+func (s *Server) RegisterWebhookEndpoints(repo WebhookEndpointRepository) {
+	s.mux.HandleFunc("GET /admin/webhooks/subscribers/{subscriberID}/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		endpoints, err := repo.ListEndpoints(r.Context(), r.PathValue("subscriberID"))
+		if err != nil {
+			log.WithError(err).Error("Failed to list webhook endpoints")
+			writeError(w, http.StatusInternalServerError, "failed to list endpoints")
+			return
+		}
+		writeJSON(w, http.StatusOK, endpoints)
+	})
+
+	s.mux.HandleFunc("POST /admin/webhooks/subscribers/{subscriberID}/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		var req createEndpointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			writeError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+			return
+		}
+		for _, eventType := range req.EventTypes {
+			if strings.TrimSpace(eventType) == "" {
+				writeError(w, http.StatusBadRequest, "event_types must not contain empty values")
+				return
+			}
+		}
+		if req.Secret == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.WithError(err).Error("Failed to generate webhook secret")
+				writeError(w, http.StatusInternalServerError, "failed to create endpoint")
+				return
+			}
+			req.Secret = hex.EncodeToString(secret)
+		}
+
+		endpoint, err := repo.CreateEndpoint(r.Context(), domain.WebhookEndpoint{
+			SubscriberID: r.PathValue("subscriberID"),
+			URL:          req.URL,
+			Secret:       req.Secret,
+			EventTypes:   req.EventTypes,
+		})
+		if err != nil {
+			log.WithError(err).Error("Failed to create webhook endpoint")
+			writeError(w, http.StatusInternalServerError, "failed to create endpoint")
+			return
+		}
+		log.WithFields(log.Fields{
+			"subscriber_id": endpoint.SubscriberID,
+			"endpoint_id":   endpoint.ID,
+		}).Info("Webhook endpoint registered by operator")
+		writeJSON(w, http.StatusCreated, endpoint)
+	})
+
+	s.mux.HandleFunc("POST /admin/webhooks/subscribers/{subscriberID}/endpoints/{endpointID}/enable", func(w http.ResponseWriter, r *http.Request) {
+		enabled, err := repo.EnableEndpoint(r.Context(), r.PathValue("subscriberID"), r.PathValue("endpointID"))
+		if err != nil {
+			log.WithError(err).Error("Failed to enable webhook endpoint")
+			writeError(w, http.StatusInternalServerError, "failed to enable endpoint")
+			return
+		}
+		if !enabled {
+			writeError(w, http.StatusNotFound, "endpoint not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.mux.HandleFunc("DELETE /admin/webhooks/subscribers/{subscriberID}/endpoints/{endpointID}", func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := repo.DeleteEndpoint(r.Context(), r.PathValue("subscriberID"), r.PathValue("endpointID"))
+		if err != nil {
+			log.WithError(err).Error("Failed to delete webhook endpoint")
+			writeError(w, http.StatusInternalServerError, "failed to delete endpoint")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "endpoint not found")
+			return
+		}
+		log.WithFields(log.Fields{
+			"subscriber_id": r.PathValue("subscriberID"),
+			"endpoint_id":   r.PathValue("endpointID"),
+		}).Info("Webhook endpoint deleted by operator")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
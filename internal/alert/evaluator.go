@@ -0,0 +1,185 @@
+package alert
+
+import (
+	"context"
+	"notification-service/internal/domain"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// queueSize bounds the alerts waiting for the notifiers; more are dropped
+const queueSize = 100
+
+// Evaluator runs events through the rules and forwards fired alerts to
+// every notifier in the background, at most once per rule cooldown for the
+// same key. The cooldown starts once a notifier accepted the alert.
+type Evaluator struct {
+	rules     []Rule
+	notifiers []Notifier
+	now       func() time.Time
+	queue     chan Alert
+	done      chan struct{}
+
+	mu        sync.Mutex
+	closed    bool
+	lastFired map[string]time.Time
+	pending   map[string]bool
+}
+
+func NewEvaluator(rules []Rule, notifiers []Notifier) *Evaluator {
+	e := &Evaluator{
+		rules:     rules,
+		notifiers: notifiers,
+		now:       time.Now,
+		queue:     make(chan Alert, queueSize),
+		done:      make(chan struct{}),
+		lastFired: make(map[string]time.Time),
+		pending:   make(map[string]bool),
+	}
+	go e.run()
+	return e
+}
+
+// Observe runs an event of any type through the rules; only purchases and
+// refunds have rules so far
+func (e *Evaluator) Observe(ctx context.Context, event any) {
+	switch ev := event.(type) {
+	case domain.PurchaseInfo:
+		e.ObservePurchase(ctx, ev)
+	case domain.RefundInfo:
+		e.ObserveRefund(ctx, ev)
+	}
+}
+
+func (e *Evaluator) ObservePurchase(ctx context.Context, purchase domain.PurchaseInfo) {
+	now := e.now()
+	for _, rule := range e.rules {
+		if a := rule.OnPurchase(purchase, now); a != nil {
+			e.fire(rule, *a)
+		}
+	}
+}
+
+func (e *Evaluator) ObserveRefund(ctx context.Context, refund domain.RefundInfo) {
+	now := e.now()
+	for _, rule := range e.rules {
+		if a := rule.OnRefund(refund, now); a != nil {
+			e.fire(rule, *a)
+		}
+	}
+}
+
+// Close stops accepting alerts and waits until queued ones are sent or ctx
+// is done. Alerts fired after Close are dropped.
+func (e *Evaluator) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fire queues an alert without blocking the event that triggered it
+func (e *Evaluator) fire(rule Rule, a Alert) {
+	if !e.acquire(rule, a) {
+		log.WithFields(log.Fields{
+			"rule": a.Rule,
+			"key":  a.Key,
+		}).Debug("Alert suppressed by cooldown")
+		return
+	}
+
+	if !e.enqueue(a) {
+		e.release(a, false)
+		log.WithFields(log.Fields{
+			"rule": a.Rule,
+			"key":  a.Key,
+		}).Warn("Alert queue is full or closed, alert dropped")
+	}
+}
+
+// enqueue queues a unless the queue is full or closed
+func (e *Evaluator) enqueue(a Alert) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return false
+	}
+	select {
+	case e.queue <- a:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Evaluator) run() {
+	defer close(e.done)
+	for a := range e.queue {
+		e.send(a)
+	}
+}
+
+func (e *Evaluator) send(a Alert) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sent := false
+	for _, n := range e.notifiers {
+		if err := n.Notify(ctx, a); err != nil {
+			log.WithError(err).WithField("rule", a.Rule).Error("Failed to send alert")
+			continue
+		}
+		sent = true
+	}
+	e.release(a, sent)
+	if sent {
+		log.WithFields(log.Fields{
+			"rule": a.Rule,
+			"key":  a.Key,
+		}).Info("Alert sent")
+	}
+}
+
+func alertKey(a Alert) string {
+	return a.Rule + ":" + a.Key
+}
+
+// acquire reports whether the alert may be sent: it is not in its cooldown
+// and the same alert is not already waiting for the notifiers
+func (e *Evaluator) acquire(rule Rule, a Alert) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := alertKey(a)
+	if e.pending[key] {
+		return false
+	}
+	if last, ok := e.lastFired[key]; ok && a.TriggeredAt.Sub(last) < rule.Cooldown() {
+		return false
+	}
+	e.pending[key] = true
+	return true
+}
+
+// release ends the wait of an alert and starts its cooldown when it was sent
+func (e *Evaluator) release(a Alert, sent bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := alertKey(a)
+	delete(e.pending, key)
+	if sent {
+		e.lastFired[key] = a.TriggeredAt
+	}
+}
@@ -0,0 +1,71 @@
+package alert
+
+import (
+	"context"
+	"notification-service/internal/domain"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, a Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func TestEvaluatorDropsAlertsAfterClose(t *testing.T) {
+	notifier := &recordingNotifier{}
+	e := NewEvaluator([]Rule{&HighValuePurchaseRule{Threshold: 100}}, []Notifier{notifier})
+
+	e.Observe(context.Background(), domain.PurchaseInfo{UserID: "user-1", CoinsPurchased: 500})
+	if err := e.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Events still being handled after the shutdown wait must not panic
+	e.Observe(context.Background(), domain.PurchaseInfo{UserID: "user-2", CoinsPurchased: 500})
+	if err := e.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Key != "user-1" {
+		t.Errorf("sent %+v, want the alert fired before Close", notifier.alerts)
+	}
+}
+
+func TestEvaluatorAppliesCooldown(t *testing.T) {
+	notifier := &recordingNotifier{}
+	e := NewEvaluator([]Rule{&HighValuePurchaseRule{Threshold: 100, CooldownTime: time.Hour}}, []Notifier{notifier})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+
+	for _, at := range []time.Duration{0, time.Minute, 2 * time.Hour} {
+		now = now.Add(at)
+		e.Observe(context.Background(), domain.PurchaseInfo{UserID: "user-1", CoinsPurchased: 500})
+		// Let the alert be sent, which starts its cooldown
+		deadline := time.Now().Add(time.Second)
+		for {
+			e.mu.Lock()
+			pending := len(e.pending)
+			e.mu.Unlock()
+			if pending == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err := e.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.alerts) != 2 {
+		t.Errorf("sent %d alerts, want 2", len(notifier.alerts))
+	}
+}
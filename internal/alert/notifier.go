@@ -0,0 +1,113 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Notifier posts alerts to an incoming webhook of a chat tool
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// WebhookNotifier posts the alert as plain JSON
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, n.client, n.url, a)
+}
+
+// SlackNotifier posts the alert in the Slack incoming-webhook format, which
+// is also understood by Mattermost and Rocket.Chat
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackNotifier(url string, timeout time.Duration) *SlackNotifier {
+	return &SlackNotifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, a Alert) error {
+	keys := make([]string, 0, len(a.Fields))
+	for k := range a.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]slackField, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, slackField{Title: k, Value: a.Fields[k], Short: true})
+	}
+
+	msg := slackMessage{
+		Text: ":rotating_light: " + a.Title,
+		Attachments: []slackAttachment{{
+			Fallback: a.Text,
+			Color:    "danger",
+			Title:    a.Title,
+			Text:     a.Text,
+			Fields:   fields,
+			Ts:       a.TriggeredAt.Unix(),
+		}},
+	}
+	return postJSON(ctx, n.client, n.url, msg)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alert webhook returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
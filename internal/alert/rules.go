@@ -0,0 +1,156 @@
+package alert
+
+import (
+	"fmt"
+	"notification-service/internal/domain"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Alert is a message for the internal team chat
+type Alert struct {
+	Rule string `json:"rule"`
+	// Key identifies what the alert is about; cooldowns apply per rule and key
+	Key         string            `json:"key"`
+	Title       string            `json:"title"`
+	Text        string            `json:"text"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	TriggeredAt time.Time         `json:"triggered_at"`
+}
+
+// Rule inspects purchase and refund events and returns an alert when it fires
+type Rule interface {
+	Name() string
+	Cooldown() time.Duration
+	OnPurchase(purchase domain.PurchaseInfo, now time.Time) *Alert
+	OnRefund(refund domain.RefundInfo, now time.Time) *Alert
+}
+
+// HighValuePurchaseRule fires when a single purchase exceeds a number of coins
+type HighValuePurchaseRule struct {
+	Threshold    int
+	CooldownTime time.Duration
+}
+
+func (r *HighValuePurchaseRule) Name() string { return "high_value_purchase" }
+
+func (r *HighValuePurchaseRule) Cooldown() time.Duration { return r.CooldownTime }
+
+func (r *HighValuePurchaseRule) OnPurchase(purchase domain.PurchaseInfo, now time.Time) *Alert {
+	if purchase.CoinsPurchased <= r.Threshold {
+		return nil
+	}
+	return &Alert{
+		Rule:  r.Name(),
+		Key:   purchase.UserID,
+		Title: "High-value purchase",
+		Text:  fmt.Sprintf("User %s purchased %d coins (threshold %d)", purchase.UserID, purchase.CoinsPurchased, r.Threshold),
+		Fields: map[string]string{
+			"transaction_id": purchase.TransactionID,
+			"coins":          strconv.Itoa(purchase.CoinsPurchased),
+			"provider":       purchase.Provider,
+			"country":        purchase.Country,
+		},
+		TriggeredAt: now,
+	}
+}
+
+func (r *HighValuePurchaseRule) OnRefund(domain.RefundInfo, time.Time) *Alert { return nil }
+
+// RefundSpikeRule fires when a provider sees more than Threshold refunds
+// within a sliding Window of their processing times. A refund handled again
+// is counted once, and a replayed backlog is counted when it happened, not
+// all at once.
+type RefundSpikeRule struct {
+	Threshold    int
+	Window       time.Duration
+	CooldownTime time.Duration
+
+	mu      sync.Mutex
+	refunds map[string][]countedRefund
+}
+
+type countedRefund struct {
+	id string
+	at time.Time
+}
+
+func (r *RefundSpikeRule) Name() string { return "refund_spike" }
+
+func (r *RefundSpikeRule) Cooldown() time.Duration { return r.CooldownTime }
+
+func (r *RefundSpikeRule) OnPurchase(domain.PurchaseInfo, time.Time) *Alert { return nil }
+
+func (r *RefundSpikeRule) OnRefund(refund domain.RefundInfo, now time.Time) *Alert {
+	provider := refund.Provider
+	if provider == "" {
+		provider = "unknown"
+	}
+	// Refunds without a processing time count when they arrive
+	at, err := time.Parse(time.RFC3339, refund.ProcessedAt)
+	if err != nil {
+		at = now
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refunds == nil {
+		r.refunds = make(map[string][]countedRefund)
+	}
+
+	latest := at
+	for _, c := range r.refunds[provider] {
+		if refund.RefundID != "" && c.id == refund.RefundID {
+			return nil
+		}
+		if c.at.After(latest) {
+			latest = c.at
+		}
+	}
+
+	// Refunds that left the window of the latest one can no longer count
+	cutoff := latest.Add(-r.Window)
+	kept := r.refunds[provider][:0]
+	for _, c := range r.refunds[provider] {
+		if c.at.After(cutoff) {
+			kept = append(kept, c)
+		}
+	}
+	kept = append(kept, countedRefund{id: refund.RefundID, at: at})
+	r.refunds[provider] = kept
+
+	// A refund arriving late can complete a window that ends after it
+	count, end := 0, at
+	for _, e := range kept {
+		if e.at.Before(at) || !e.at.Before(at.Add(r.Window)) {
+			continue
+		}
+		n := 0
+		for _, c := range kept {
+			if c.at.After(e.at.Add(-r.Window)) && !c.at.After(e.at) {
+				n++
+			}
+		}
+		if n > count {
+			count, end = n, e.at
+		}
+	}
+	if count <= r.Threshold {
+		return nil
+	}
+	return &Alert{
+		Rule:  r.Name(),
+		Key:   provider,
+		Title: "Refund spike",
+		Text:  fmt.Sprintf("%d refunds for provider %s in the %s up to %s (threshold %d)", count, provider, r.Window, end.UTC().Format(time.RFC3339), r.Threshold),
+		Fields: map[string]string{
+			"provider":    provider,
+			"refunds":     strconv.Itoa(count),
+			"window":      r.Window.String(),
+			"last_refund": refund.RefundID,
+		},
+		TriggeredAt: now,
+	}
+}
@@ -0,0 +1,54 @@
+package alert
+
+import (
+	"notification-service/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestRefundSpikeRule(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	refund := func(id string, at time.Duration) domain.RefundInfo {
+		return domain.RefundInfo{RefundID: id, Provider: "stripe", ProcessedAt: start.Add(at).Format(time.RFC3339)}
+	}
+
+	tests := []struct {
+		name    string
+		refunds []domain.RefundInfo
+		want    bool // whether the last refund fires
+	}{
+		{
+			name:    "spike",
+			refunds: []domain.RefundInfo{refund("r1", 0), refund("r2", time.Minute), refund("r3", 2*time.Minute)},
+			want:    true,
+		},
+		{
+			name:    "same refund handled again",
+			refunds: []domain.RefundInfo{refund("r1", 0), refund("r2", time.Minute), refund("r2", time.Minute), refund("r1", 0)},
+		},
+		{
+			// A replayed backlog arrives at once but happened over hours
+			name:    "spread out backlog",
+			refunds: []domain.RefundInfo{refund("r1", 0), refund("r2", time.Hour), refund("r3", 2*time.Hour)},
+		},
+		{
+			name:    "late refund within the window",
+			refunds: []domain.RefundInfo{refund("r1", 2*time.Minute), refund("r2", 3*time.Minute), refund("r3", time.Minute)},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &RefundSpikeRule{Threshold: 2, Window: 10 * time.Minute}
+			now := start.Add(24 * time.Hour)
+			var last *Alert
+			for _, r := range tt.refunds {
+				last = rule.OnRefund(r, now)
+			}
+			if fired := last != nil; fired != tt.want {
+				t.Errorf("fired = %v, want %v (alert %+v)", fired, tt.want, last)
+			}
+		})
+	}
+}
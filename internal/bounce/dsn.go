@@ -0,0 +1,165 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// ErrNotDSN is returned for messages that are not RFC 3464 delivery status notifications
+var ErrNotDSN = errors.New("message is not a delivery status notification")
+
+// DSN is a parsed RFC 3464 delivery status notification
+type DSN struct {
+	// OriginalMessageID is the Message-ID of the message that bounced
+	OriginalMessageID string
+	Recipients        []RecipientStatus
+}
+
+// RecipientStatus holds the per-recipient fields of a DSN
+type RecipientStatus struct {
+	FinalRecipient string
+	Action         string // failed, delayed, delivered, relayed or expanded
+	Status         string // enhanced status code such as 5.1.1
+	DiagnosticCode string
+}
+
+// Failed reports whether delivery to the recipient was given up
+func (r RecipientStatus) Failed() bool {
+	return strings.EqualFold(r.Action, "failed")
+}
+
+// Hard reports whether the failure is permanent (5.x.x)
+func (r RecipientStatus) Hard() bool {
+	return r.Failed() && strings.HasPrefix(r.Status, "5")
+}
+
+// ParseDSN reads a multipart/report; report-type=delivery-status message
+func ParseDSN(r io.Reader) (*DSN, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.EqualFold(mediaType, "multipart/report") ||
+		!strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	dsn := &DSN{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(decodePart(part))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report part: %w", err)
+		}
+
+		switch strings.ToLower(partType) {
+		case "message/delivery-status", "message/global-delivery-status":
+			recipients, err := parseDeliveryStatus(body)
+			if err != nil {
+				return nil, err
+			}
+			dsn.Recipients = append(dsn.Recipients, recipients...)
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			if id := originalMessageID(body); id != "" {
+				dsn.OriginalMessageID = id
+			}
+		}
+	}
+
+	if dsn.OriginalMessageID == "" {
+		// Some MTAs omit the returned headers but reference the original message
+		dsn.OriginalMessageID = strings.TrimSpace(msg.Header.Get("In-Reply-To"))
+	}
+	if len(dsn.Recipients) == 0 {
+		return nil, ErrNotDSN
+	}
+	return dsn, nil
+}
+
+// decodePart undoes the transfer encodings multipart does not handle itself
+func decodePart(part *multipart.Part) io.Reader {
+	switch strings.ToLower(part.Header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, part)
+	case "quoted-printable":
+		return quotedprintable.NewReader(part)
+	}
+	return part
+}
+
+// parseDeliveryStatus parses the per-message block followed by one block per recipient
+func parseDeliveryStatus(body []byte) ([]RecipientStatus, error) {
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(body)))
+
+	// The first block carries per-message fields such as Reporting-MTA
+	if _, err := tr.ReadMIMEHeader(); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse delivery status: %w", err)
+	}
+
+	var recipients []RecipientStatus
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			recipients = append(recipients, RecipientStatus{
+				FinalRecipient: addressField(h.Get("Final-Recipient")),
+				Action:         strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+				Status:         statusCode(h.Get("Status")),
+				DiagnosticCode: addressField(h.Get("Diagnostic-Code")),
+			})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse delivery status: %w", err)
+		}
+	}
+	return recipients, nil
+}
+
+// addressField strips the type prefix of fields like "rfc822; user@example.com"
+func addressField(v string) string {
+	if i := strings.Index(v, ";"); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// statusCode keeps only the enhanced status code, dropping trailing comments
+func statusCode(v string) string {
+	fields := strings.Fields(v)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func originalMessageID(headers []byte) string {
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(headers)))
+	h, err := tr.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return ""
+	}
+	return strings.TrimSpace(h.Get("Message-Id"))
+}
@@ -0,0 +1,99 @@
+package bounce
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		file      string
+		messageID string
+		want      RecipientStatus
+		failed    bool
+		hard      bool
+	}{
+		{
+			file:      "1700000001.M1P1.mx.hard",
+			messageID: "<hard-bounce@notifications.example.com>",
+			want: RecipientStatus{
+				FinalRecipient: "gone@example.org",
+				Action:         "failed",
+				Status:         "5.1.1",
+				DiagnosticCode: "550 5.1.1 <gone@example.org>: Recipient address rejected: User unknown in virtual mailbox table",
+			},
+			failed: true,
+			hard:   true,
+		},
+		{
+			// base64 delivery status, Message-ID only in In-Reply-To
+			file:      "1700000002.M2P1.mx.soft",
+			messageID: "<soft-bounce@notifications.example.com>",
+			want: RecipientStatus{
+				FinalRecipient: "full@example.net",
+				Action:         "failed",
+				Status:         "4.2.2",
+				DiagnosticCode: "452 4.2.2 Mailbox full",
+			},
+			failed: true,
+		},
+		{
+			file:      "1700000003.M3P1.mx.delayed",
+			messageID: "<delayed@notifications.example.com>",
+			want: RecipientStatus{
+				FinalRecipient: "slow@example.com",
+				Action:         "delayed",
+				Status:         "4.4.1",
+				DiagnosticCode: "connect to mail.example.com[192.0.2.1]:25: Connection timed out",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dsn := parseFixture(t, tt.file)
+			if dsn.OriginalMessageID != tt.messageID {
+				t.Errorf("OriginalMessageID = %q, want %q", dsn.OriginalMessageID, tt.messageID)
+			}
+			if len(dsn.Recipients) != 1 {
+				t.Fatalf("got %d recipients, want 1", len(dsn.Recipients))
+			}
+			got := dsn.Recipients[0]
+			if got != tt.want {
+				t.Errorf("recipient = %+v, want %+v", got, tt.want)
+			}
+			if got.Failed() != tt.failed || got.Hard() != tt.hard {
+				t.Errorf("Failed() = %v, Hard() = %v, want %v, %v", got.Failed(), got.Hard(), tt.failed, tt.hard)
+			}
+		})
+	}
+}
+
+func TestParseDSNRejectsOtherMessages(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "maildir", "new", "1700000004.M4P1.mx.plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := ParseDSN(f); !errors.Is(err, ErrNotDSN) {
+		t.Fatalf("ParseDSN() error = %v, want ErrNotDSN", err)
+	}
+}
+
+func parseFixture(t *testing.T, name string) *DSN {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "maildir", "new", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dsn, err := ParseDSN(f)
+	if err != nil {
+		t.Fatalf("ParseDSN() error = %v", err)
+	}
+	return dsn
+}
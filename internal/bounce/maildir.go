@@ -0,0 +1,51 @@
+package bounce
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Maildir reads new messages from a maildir and moves handled ones to cur
+type Maildir struct {
+	dir string
+}
+
+func NewMaildir(dir string) *Maildir {
+	return &Maildir{dir: dir}
+}
+
+// NewMessages returns the names of unread messages, oldest first
+func (m *Maildir) NewMessages() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dir, "new"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maildir: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	// Maildir names start with the delivery timestamp
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *Maildir) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(m.dir, "new", name))
+}
+
+// MarkSeen moves a message to cur with the Seen flag so it is not read again
+func (m *Maildir) MarkSeen(name string) error {
+	from := filepath.Join(m.dir, "new", name)
+	to := filepath.Join(m.dir, "cur", name+":2,S")
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to move message to cur: %w", err)
+	}
+	return nil
+}
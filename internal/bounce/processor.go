@@ -0,0 +1,129 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EmailRepository defines the interface for updating email logs with bounces
+type EmailRepository interface {
+	MarkBounced(ctx context.Context, messageID, diagnostic string) (bool, error)
+}
+
+// SuppressionRepository defines the interface for suppressing hard-bounced addresses
+type SuppressionRepository interface {
+	Add(ctx context.Context, s domain.SuppressedRecipient) error
+}
+
+// Processor reads DSNs from a maildir, marks the original emails as bounced
+// and suppresses addresses that bounced permanently
+type Processor struct {
+	maildir      *Maildir
+	emails       EmailRepository
+	suppressions SuppressionRepository
+	interval     time.Duration
+}
+
+func NewProcessor(maildir *Maildir, emails EmailRepository, suppressions SuppressionRepository, interval time.Duration) *Processor {
+	return &Processor{maildir: maildir, emails: emails, suppressions: suppressions, interval: interval}
+}
+
+// Run polls the maildir until ctx is cancelled
+func (p *Processor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.ProcessNew(ctx); err != nil {
+			log.WithError(err).Error("Failed to process bounces")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Bounce processor stopping due to context cancellation")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNew handles every unread message once. Messages that fail because
+// of a database error stay unread and are retried on the next run.
+func (p *Processor) ProcessNew(ctx context.Context) error {
+	names, err := p.maildir.NewMessages()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := p.processMessage(ctx, name); err != nil {
+			return fmt.Errorf("message %s: %w", name, err)
+		}
+		if err := p.maildir.MarkSeen(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Processor) processMessage(ctx context.Context, name string) error {
+	f, err := p.maildir.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dsn, err := ParseDSN(f)
+	if errors.Is(err, ErrNotDSN) {
+		log.WithField("message", name).Debug("Skipping message that is not a DSN")
+		return nil
+	}
+	if err != nil {
+		log.WithError(err).WithField("message", name).Warn("Skipping malformed DSN")
+		return nil
+	}
+
+	for _, rcpt := range dsn.Recipients {
+		if !rcpt.Failed() {
+			continue
+		}
+
+		fields := log.Fields{
+			"message_id": dsn.OriginalMessageID,
+			"status":     rcpt.Status,
+			"hard":       rcpt.Hard(),
+		}
+
+		if dsn.OriginalMessageID != "" {
+			diagnostic := rcpt.DiagnosticCode
+			if diagnostic == "" {
+				diagnostic = rcpt.Status
+			}
+			found, err := p.emails.MarkBounced(ctx, dsn.OriginalMessageID, diagnostic)
+			if err != nil {
+				return err
+			}
+			if !found {
+				log.WithFields(fields).Warn("Bounce does not match any email log")
+			}
+		}
+
+		if rcpt.Hard() && rcpt.FinalRecipient != "" {
+			err := p.suppressions.Add(ctx, domain.SuppressedRecipient{
+				Address: rcpt.FinalRecipient,
+				Reason:  fmt.Sprintf("hard bounce %s: %s", rcpt.Status, rcpt.DiagnosticCode),
+				Source:  domain.SuppressionBounce,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		log.WithFields(fields).Info("Bounce processed")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"notification-service/internal/domain"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeEmails struct {
+	err     error
+	bounced map[string]string
+}
+
+func (f *fakeEmails) MarkBounced(ctx context.Context, messageID, diagnostic string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	f.bounced[messageID] = diagnostic
+	return true, nil
+}
+
+type fakeSuppressions struct {
+	added []domain.SuppressedRecipient
+}
+
+func (f *fakeSuppressions) Add(ctx context.Context, s domain.SuppressedRecipient) error {
+	f.added = append(f.added, s)
+	return nil
+}
+
+// copyMaildir copies the fixture maildir so processing does not move the fixtures
+func copyMaildir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := filepath.Join("testdata", "maildir", "new")
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "new", e.Name()), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestProcessorProcessNew(t *testing.T) {
+	dir := copyMaildir(t)
+	emails := &fakeEmails{bounced: map[string]string{}}
+	suppressions := &fakeSuppressions{}
+	p := NewProcessor(NewMaildir(dir), emails, suppressions, time.Minute)
+
+	if err := p.ProcessNew(context.Background()); err != nil {
+		t.Fatalf("ProcessNew() error = %v", err)
+	}
+
+	// Soft and hard failures are both recorded, delays are not
+	wantBounced := map[string]string{
+		"<hard-bounce@notifications.example.com>": "550 5.1.1 <gone@example.org>: Recipient address rejected: User unknown in virtual mailbox table",
+		"<soft-bounce@notifications.example.com>": "452 4.2.2 Mailbox full",
+	}
+	if !reflect.DeepEqual(emails.bounced, wantBounced) {
+		t.Errorf("bounced = %v, want %v", emails.bounced, wantBounced)
+	}
+
+	// Only the hard bounce suppresses its address
+	if len(suppressions.added) != 1 {
+		t.Fatalf("got %d suppressions, want 1: %+v", len(suppressions.added), suppressions.added)
+	}
+	s := suppressions.added[0]
+	if s.Address != "gone@example.org" || s.Source != domain.SuppressionBounce || !strings.HasPrefix(s.Reason, "hard bounce 5.1.1") {
+		t.Errorf("suppression = %+v", s)
+	}
+
+	if got := dirNames(t, filepath.Join(dir, "new")); len(got) != 0 {
+		t.Errorf("new = %v, want empty", got)
+	}
+	wantSeen := []string{
+		"1700000001.M1P1.mx.hard:2,S",
+		"1700000002.M2P1.mx.soft:2,S",
+		"1700000003.M3P1.mx.delayed:2,S",
+		"1700000004.M4P1.mx.plain:2,S",
+	}
+	if got := dirNames(t, filepath.Join(dir, "cur")); !reflect.DeepEqual(got, wantSeen) {
+		t.Errorf("cur = %v, want %v", got, wantSeen)
+	}
+}
+
+func TestProcessorKeepsMessagesUnreadOnDatabaseError(t *testing.T) {
+	dir := copyMaildir(t)
+	emails := &fakeEmails{err: errors.New("connection refused"), bounced: map[string]string{}}
+	p := NewProcessor(NewMaildir(dir), emails, &fakeSuppressions{}, time.Minute)
+
+	if err := p.ProcessNew(context.Background()); err == nil {
+		t.Fatal("ProcessNew() error = nil, want the database error")
+	}
+	if got := dirNames(t, filepath.Join(dir, "new")); len(got) != 4 {
+		t.Errorf("new = %v, want every message left unread", got)
+	}
+
+	emails.err = nil
+	if err := p.ProcessNew(context.Background()); err != nil {
+		t.Fatalf("ProcessNew() error = %v", err)
+	}
+	if len(emails.bounced) != 2 {
+		t.Errorf("bounced = %v, want both failures after the retry", emails.bounced)
+	}
+}
@@ -0,0 +1,222 @@
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SpecVersion = "1.0"
+	// ContentType marks a message or request body holding a structured mode event
+	ContentType = "application/cloudevents+json"
+
+	// headerPrefix starts the attribute headers of a binary mode Kafka message
+	headerPrefix      = "ce_"
+	contentTypeHeader = "content-type"
+)
+
+var ErrInvalidEvent = errors.New("invalid CloudEvent")
+
+// Event is a CloudEvents 1.0 event. Data holds the raw data, whatever its
+// content type.
+type Event struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	Data            []byte
+}
+
+// structured is the JSON format of an event; binary data travels as data_base64
+type structured struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+}
+
+// idNamespace derives event IDs from what identifies an event
+var idNamespace = uuid.MustParse("5b1f3c1e-8d0a-4f7e-9a51-2c6e0b7d4f93")
+
+// NewID derives an event ID from the parts identifying the event, so an
+// event published again keeps its ID and consumers can drop the duplicate
+func NewID(parts ...string) string {
+	return uuid.NewSHA1(idNamespace, []byte(strings.Join(parts, "\x00"))).String()
+}
+
+// New builds an event with the given ID whose data is the JSON encoding of data
+func New(id, eventType, source string, data any) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode event data: %w", err)
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              id,
+		Source:          source,
+		Type:            eventType,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            encoded,
+	}, nil
+}
+
+// Validate checks the required attributes
+func (e Event) Validate() error {
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf("%w: unsupported specversion %q", ErrInvalidEvent, e.SpecVersion)
+	}
+	for _, attr := range [][2]string{{"id", e.ID}, {"source", e.Source}, {"type", e.Type}} {
+		if attr[1] == "" {
+			return fmt.Errorf("%w: missing attribute %q", ErrInvalidEvent, attr[0])
+		}
+	}
+	return nil
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	s := structured{
+		SpecVersion:     e.SpecVersion,
+		ID:              e.ID,
+		Source:          e.Source,
+		Type:            e.Type,
+		Subject:         e.Subject,
+		DataContentType: e.DataContentType,
+		DataSchema:      e.DataSchema,
+	}
+	if !e.Time.IsZero() {
+		s.Time = e.Time.Format(time.RFC3339Nano)
+	}
+	if len(e.Data) > 0 {
+		if isJSON(e.DataContentType) && json.Valid(e.Data) {
+			s.Data = e.Data
+		} else {
+			s.DataBase64 = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(s)
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var s structured
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*e = Event{
+		SpecVersion:     s.SpecVersion,
+		ID:              s.ID,
+		Source:          s.Source,
+		Type:            s.Type,
+		Subject:         s.Subject,
+		DataContentType: s.DataContentType,
+		DataSchema:      s.DataSchema,
+	}
+	if s.Time != "" {
+		t, err := time.Parse(time.RFC3339Nano, s.Time)
+		if err != nil {
+			return fmt.Errorf("%w: invalid time %q", ErrInvalidEvent, s.Time)
+		}
+		e.Time = t
+	}
+	switch {
+	case s.DataBase64 != "":
+		data, err := base64.StdEncoding.DecodeString(s.DataBase64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid data_base64: %v", ErrInvalidEvent, err)
+		}
+		e.Data = data
+	case len(s.Data) > 0 && string(s.Data) != "null":
+		// A JSON string is the data itself unless the data is JSON
+		var text string
+		if !isJSON(s.DataContentType) && json.Unmarshal(s.Data, &text) == nil {
+			e.Data = []byte(text)
+		} else {
+			e.Data = s.Data
+		}
+	}
+	return nil
+}
+
+// Parse reads an event from a Kafka message in binary mode, with the
+// attributes in ce_* headers, or in structured mode. headers maps lower-case
+// header names to values. ok is false for a plain payload that is not a
+// CloudEvent.
+func Parse(value []byte, headers map[string]string) (event Event, ok bool, err error) {
+	if specVersion, binary := headers[headerPrefix+"specversion"]; binary {
+		event = Event{
+			SpecVersion:     specVersion,
+			ID:              headers[headerPrefix+"id"],
+			Source:          headers[headerPrefix+"source"],
+			Type:            headers[headerPrefix+"type"],
+			Subject:         headers[headerPrefix+"subject"],
+			DataContentType: headers[contentTypeHeader],
+			DataSchema:      headers[headerPrefix+"dataschema"],
+			Data:            value,
+		}
+		if t := headers[headerPrefix+"time"]; t != "" {
+			if event.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+				return Event{}, true, fmt.Errorf("%w: invalid time %q", ErrInvalidEvent, t)
+			}
+		}
+		return event, true, event.Validate()
+	}
+
+	if !isStructured(value, headers[contentTypeHeader]) {
+		return Event{}, false, nil
+	}
+	if err := json.Unmarshal(value, &event); err != nil {
+		if errors.Is(err, ErrInvalidEvent) {
+			return Event{}, true, err
+		}
+		return Event{}, true, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	return event, true, event.Validate()
+}
+
+// isStructured reports whether value is a structured mode event. Producers
+// do not always set the content type, so JSON objects with a specversion
+// attribute count as well.
+func isStructured(value []byte, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == ContentType {
+		return true
+	}
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+	var probe struct {
+		SpecVersion *string `json:"specversion"`
+	}
+	return json.Unmarshal(trimmed, &probe) == nil && probe.SpecVersion != nil
+}
+
+// isJSON reports whether data of the content type is JSON; data without a
+// content type is JSON too
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -14,8 +14,225 @@ type DB struct {
 	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" envDefault:"15m"`
 }
 
+type SMS struct {
+	// Provider selects the SMS implementation: "http", "fake" or empty to disable SMS
+	Provider   string        `env:"SMS_PROVIDER"`
+	GatewayURL string        `env:"SMS_GATEWAY_URL"`
+	APIKey     string        `env:"SMS_GATEWAY_API_KEY"`
+	From       string        `env:"SMS_FROM"`
+	Timeout    time.Duration `env:"SMS_GATEWAY_TIMEOUT" envDefault:"5s"`
+}
+
+type Push struct {
+	// Provider selects the push implementation: "http", "fake" or empty to disable push
+	Provider    string        `env:"PUSH_PROVIDER"`
+	ProviderURL string        `env:"PUSH_PROVIDER_URL"`
+	APIKey      string        `env:"PUSH_API_KEY"`
+	Timeout     time.Duration `env:"PUSH_TIMEOUT" envDefault:"5s"`
+}
+
+type Webhook struct {
+	Enabled        bool          `env:"WEBHOOKS_ENABLED" envDefault:"false"`
+	MaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"5"`
+	BaseDelay      time.Duration `env:"WEBHOOK_BASE_DELAY" envDefault:"1s"`
+	MaxDelay       time.Duration `env:"WEBHOOK_MAX_DELAY" envDefault:"1m"`
+	RequestTimeout time.Duration `env:"WEBHOOK_REQUEST_TIMEOUT" envDefault:"10s"`
+	DisableAfter   int           `env:"WEBHOOK_DISABLE_AFTER" envDefault:"20"`
+	Workers        int           `env:"WEBHOOK_WORKERS" envDefault:"4"`
+	QueueSize      int           `env:"WEBHOOK_QUEUE_SIZE" envDefault:"1000"`
+}
+
+type Alert struct {
+	// WebhookURL is the incoming webhook of the team chat; alerts are disabled when empty
+	WebhookURL string `env:"ALERT_WEBHOOK_URL"`
+	// Format is "json" for a generic payload or "slack" for Slack-compatible chats
+	Format  string        `env:"ALERT_WEBHOOK_FORMAT" envDefault:"slack"`
+	Timeout time.Duration `env:"ALERT_WEBHOOK_TIMEOUT" envDefault:"5s"`
+
+	// HighValueCoins fires an alert for purchases above this number of coins; 0 disables the rule
+	HighValueCoins    int           `env:"ALERT_HIGH_VALUE_COINS" envDefault:"0"`
+	HighValueCooldown time.Duration `env:"ALERT_HIGH_VALUE_COOLDOWN" envDefault:"1m"`
+
+	// RefundSpikeThreshold fires an alert when a provider exceeds this many refunds within RefundSpikeWindow; 0 disables the rule
+	RefundSpikeThreshold int           `env:"ALERT_REFUND_SPIKE_THRESHOLD" envDefault:"0"`
+	RefundSpikeWindow    time.Duration `env:"ALERT_REFUND_SPIKE_WINDOW" envDefault:"10m"`
+	RefundSpikeCooldown  time.Duration `env:"ALERT_REFUND_SPIKE_COOLDOWN" envDefault:"30m"`
+}
+
+type Preferences struct {
+	// MandatoryEvents are event types sent even to users who opted out of their category
+	MandatoryEvents []string `env:"MANDATORY_EVENT_TYPES" envSeparator:","`
+}
+
+type Bounce struct {
+	// Maildir receives the DSNs sent to our envelope sender; bounce processing is disabled when empty
+	Maildir      string        `env:"BOUNCE_MAILDIR"`
+	PollInterval time.Duration `env:"BOUNCE_POLL_INTERVAL" envDefault:"1m"`
+}
+
+type DeliveryEvents struct {
+	// MailgunSigningKey enables POST /webhooks/delivery-events/mailgun
+	MailgunSigningKey string `env:"DELIVERY_EVENTS_MAILGUN_SIGNING_KEY"`
+	// GenericSecret enables POST /webhooks/delivery-events/generic
+	GenericSecret string `env:"DELIVERY_EVENTS_GENERIC_SECRET"`
+	// MaxAge rejects callbacks whose signed timestamp is older than this
+	MaxAge time.Duration `env:"DELIVERY_EVENTS_MAX_AGE" envDefault:"5m"`
+}
+
+type Audit struct {
+	// StoreBodies keeps the rendered message of every email, SMS and push notification
+	StoreBodies bool `env:"STORE_EMAIL_BODIES" envDefault:"true"`
+	// BodyRetention deletes stored bodies after this long; 0 keeps them forever
+	BodyRetention time.Duration `env:"EMAIL_BODY_RETENTION" envDefault:"0"`
+}
+
+type Retention struct {
+	Enabled bool `env:"RETENTION_ENABLED" envDefault:"false"`
+	// TTLs has the form "sent=2160h,failed=4320h"; statuses not listed are kept forever
+	TTLs       string        `env:"RETENTION_TTLS" envDefault:"sent=2160h,suppressed=2160h,delivered=2160h,opened=2160h,clicked=2160h,failed=4320h,bounced=4320h,complained=4320h"`
+	Interval   time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h"`
+	BatchSize  int           `env:"RETENTION_BATCH_SIZE" envDefault:"1000"`
+	BatchPause time.Duration `env:"RETENTION_BATCH_PAUSE" envDefault:"100ms"`
+	ArchiveDir string        `env:"RETENTION_ARCHIVE_DIR"`
+}
+
+type Partitions struct {
+	Enabled     bool          `env:"PARTITION_MAINTENANCE_ENABLED" envDefault:"true"`
+	Interval    time.Duration `env:"PARTITION_MAINTENANCE_INTERVAL" envDefault:"24h"`
+	MonthsAhead int           `env:"PARTITION_MONTHS_AHEAD" envDefault:"3"`
+	// Retention drops whole monthly partitions older than this; 0 keeps them
+	Retention time.Duration `env:"PARTITION_RETENTION" envDefault:"0"`
+}
+
+type EmailLogWriter struct {
+	// Batched queues email logs and inserts them in batches; when false every log is inserted synchronously
+	Batched       bool          `env:"EMAIL_LOG_BATCHED" envDefault:"true"`
+	BatchSize     int           `env:"EMAIL_LOG_BATCH_SIZE" envDefault:"500"`
+	FlushInterval time.Duration `env:"EMAIL_LOG_FLUSH_INTERVAL" envDefault:"1s"`
+	// BufferSize is the number of queued logs after which message handling blocks
+	BufferSize int           `env:"EMAIL_LOG_BUFFER_SIZE" envDefault:"5000"`
+	RetryDelay time.Duration `env:"EMAIL_LOG_RETRY_DELAY" envDefault:"1s"`
+}
+
+type Erasure struct {
+	// PseudonymKey keys the hash that replaces erased recipients; keep it secret and stable
+	PseudonymKey string `env:"ERASURE_PSEUDONYM_KEY"`
+}
+
+type Encryption struct {
+	// MasterKey is a base64 AES-256 key wrapping the data keys; recipients are stored in plaintext when it and KeyFile are empty
+	MasterKey string `env:"PII_MASTER_KEY"`
+	// KeyFile holds a master key generated on first start, for local development
+	KeyFile string `env:"PII_KEY_FILE"`
+	// PreviousMasterKeys unwrap data keys until rotate-keys rewraps them under MasterKey
+	PreviousMasterKeys []string `env:"PII_PREVIOUS_MASTER_KEYS" envSeparator:","`
+}
+
+type Events struct {
+	// DisallowUnknownFields rejects event payloads with fields the event type does not define
+	DisallowUnknownFields bool `env:"EVENT_DISALLOW_UNKNOWN_FIELDS" envDefault:"false"`
+	// DeadLetters stores messages the consumer gives up on in the dead_letters table; they are only logged otherwise
+	DeadLetters bool `env:"DEAD_LETTERS_ENABLED" envDefault:"true"`
+	// CloudEventTopics carry CloudEvents of any event type, dispatched on their type attribute
+	CloudEventTopics []string `env:"CLOUDEVENTS_TOPICS" envSeparator:","`
+	// Source is the CloudEvents source attribute of the events the service publishes
+	Source string `env:"CLOUDEVENTS_SOURCE" envDefault:"/notification-service"`
+}
+
+type Source struct {
+	// Kind selects the broker events are consumed from: "kafka", "jetstream" or "redis"
+	Kind string `env:"MESSAGE_SOURCE" envDefault:"kafka"`
+	// MaxDeliver is how often JetStream and Redis deliver a failing message before dead-lettering it
+	MaxDeliver int `env:"MESSAGE_MAX_DELIVER" envDefault:"5"`
+	Batch      int `env:"MESSAGE_BATCH_SIZE" envDefault:"64"`
+
+	// NATSURL may use tls:// to connect over TLS
+	NATSURL     string        `env:"NATS_URL" envDefault:"nats://localhost:4222"`
+	NATSStream  string        `env:"NATS_STREAM" envDefault:"BILLING"`
+	NATSDurable string        `env:"NATS_DURABLE" envDefault:"notification_service"`
+	NATSAckWait time.Duration `env:"NATS_ACK_WAIT" envDefault:"30s"`
+
+	RedisAddr     string `env:"REDIS_ADDR" envDefault:"localhost:6379"`
+	RedisPassword string `env:"REDIS_PASSWORD"`
+	RedisDB       int    `env:"REDIS_DB" envDefault:"0"`
+	RedisTLS      bool   `env:"REDIS_TLS" envDefault:"false"`
+	RedisGroup    string `env:"REDIS_GROUP" envDefault:"notification_service"`
+	// RedisConsumer must be unique per instance and stable across restarts; defaults to the host name
+	RedisConsumer  string        `env:"REDIS_CONSUMER"`
+	RedisClaimIdle time.Duration `env:"REDIS_CLAIM_IDLE" envDefault:"30s"`
+}
+
+type StatusEvents struct {
+	// Topic receives a notification_status event after every outcome of a notification; nothing is published when empty
+	Topic string `env:"NOTIFICATION_STATUS_TOPIC"`
+	// FlushTimeout bounds the wait for outstanding delivery reports on shutdown
+	FlushTimeout time.Duration `env:"NOTIFICATION_STATUS_FLUSH_TIMEOUT" envDefault:"5s"`
+}
+
+type SchemaRegistry struct {
+	// URL of a Confluent compatible registry; wire-format payloads are rejected when it and Dir are empty
+	URL      string        `env:"SCHEMA_REGISTRY_URL"`
+	Username string        `env:"SCHEMA_REGISTRY_USERNAME"`
+	Password string        `env:"SCHEMA_REGISTRY_PASSWORD"`
+	Timeout  time.Duration `env:"SCHEMA_REGISTRY_TIMEOUT" envDefault:"5s"`
+	// Dir holds schemas as <id>.json files instead of a registry, for local development
+	Dir string `env:"SCHEMA_REGISTRY_DIR"`
+}
+
+type FlowControl struct {
+	// Enabled pauses the consumer while SMTP or Postgres is unhealthy instead of failing every message
+	Enabled bool `env:"FLOW_CONTROL_ENABLED" envDefault:"true"`
+	// CheckInterval is how often the dependencies are probed, paused or not
+	CheckInterval time.Duration `env:"FLOW_CONTROL_CHECK_INTERVAL" envDefault:"10s"`
+	// SMTPFailureThreshold opens the SMTP circuit after this many consecutive failed sends
+	SMTPFailureThreshold int `env:"SMTP_FAILURE_THRESHOLD" envDefault:"3"`
+}
+
+type Retry struct {
+	// MaxAttempts, BaseDelay, MaxDelay and AttemptTimeout are the defaults of every event type and channel
+	MaxAttempts int           `env:"RETRY_MAX_ATTEMPTS" envDefault:"3"`
+	BaseDelay   time.Duration `env:"RETRY_BASE_DELAY" envDefault:"1s"`
+	MaxDelay    time.Duration `env:"RETRY_MAX_DELAY" envDefault:"30s"`
+	// AttemptTimeout bounds every single send; 0 means 10s
+	AttemptTimeout time.Duration `env:"RETRY_ATTEMPT_TIMEOUT" envDefault:"10s"`
+	// Policies has the form "*:email=attempts:5,timeout:10s;refund:sms=attempts:2,base:500ms,max:5s"
+	Policies string `env:"RETRY_POLICIES"`
+}
+
+type Admin struct {
+	Addr string `env:"ADMIN_ADDR" envDefault:":8081"`
+	// Token is required: the admin API exposes recipients, message bodies and erasure
+	Token string `env:"ADMIN_TOKEN"`
+}
+
+type Routing struct {
+	// Rules has the form "purchase:KZ,UZ:sms;purchase:*:email+push;refund:*:email"
+	Rules string `env:"NOTIFICATION_ROUTING_RULES"`
+}
+
 type Config struct {
-	DB DB
+	DB             DB
+	SMS            SMS
+	Push           Push
+	Routing        Routing
+	Webhook        Webhook
+	Alert          Alert
+	Preferences    Preferences
+	Bounce         Bounce
+	DeliveryEvents DeliveryEvents
+	Audit          Audit
+	Retention      Retention
+	Partitions     Partitions
+	EmailLogWriter EmailLogWriter
+	Erasure        Erasure
+	Encryption     Encryption
+	Events         Events
+	Source         Source
+	StatusEvents   StatusEvents
+	SchemaRegistry SchemaRegistry
+	FlowControl    FlowControl
+	Retry          Retry
+	Admin          Admin
 }
 
 func Load() (*Config, error) {
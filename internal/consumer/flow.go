@@ -0,0 +1,264 @@
+package consumer
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// checkTimeout bounds a single run of a health check
+const checkTimeout = 5 * time.Second
+
+// maxFlowTransitions is how many transitions FlowStatus keeps
+const maxFlowTransitions = 50
+
+// HealthCheck probes a downstream dependency the handlers need
+type HealthCheck struct {
+	Name string
+	// Check returns nil while the dependency is available
+	Check func(ctx context.Context) error
+}
+
+type FlowState string
+
+const (
+	FlowRunning FlowState = "running"
+	FlowPaused  FlowState = "paused"
+)
+
+// FlowTransition records the consumer pausing or resuming
+type FlowTransition struct {
+	From FlowState `json:"from"`
+	To   FlowState `json:"to"`
+	// Dependency is the failing check that paused the consumer; empty on resume
+	Dependency string    `json:"dependency,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// FlowStatus is the state of the health-aware flow control
+type FlowStatus struct {
+	State FlowState `json:"state"`
+	Since time.Time `json:"since"`
+	// Waiting is the number of messages kept for handling after the resume
+	Waiting     int              `json:"waiting"`
+	Transitions []FlowTransition `json:"transitions"`
+}
+
+// flowController pauses the consumer while a dependency is unhealthy. It is
+// only used from the consumer's goroutine, except for what mu guards, which
+// status reads.
+type flowController struct {
+	checks    []HealthCheck
+	interval  time.Duration
+	nextCheck time.Time
+
+	mu          sync.Mutex
+	state       FlowState
+	since       time.Time
+	transitions []FlowTransition
+	// waiting holds the messages taken from the source while paused, in
+	// the order they are handled after the resume
+	waiting []*Message
+}
+
+// PauseWhenUnhealthy makes the consumer run checks every interval and after
+// every message that failed with an error that is not permanent. While a
+// check fails, every assigned partition is paused and the failed message is
+// kept, so the consumer does not burn through messages it cannot handle. It
+// resumes once every check passes and handles the kept messages first, in
+// order. Sources that can redeliver single messages get them back instead.
+//
+// It must be called before Start.
+func (c *KafkaConsumer) PauseWhenUnhealthy(interval time.Duration, checks ...HealthCheck) {
+	c.flow = &flowController{
+		checks:   checks,
+		interval: interval,
+		state:    FlowRunning,
+		since:    time.Now(),
+	}
+}
+
+// FlowStatus returns the state of the flow control and its recent
+// transitions; ok is false when PauseWhenUnhealthy was not called
+func (c *KafkaConsumer) FlowStatus() (status FlowStatus, ok bool) {
+	if c.flow == nil {
+		return FlowStatus{}, false
+	}
+	return c.flow.status(), true
+}
+
+func (f *flowController) status() FlowStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return FlowStatus{
+		State:       f.state,
+		Since:       f.since,
+		Waiting:     len(f.waiting),
+		Transitions: slices.Clone(f.transitions),
+	}
+}
+
+func (f *flowController) paused() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state == FlowPaused
+}
+
+// wait keeps msg for the resume, first if front is set
+func (f *flowController) wait(msg *Message, front bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if front {
+		f.waiting = slices.Insert(f.waiting, 0, msg)
+	} else {
+		f.waiting = append(f.waiting, msg)
+	}
+}
+
+// next takes the first kept message, if any
+func (f *flowController) next() *Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.waiting) == 0 {
+		return nil
+	}
+	msg := f.waiting[0]
+	f.waiting = slices.Delete(f.waiting, 0, 1)
+	return msg
+}
+
+// forget drops the kept messages of revoked partitions
+func (f *flowController) forget(partitions []TopicPartition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.waiting = slices.DeleteFunc(f.waiting, func(msg *Message) bool {
+		return slices.Contains(partitions, msg.TopicPartition())
+	})
+}
+
+func (f *flowController) waitingCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiting)
+}
+
+// check runs every health check and returns the first failing one
+func (f *flowController) check(ctx context.Context) (string, error) {
+	f.nextCheck = time.Now().Add(f.interval)
+	for _, hc := range f.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := hc.Check(checkCtx)
+		cancel()
+		if err != nil {
+			return hc.Name, err
+		}
+	}
+	return "", nil
+}
+
+func (f *flowController) transition(to FlowState, dependency string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := FlowTransition{From: f.state, To: to, Dependency: dependency, At: time.Now()}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	f.state = to
+	f.since = t.At
+	f.transitions = append(f.transitions, t)
+	if len(f.transitions) > maxFlowTransitions {
+		f.transitions = slices.Delete(f.transitions, 0, len(f.transitions)-maxFlowTransitions)
+	}
+}
+
+// control runs the checks when they are due, pausing or resuming the consumer
+func (c *KafkaConsumer) control(ctx context.Context) {
+	f := c.flow
+	if time.Now().Before(f.nextCheck) {
+		return
+	}
+	dependency, err := f.check(ctx)
+	switch {
+	case err != nil && !f.paused():
+		c.pauseFlow(dependency, err)
+	case err != nil:
+		// Partitions assigned in a rebalance while paused start unpaused
+		c.pauseAssigned()
+		log.WithError(err).WithField("dependency", dependency).Debug("Dependency is still unhealthy")
+	case f.paused():
+		c.resumeFlow()
+	}
+}
+
+// holdIfUnhealthy runs the checks after msg failed and, if one fails, pauses
+// the consumer and keeps msg for the resume
+func (c *KafkaConsumer) holdIfUnhealthy(ctx context.Context, msg *Message) bool {
+	dependency, err := c.flow.check(ctx)
+	if err == nil {
+		return false
+	}
+	if !c.flow.paused() {
+		c.pauseFlow(dependency, err)
+	}
+	c.keep(msg, true)
+	return true
+}
+
+// keep holds on to a message taken from the source while paused; a message
+// that failed goes first, as it precedes everything polled after it
+func (c *KafkaConsumer) keep(msg *Message, failed bool) {
+	if redeliverer, ok := c.source.(Redeliverer); ok {
+		if err := redeliverer.Nack(msg); err != nil {
+			log.WithError(err).Warn("Failed to return message for redelivery")
+		}
+		return
+	}
+	if err := c.source.Pause([]TopicPartition{msg.TopicPartition()}); err != nil {
+		log.WithError(err).Error("Failed to pause partition")
+	}
+	c.flow.wait(msg, failed)
+}
+
+// nextWaiting returns the next kept message, if any
+func (c *KafkaConsumer) nextWaiting() *Message {
+	return c.flow.next()
+}
+
+func (c *KafkaConsumer) pauseFlow(dependency string, err error) {
+	log.WithError(err).WithField("dependency", dependency).Warn("Dependency is unhealthy, pausing consumer")
+	c.flow.transition(FlowPaused, dependency, err)
+	c.pauseAssigned()
+}
+
+func (c *KafkaConsumer) resumeFlow() {
+	partitions, err := c.source.Assignment()
+	if err != nil {
+		log.WithError(err).Error("Failed to get assigned partitions")
+		return
+	}
+	// Partitions held by their route's error policy stay paused until restart
+	c.mu.Lock()
+	partitions = slices.DeleteFunc(partitions, func(tp TopicPartition) bool { return c.held[tp] })
+	c.mu.Unlock()
+	if err := c.source.Resume(partitions); err != nil {
+		log.WithError(err).Error("Failed to resume partitions")
+		return
+	}
+	log.WithField("waiting", c.flow.waitingCount()).Info("Dependencies are healthy again, resuming consumer")
+	c.flow.transition(FlowRunning, "", nil)
+}
+
+func (c *KafkaConsumer) pauseAssigned() {
+	partitions, err := c.source.Assignment()
+	if err == nil {
+		err = c.source.Pause(partitions)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to pause assigned partitions")
+	}
+}
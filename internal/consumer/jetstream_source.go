@@ -0,0 +1,286 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	natsDialTimeout    = 5 * time.Second
+	natsRequestTimeout = 5 * time.Second
+)
+
+// JetStreamConfig configures a durable pull consumer on a JetStream stream.
+// Topics are the stream's subjects.
+type JetStreamConfig struct {
+	// URL has the form nats://[user:password@|token@]host[:port]; tls://
+	// connects over TLS, which is also used whenever the server requires it
+	URL     string
+	Stream  string
+	Durable string
+	// AckWait is how long the server waits for an ack before redelivering
+	AckWait time.Duration
+	// MaxDeliver is how often a message is delivered before it is dead-lettered
+	MaxDeliver int
+	// Batch is the number of messages requested per pull
+	Batch int
+}
+
+type jetStreamSource struct {
+	cfg  JetStreamConfig
+	conn *nats.Conn
+	js   jetstream.JetStream
+
+	mu       sync.Mutex
+	consumer jetstream.Consumer
+	closed   bool
+	subjects []string
+	paused   map[string]bool
+	buffered []*Message
+}
+
+// NewJetStreamSource connects to the NATS server, reconnecting whenever the
+// connection is lost; the durable consumer is created or updated on Subscribe
+func NewJetStreamSource(cfg JetStreamConfig) (*jetStreamSource, error) {
+	cfg.Batch = max(cfg.Batch, 1)
+
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name("notification-service"),
+		nats.Timeout(natsDialTimeout),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.WithError(err).Warn("Lost connection to NATS")
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.WithField("url", conn.ConnectedUrlRedacted()).Info("Reconnected to NATS")
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	log.WithField("url", conn.ConnectedUrlRedacted()).Info("Connected to NATS")
+
+	return &jetStreamSource{cfg: cfg, conn: conn, js: js, paused: make(map[string]bool)}, nil
+}
+
+func (s *jetStreamSource) Subscribe(topics []string) error {
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, "^") {
+			return fmt.Errorf("topic pattern %q is not supported by JetStream, use subject wildcards", topic)
+		}
+	}
+
+	config := jetstream.ConsumerConfig{
+		Durable:       s.cfg.Durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckWait:       s.cfg.AckWait,
+		// The server delivers without limit; LastDelivery enforces MaxDeliver,
+		// so a message is dead-lettered rather than dropped by the server
+		MaxDeliver: -1,
+	}
+	if len(topics) == 1 {
+		config.FilterSubject = topics[0]
+	} else {
+		config.FilterSubjects = topics
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsRequestTimeout)
+	defer cancel()
+	consumer, err := s.js.CreateOrUpdateConsumer(ctx, s.cfg.Stream, config)
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream consumer: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subjects = topics
+	s.consumer = consumer
+	s.buffered = nil
+	return nil
+}
+
+func (s *jetStreamSource) Poll(timeout time.Duration) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSourceClosed
+	}
+	if msg := s.next(); msg != nil {
+		return msg, nil
+	}
+	// Nothing is pulled while every subject is paused
+	if s.consumer == nil || s.allPaused() {
+		time.Sleep(timeout)
+		return nil, nil
+	}
+	if err := s.fill(timeout); err != nil {
+		log.WithError(err).Warn("Failed to pull JetStream messages, retrying...")
+		time.Sleep(timeout)
+		return nil, nil
+	}
+	return s.next(), nil
+}
+
+// next takes the first buffered message of a subject that is not paused; s.mu must be held
+func (s *jetStreamSource) next() *Message {
+	for i, msg := range s.buffered {
+		if !s.paused[msg.Topic] {
+			s.buffered = slices.Delete(s.buffered, i, i+1)
+			return msg
+		}
+	}
+	return nil
+}
+
+// fill buffers the messages the server has ready, or else waits up to
+// timeout for the next one; s.mu must be held
+func (s *jetStreamSource) fill(timeout time.Duration) error {
+	batch, err := s.consumer.FetchNoWait(s.cfg.Batch)
+	if err != nil {
+		return err
+	}
+	if s.take(batch) > 0 || batch.Error() != nil {
+		return batch.Error()
+	}
+
+	batch, err = s.consumer.Fetch(1, jetstream.FetchMaxWait(timeout))
+	if err != nil {
+		return err
+	}
+	s.take(batch)
+	return batch.Error()
+}
+
+// take buffers the messages of batch and returns how many it kept; s.mu must be held
+func (s *jetStreamSource) take(batch jetstream.MessageBatch) int {
+	kept := 0
+	for m := range batch.Messages() {
+		if msg := s.received(m); msg != nil {
+			s.buffered = append(s.buffered, msg)
+			kept++
+		}
+	}
+	return kept
+}
+
+// received converts m, or hands it back when its subject is paused; s.mu must be held
+func (s *jetStreamSource) received(m jetstream.Msg) *Message {
+	meta, err := m.Metadata()
+	if err != nil {
+		log.WithError(err).WithField("subject", m.Subject()).Warn("Ignoring JetStream message without metadata")
+		return nil
+	}
+	if s.paused[m.Subject()] {
+		// It comes again once the subject is resumed
+		if err := m.NakWithDelay(s.cfg.AckWait); err != nil {
+			log.WithError(err).Warn("Failed to return JetStream message of paused subject")
+		}
+		return nil
+	}
+
+	msg := &Message{
+		Topic:      m.Subject(),
+		Offset:     int64(meta.Sequence.Stream),
+		Value:      m.Data(),
+		Timestamp:  meta.Timestamp,
+		receipt:    m,
+		deliveries: int(meta.NumDelivered),
+	}
+	headers := m.Headers()
+	for _, key := range slices.Sorted(maps.Keys(headers)) {
+		for _, value := range headers[key] {
+			msg.Headers = append(msg.Headers, Header{Key: key, Value: []byte(value)})
+		}
+	}
+	return msg
+}
+
+// Commit acks msg
+func (s *jetStreamSource) Commit(msg *Message) error {
+	m, ok := msg.receipt.(jetstream.Msg)
+	if !ok {
+		return fmt.Errorf("not a JetStream message: %s@%d", msg.Topic, msg.Offset)
+	}
+	return m.Ack()
+}
+
+// Nack asks the server to redeliver msg now
+func (s *jetStreamSource) Nack(msg *Message) error {
+	m, ok := msg.receipt.(jetstream.Msg)
+	if !ok {
+		return fmt.Errorf("not a JetStream message: %s@%d", msg.Topic, msg.Offset)
+	}
+	return m.Nak()
+}
+
+// LastDelivery reports whether msg was delivered MaxDeliver times
+func (s *jetStreamSource) LastDelivery(msg *Message) bool {
+	return s.cfg.MaxDeliver > 0 && msg.deliveries >= s.cfg.MaxDeliver
+}
+
+// Assignment returns every subscribed subject as partition 0
+func (s *jetStreamSource) Assignment() ([]TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return subjectPartitions(s.subjects), nil
+}
+
+func (s *jetStreamSource) Pause(partitions []TopicPartition) error {
+	s.setPaused(partitions, true)
+	return nil
+}
+
+func (s *jetStreamSource) Resume(partitions []TopicPartition) error {
+	s.setPaused(partitions, false)
+	return nil
+}
+
+func (s *jetStreamSource) setPaused(partitions []TopicPartition, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		if paused {
+			s.paused[tp.Topic] = true
+		} else {
+			delete(s.paused, tp.Topic)
+		}
+	}
+}
+
+// allPaused reports whether every subscribed subject is paused; s.mu must be held
+func (s *jetStreamSource) allPaused() bool {
+	for _, subject := range s.subjects {
+		if !s.paused[subject] {
+			return false
+		}
+	}
+	return len(s.subjects) > 0
+}
+
+// Close drops the connection; unacked messages are redelivered after AckWait
+func (s *jetStreamSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.conn.Close()
+	return nil
+}
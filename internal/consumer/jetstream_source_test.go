@@ -0,0 +1,187 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// runJetStream starts an embedded NATS server with a BILLING stream on billing.>
+func runJetStream(t *testing.T) (string, jetstream.JetStream) {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "BILLING", Subjects: []string{"billing.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ns.ClientURL(), js
+}
+
+func newTestJetStreamSource(t *testing.T, url string, maxDeliver int) *jetStreamSource {
+	t.Helper()
+	source, err := NewJetStreamSource(JetStreamConfig{
+		URL:        url,
+		Stream:     "BILLING",
+		Durable:    "notification_service",
+		AckWait:    time.Minute,
+		MaxDeliver: maxDeliver,
+		Batch:      10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { source.Close() })
+	return source
+}
+
+// consumerIdle reports whether the durable consumer has nothing left to deliver or ack
+func consumerIdle(t *testing.T, js jetstream.JetStream) bool {
+	info, err := js.Consumer(context.Background(), "BILLING", "notification_service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := info.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i.NumPending == 0 && i.NumAckPending == 0 && i.NumRedelivered == 0
+}
+
+func TestJetStreamSourceDeliversAndAcks(t *testing.T) {
+	url, js := runJetStream(t)
+	ctx := context.Background()
+	_, err := js.PublishMsg(ctx, &nats.Msg{
+		Subject: "billing.payments",
+		Data:    []byte("payment"),
+		Header:  nats.Header{"ce-type": []string{"payment_succeeded"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.Publish(ctx, "billing.refunds", []byte("refund")); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*Message
+	payments := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		received = append(received, MessageFrom(ctx))
+		return nil
+	}}
+	refunds := &handlerFunc{}
+	c, err := NewKafkaConsumer(newTestJetStreamSource(t, url, 5),
+		Route{Topic: "billing.payments", Handler: payments},
+		Route{Topic: "billing.refunds", Handler: refunds},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "every message to be acked", func() bool {
+		return len(payments.handled()) == 1 && len(refunds.handled()) == 1 && consumerIdle(t, js)
+	})
+	stop()
+
+	msg := received[0]
+	if msg.Topic != "billing.payments" || msg.Offset != 1 || msg.deliveries != 1 {
+		t.Errorf("unexpected message %s@%d delivered %d times", msg.Topic, msg.Offset, msg.deliveries)
+	}
+	if want := []Header{{Key: "ce-type", Value: []byte("payment_succeeded")}}; !slices.EqualFunc(msg.Headers, want, func(a, b Header) bool {
+		return a.Key == b.Key && string(a.Value) == string(b.Value)
+	}) {
+		t.Errorf("headers = %v, want %v", msg.Headers, want)
+	}
+}
+
+func TestJetStreamSourceDeadLettersAtDeliveryLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		sinkErr    error
+		wantLetter bool
+	}{
+		{name: "dead letter saved", wantLetter: true},
+		{name: "dead letter failed", sinkErr: errors.New("database down")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, js := runJetStream(t)
+			if _, err := js.Publish(context.Background(), "billing.payments", []byte("payment")); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+				return errors.New("smtp unavailable")
+			}}
+			deadLetters := &fakeDeadLetters{err: tt.sinkErr}
+			c, err := NewKafkaConsumer(newTestJetStreamSource(t, url, 2), Route{
+				Topic:   "billing.payments",
+				Handler: handler,
+				Policy:  ErrorPolicy{Action: Hold, DeadLetters: deadLetters},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stop := start(t, c)
+			if tt.wantLetter {
+				waitFor(t, "the message to be acked", func() bool { return consumerIdle(t, js) })
+			} else {
+				// Delivered again past the limit rather than dropped
+				waitFor(t, "the message to be redelivered", func() bool { return len(handler.handled()) >= 3 })
+			}
+			stop()
+
+			letters := deadLetters.saved()
+			if !tt.wantLetter {
+				if len(letters) != 0 || consumerIdle(t, js) {
+					t.Errorf("saved %d dead letters, want the message kept on the server", len(letters))
+				}
+				return
+			}
+			if got := len(handler.handled()); got != 2 {
+				t.Errorf("handled %d times, want 2", got)
+			}
+			if len(letters) != 1 || string(letters[0].Payload) != "payment" || letters[0].Error != "smtp unavailable" {
+				t.Errorf("unexpected dead letters: %+v", letters)
+			}
+		})
+	}
+}
+
+func TestJetStreamSourceRejectsTopicPatterns(t *testing.T) {
+	url, _ := runJetStream(t)
+	if err := newTestJetStreamSource(t, url, 5).Subscribe([]string{"^billing"}); err == nil {
+		t.Error("Subscribe() succeeded, want an error")
+	}
+}
@@ -2,8 +2,15 @@ package consumer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"notification-service/internal/logwriter"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -11,47 +18,407 @@ type MessageHandler interface {
 	HandleMessage(ctx context.Context, message []byte) error
 }
 
+type messageKey struct{}
+
+// MessageFrom returns the message a handler was called for, with its topic
+// and headers; nil when the handler was not called by a KafkaConsumer
+func MessageFrom(ctx context.Context) *Message {
+	msg, _ := ctx.Value(messageKey{}).(*Message)
+	return msg
+}
+
+// SetUserID records the user whose data the message carries, so its dead
+// letter is removed when the user is erased
+func SetUserID(ctx context.Context, userID string) {
+	if msg := MessageFrom(ctx); msg != nil {
+		msg.userID = userID
+	}
+}
+
+// inflight is a handled message whose log rows may not be durable yet
+type inflight struct {
+	message *Message
+	ack     *logwriter.Ack
+}
+
+type patternRoute struct {
+	pattern *regexp.Regexp
+	route   Route
+}
+
+// KafkaConsumer reads several topics through one message source and routes
+// each message to the handler of its topic.
+//
+// It commits a message only after the log rows written for it are durable,
+// in partition order, so a restart never skips past an unwritten row.
 type KafkaConsumer struct {
-	consumer *kafka.Consumer
-	topic    string
-	handler  MessageHandler
+	source   MessageSource
+	routes   map[string]Route
+	patterns []patternRoute
+	stats    stats
+	flow     *flowController
+
+	mu       sync.Mutex
+	inflight map[TopicPartition][]inflight
+	// held are the partitions paused by their route's error policy
+	held map[TopicPartition]bool
 }
 
-func NewKafkaConsumer(consumer *kafka.Consumer, topic string, handler MessageHandler) (*KafkaConsumer, error) {
-	if err := consumer.SubscribeTopics([]string{topic}, nil); err != nil {
+// NewKafkaConsumer subscribes source to the topics of every route
+func NewKafkaConsumer(source MessageSource, routes ...Route) (*KafkaConsumer, error) {
+	c := &KafkaConsumer{
+		source:   source,
+		routes:   make(map[string]Route),
+		inflight: make(map[TopicPartition][]inflight),
+		held:     make(map[TopicPartition]bool),
+	}
+
+	topics := make([]string, 0, len(routes))
+	for _, route := range routes {
+		if strings.HasPrefix(route.Topic, "^") {
+			pattern, err := regexp.Compile(route.Topic)
+			if err != nil {
+				return nil, fmt.Errorf("invalid topic pattern %q: %w", route.Topic, err)
+			}
+			c.patterns = append(c.patterns, patternRoute{pattern: pattern, route: route})
+		} else {
+			if _, ok := c.routes[route.Topic]; ok {
+				return nil, fmt.Errorf("topic %q routed twice", route.Topic)
+			}
+			c.routes[route.Topic] = route
+		}
+		topics = append(topics, route.Topic)
+	}
+
+	if rebalancer, ok := source.(Rebalancer); ok {
+		rebalancer.OnRebalance(c.assigned, c.revoked)
+	}
+	if err := source.Subscribe(topics); err != nil {
 		return nil, err
 	}
-	log.WithField("topic", topic).Info("Subscribed to Kafka topic")
-	return &KafkaConsumer{consumer: consumer, topic: topic, handler: handler}, nil
+	log.WithField("topics", topics).Info("Subscribed to Kafka topics")
+	return c, nil
+}
+
+// route finds the handler of a topic; exact names win over patterns
+func (c *KafkaConsumer) route(topic string) (Route, bool) {
+	if route, ok := c.routes[topic]; ok {
+		return route, true
+	}
+	for _, p := range c.patterns {
+		if p.pattern.MatchString(topic) {
+			return p.route, true
+		}
+	}
+	return Route{}, false
 }
 
 func (c *KafkaConsumer) Start(ctx context.Context) error {
 	for {
+		c.storeDurableOffsets()
+
 		select {
 		case <-ctx.Done():
 			log.Info("Kafka consumer stopping due to context cancellation")
 			return ctx.Err()
 		default:
-			ev := c.consumer.Poll(100)
-			if ev == nil {
-				continue
+		}
+
+		if c.flow != nil {
+			c.control(ctx)
+			if !c.flow.paused() {
+				if msg := c.nextWaiting(); msg != nil {
+					c.handle(ctx, msg)
+					continue
+				}
 			}
+		}
+
+		// Paused partitions deliver nothing, but polling keeps the consumer
+		// in its group
+		msg, err := c.source.Poll(100 * time.Millisecond)
+		if err != nil {
+			return err
+		}
+		switch {
+		case msg == nil:
+		case c.flow != nil && c.flow.paused():
+			c.keep(msg, false)
+		default:
+			c.handle(ctx, msg)
+		}
+	}
+}
+
+func (c *KafkaConsumer) handle(ctx context.Context, msg *Message) {
+	topic := msg.Topic
+	ack := logwriter.NewAck()
+	kept := false
+	defer func() {
+		if kept {
+			// Handled again after the resume, with a new ack
+			return
+		}
+		ack.Seal()
+		key := msg.TopicPartition()
+		c.mu.Lock()
+		c.inflight[key] = append(c.inflight[key], inflight{message: msg, ack: ack})
+		c.mu.Unlock()
+	}()
+
+	c.stats.update(topic, func(t *TopicStats) { t.Received++ })
 
-			switch e := ev.(type) {
-			case *kafka.Message:
-				if err := c.handler.HandleMessage(ctx, e.Value); err != nil {
-					log.WithError(err).Error("Failed to handle message")
+	route, ok := c.route(topic)
+	if !ok {
+		log.WithField("topic", topic).Warn("No handler for Kafka topic, skipping message")
+		c.stats.update(topic, func(t *TopicStats) { t.Unrouted++ })
+		return
+	}
+
+	started := time.Now()
+	attempts, err := c.handleWithRetries(ctx, route, msg, ack)
+	c.stats.update(topic, func(t *TopicStats) { t.TotalTime += time.Since(started) })
+	if err == nil {
+		c.stats.update(topic, func(t *TopicStats) { t.Handled++ })
+		return
+	}
+
+	c.stats.recordError(topic, err)
+	if c.flow != nil && !isPermanent(err) && !errors.Is(err, context.Canceled) && c.holdIfUnhealthy(ctx, msg) {
+		kept = true
+		return
+	}
+
+	fields := log.Fields{
+		"topic":     topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Shutting down; the message is consumed again after the restart
+		ack.Fail(err)
+		return
+	case route.Policy.Action == Skip || isPermanent(err) || c.lastDelivery(msg):
+		if route.Policy.DeadLetters == nil {
+			log.WithError(err).WithFields(fields).Error("Failed to handle message")
+			c.stats.update(topic, func(t *TopicStats) { t.Skipped++ })
+			return
+		}
+		dlqErr := c.deadLetter(ctx, route, msg, attempts, err)
+		if dlqErr == nil {
+			log.WithError(err).WithFields(fields).Error("Failed to handle message, moved it to the dead letters")
+			c.stats.update(topic, func(t *TopicStats) { t.DeadLettered++ })
+			return
+		}
+		// Hold the message rather than lose it
+		log.WithError(dlqErr).WithFields(fields).Error("Failed to save dead letter")
+	}
+
+	ack.Fail(err)
+	if _, ok := c.source.(Redeliverer); ok {
+		log.WithError(err).WithFields(fields).Error("Failed to handle message, returning it for redelivery")
+		c.stats.update(topic, func(t *TopicStats) { t.Redelivered++ })
+		return
+	}
+
+	log.WithError(err).WithFields(fields).Error("Failed to handle message, holding partition until restart")
+	c.stats.update(topic, func(t *TopicStats) { t.Held++ })
+	c.mu.Lock()
+	c.held[msg.TopicPartition()] = true
+	c.mu.Unlock()
+	if err := c.source.Pause([]TopicPartition{msg.TopicPartition()}); err != nil {
+		log.WithError(err).WithFields(fields).Error("Failed to pause partition")
+	}
+}
+
+func (c *KafkaConsumer) deadLetter(ctx context.Context, route Route, msg *Message, attempts int, cause error) error {
+	letter := domain.DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Payload:   msg.Value,
+		UserID:    msg.userID,
+		Error:     cause.Error(),
+		Permanent: isPermanent(cause),
+		Attempts:  attempts,
+	}
+	if len(msg.Headers) > 0 {
+		letter.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			letter.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return route.Policy.DeadLetters.SaveDeadLetter(ctx, letter)
+}
+
+// handleWithRetries returns the number of attempts made with the last error
+func (c *KafkaConsumer) handleWithRetries(ctx context.Context, route Route, msg *Message, ack *logwriter.Ack) (int, error) {
+	attempts := max(route.Policy.MaxAttempts, 1)
+	delay := route.Policy.Backoff
+	topic := msg.Topic
+	handlerCtx := context.WithValue(logwriter.WithAck(ctx, ack), messageKey{}, msg)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = route.Handler.HandleMessage(handlerCtx, msg.Value)
+		if err == nil || isPermanent(err) || attempt == attempts {
+			return attempt, err
+		}
+
+		c.stats.update(topic, func(t *TopicStats) { t.Retried++ })
+		log.WithFields(log.Fields{
+			"attempt":      attempt,
+			"max_attempts": attempts,
+			"error":        err,
+			"topic":        topic,
+		}).Warn("Failed to handle message, retrying...")
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return attempts, err
+}
+
+// storeDurableOffsets commits the leading messages of every partition whose
+// rows are durable. A failed message holds back every later offset of its
+// partition, so it is consumed again after a restart, unless the source can
+// redeliver it on its own.
+func (c *KafkaConsumer) storeDurableOffsets() {
+	for _, m := range c.takeDurable() {
+		c.giveUp(m.message, m.ack.Err())
+	}
+}
+
+// takeDurable commits or returns for redelivery the leading done messages of
+// every partition. It returns the failed ones that reached the source's
+// delivery limit, which must not be handed back.
+func (c *KafkaConsumer) takeDurable() []inflight {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var exhausted []inflight
+	for key, messages := range c.inflight {
+		stored := 0
+		for _, m := range messages {
+			if !isDone(m.ack) {
+				break
+			}
+			if err := m.ack.Err(); err != nil {
+				redeliverer, ok := c.source.(Redeliverer)
+				if !ok {
+					break
 				}
-			case kafka.Error:
-				log.WithError(e).Error("Kafka error")
-				if e.IsFatal() {
-					return e
+				if redeliverer.LastDelivery(m.message) && !errors.Is(err, context.Canceled) {
+					exhausted = append(exhausted, m)
+				} else if err := redeliverer.Nack(m.message); err != nil {
+					log.WithError(err).Warn("Failed to return message for redelivery")
 				}
+				stored++
+				continue
 			}
+			// Fails for partitions revoked in a rebalance; their new owner re-reads the message
+			if err := c.source.Commit(m.message); err != nil {
+				log.WithError(err).Warn("Failed to commit message")
+			}
+			stored++
+		}
+		if stored == len(messages) {
+			delete(c.inflight, key)
+		} else {
+			c.inflight[key] = messages[stored:]
 		}
 	}
+	return exhausted
+}
+
+// revoked forgets the partitions taken away in a rebalance. The messages
+// already durable are committed first; their new owner consumes the others
+// again, including the held and waiting ones.
+func (c *KafkaConsumer) revoked(partitions []TopicPartition) {
+	c.storeDurableOffsets()
+
+	c.mu.Lock()
+	for _, tp := range partitions {
+		delete(c.inflight, tp)
+		delete(c.held, tp)
+	}
+	c.mu.Unlock()
+	if c.flow != nil {
+		c.flow.forget(partitions)
+	}
+	log.WithField("partitions", partitions).Info("Kafka partitions revoked")
+}
+
+// assigned pauses the partitions assigned while the consumer is paused, as
+// they start unpaused
+func (c *KafkaConsumer) assigned(partitions []TopicPartition) {
+	log.WithField("partitions", partitions).Info("Kafka partitions assigned")
+	if c.flow == nil || !c.flow.paused() {
+		return
+	}
+	if err := c.source.Pause(partitions); err != nil {
+		log.WithError(err).Error("Failed to pause assigned partitions")
+	}
+}
+
+// giveUp moves a message that failed on its last delivery to the dead
+// letters of its route and commits it. It is returned for redelivery when
+// the dead letter cannot be saved.
+func (c *KafkaConsumer) giveUp(msg *Message, cause error) {
+	fields := log.Fields{
+		"topic":      msg.Topic,
+		"offset":     msg.Offset,
+		"deliveries": msg.deliveries,
+	}
+	route, _ := c.route(msg.Topic)
+	if route.Policy.DeadLetters == nil {
+		log.WithError(cause).WithFields(fields).Error("Message reached its delivery limit, dropping it")
+		c.stats.update(msg.Topic, func(t *TopicStats) { t.Skipped++ })
+	} else {
+		if err := c.deadLetter(context.Background(), route, msg, msg.deliveries, cause); err != nil {
+			log.WithError(err).WithFields(fields).Error("Failed to save dead letter")
+			if err := c.source.(Redeliverer).Nack(msg); err != nil {
+				log.WithError(err).Warn("Failed to return message for redelivery")
+			}
+			return
+		}
+		log.WithError(cause).WithFields(fields).Error("Message reached its delivery limit, moved it to the dead letters")
+		c.stats.update(msg.Topic, func(t *TopicStats) { t.DeadLettered++ })
+	}
+	if err := c.source.Commit(msg); err != nil {
+		log.WithError(err).Warn("Failed to commit message")
+	}
+}
+
+// lastDelivery reports whether the source gives up on msg if it fails again
+func (c *KafkaConsumer) lastDelivery(msg *Message) bool {
+	redeliverer, ok := c.source.(Redeliverer)
+	return ok && redeliverer.LastDelivery(msg)
+}
+
+func isDone(ack *logwriter.Ack) bool {
+	select {
+	case <-ack.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Stats returns the message counters of every topic seen so far
+func (c *KafkaConsumer) Stats() map[string]TopicStats {
+	return c.stats.snapshot()
 }
 
+// Close commits the messages that became durable since the last poll and
+// closes the source
 func (c *KafkaConsumer) Close() error {
-	return c.consumer.Close()
+	c.storeDurableOffsets()
+	return c.source.Close()
 }
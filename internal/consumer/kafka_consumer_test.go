@@ -0,0 +1,463 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"notification-service/internal/domain"
+	"notification-service/internal/logwriter"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// handlerFunc handles a message with a func and records every call
+type handlerFunc struct {
+	fn func(ctx context.Context, value string) error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (h *handlerFunc) HandleMessage(ctx context.Context, message []byte) error {
+	h.mu.Lock()
+	h.calls = append(h.calls, string(message))
+	h.mu.Unlock()
+	if h.fn == nil {
+		return nil
+	}
+	return h.fn(ctx, string(message))
+}
+
+func (h *handlerFunc) handled() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.calls)
+}
+
+type fakeDeadLetters struct {
+	err error
+
+	mu      sync.Mutex
+	letters []domain.DeadLetter
+}
+
+func (f *fakeDeadLetters) SaveDeadLetter(ctx context.Context, letter domain.DeadLetter) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.letters = append(f.letters, letter)
+	return nil
+}
+
+func (f *fakeDeadLetters) saved() []domain.DeadLetter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.letters)
+}
+
+// start runs the consumer until the returned func is called
+func start(t *testing.T, c *KafkaConsumer) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Start() = %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("consumer did not stop")
+		}
+	}
+}
+
+// waitFor fails the test unless cond holds within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func produce(source *MemorySource, topic string, partition int32, values ...string) {
+	for _, v := range values {
+		source.Produce(Message{Topic: topic, Partition: partition, Value: []byte(v)})
+	}
+}
+
+func TestConsumerHoldsOffsetsBehindFailedAck(t *testing.T) {
+	source := NewMemorySource()
+	tp := TopicPartition{Topic: "events"}
+	produce(source, tp.Topic, 0, "a", "b", "c")
+
+	// The handler succeeds, but the log row written for "b" never becomes durable
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		complete := logwriter.Track(ctx)
+		if value == "b" {
+			complete(errors.New("insert failed"))
+		} else {
+			complete(nil)
+		}
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{Topic: tp.Topic, Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "every message to be handled", func() bool { return len(handler.handled()) == 3 })
+	waitFor(t, "the first offset to be committed", func() bool { return source.Committed(tp) == 1 })
+	// Give the consumer a few more rounds to commit past the failed ack
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	if got := source.Committed(tp); got != 1 {
+		t.Fatalf("committed offset = %d, want 1", got)
+	}
+
+	// A restart handles the message of the failed ack again, and the ones after it
+	handler.fn = nil
+	source.Rewind()
+	c, err = NewKafkaConsumer(source, Route{Topic: tp.Topic, Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop = start(t, c)
+	waitFor(t, "every offset to be committed", func() bool { return source.Committed(tp) == 3 })
+	stop()
+
+	want := []string{"a", "b", "c", "b", "c"}
+	if got := handler.handled(); !slices.Equal(got, want) {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+}
+
+func TestConsumerDeadLettersFailedMessages(t *testing.T) {
+	source := NewMemorySource()
+	tp := TopicPartition{Topic: "events"}
+	source.Produce(Message{
+		Topic:   tp.Topic,
+		Key:     []byte("k"),
+		Value:   []byte("malformed"),
+		Headers: []Header{{Key: "content-type", Value: []byte("application/json")}},
+	})
+	produce(source, tp.Topic, 0, "flaky", "ok")
+
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		switch value {
+		case "malformed":
+			return Permanent(errors.New("invalid JSON"))
+		case "flaky":
+			SetUserID(ctx, "user-1")
+			return errors.New("timeout")
+		}
+		return nil
+	}}
+	deadLetters := &fakeDeadLetters{}
+	c, err := NewKafkaConsumer(source, Route{
+		Topic:   tp.Topic,
+		Handler: handler,
+		Policy:  ErrorPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Action: Skip, DeadLetters: deadLetters},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "every offset to be committed", func() bool { return source.Committed(tp) == 3 })
+	stop()
+
+	letters := deadLetters.saved()
+	if len(letters) != 2 {
+		t.Fatalf("saved %d dead letters, want 2", len(letters))
+	}
+	malformed, flaky := letters[0], letters[1]
+	if string(malformed.Payload) != "malformed" || malformed.Offset != 0 || !malformed.Permanent ||
+		malformed.Attempts != 1 || malformed.Error != "invalid JSON" || string(malformed.Key) != "k" ||
+		malformed.Headers["content-type"] != "application/json" || malformed.UserID != "" {
+		t.Errorf("unexpected dead letter for the permanent failure: %+v", malformed)
+	}
+	if string(flaky.Payload) != "flaky" || flaky.Offset != 1 || flaky.Permanent || flaky.Attempts != 3 || flaky.Error != "timeout" || flaky.UserID != "user-1" {
+		t.Errorf("unexpected dead letter for the exhausted retries: %+v", flaky)
+	}
+
+	stats := c.Stats()[tp.Topic]
+	if stats.Received != 3 || stats.Handled != 1 || stats.DeadLettered != 2 || stats.Retried != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestConsumerHoldsMessageWhenDeadLetterFails(t *testing.T) {
+	source := NewMemorySource()
+	tp := TopicPartition{Topic: "events"}
+	produce(source, tp.Topic, 0, "ok", "malformed", "after")
+
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		if value == "malformed" {
+			return Permanent(errors.New("invalid JSON"))
+		}
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{
+		Topic:   tp.Topic,
+		Handler: handler,
+		Policy:  ErrorPolicy{Action: Skip, DeadLetters: &fakeDeadLetters{err: errors.New("database down")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "the partition to be held", func() bool { return c.Stats()[tp.Topic].Held == 1 })
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	if got := source.Committed(tp); got != 1 {
+		t.Errorf("committed offset = %d, want 1", got)
+	}
+	if got, want := handler.handled(), []string{"ok", "malformed"}; !slices.Equal(got, want) {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+}
+
+func TestConsumerHoldPausesOnlyTheFailedPartition(t *testing.T) {
+	source := NewMemorySource()
+	failing := TopicPartition{Topic: "events", Partition: 0}
+	healthy := TopicPartition{Topic: "events", Partition: 1}
+	produce(source, failing.Topic, failing.Partition, "a", "broken", "c")
+	produce(source, healthy.Topic, healthy.Partition, "x", "y")
+
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		if value == "broken" {
+			return errors.New("downstream rejected the message")
+		}
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{
+		Topic:   "events",
+		Handler: handler,
+		Policy:  ErrorPolicy{MaxAttempts: 2, Backoff: time.Millisecond, Action: Hold},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "the healthy partition to be committed", func() bool { return source.Committed(healthy) == 2 })
+	waitFor(t, "the partition to be held", func() bool { return c.Stats()["events"].Held == 1 })
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	if got := source.Committed(failing); got != 1 {
+		t.Errorf("committed offset of the held partition = %d, want 1", got)
+	}
+	if got := handler.handled(); slices.Contains(got, "c") {
+		t.Errorf("handled %v, want nothing after the held message", got)
+	}
+
+	// After a restart the held message is handled again
+	handler.fn = nil
+	source.Rewind()
+	c, err = NewKafkaConsumer(source, Route{Topic: "events", Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop = start(t, c)
+	waitFor(t, "the held partition to be committed", func() bool { return source.Committed(failing) == 3 })
+	stop()
+}
+
+func TestConsumerPausesWhileDependencyIsUnhealthy(t *testing.T) {
+	source := NewMemorySource()
+	tp := TopicPartition{Topic: "events"}
+
+	produce(source, tp.Topic, 0, "first", "second")
+
+	// The dependency fails while the first message is handled: it is kept
+	// and everything polled after it waits behind it
+	var healthy, failed atomic.Bool
+	healthy.Store(true)
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		if value == "first" && !failed.Swap(true) {
+			healthy.Store(false)
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{Topic: tp.Topic, Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PauseWhenUnhealthy(10*time.Millisecond, HealthCheck{
+		Name: "smtp",
+		Check: func(ctx context.Context) error {
+			if !healthy.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+
+	stop := start(t, c)
+	defer stop()
+
+	waitFor(t, "the consumer to pause", func() bool {
+		status, _ := c.FlowStatus()
+		return status.State == FlowPaused
+	})
+	time.Sleep(50 * time.Millisecond)
+	// Read from another goroutine, as the admin API does
+	if status, _ := c.FlowStatus(); status.Waiting == 0 {
+		t.Errorf("no message waiting while paused: %+v", status)
+	}
+	if got := source.Committed(tp); got != 0 {
+		t.Errorf("committed offset while paused = %d, want 0", got)
+	}
+	if got, want := handler.handled(), []string{"first"}; !slices.Equal(got, want) {
+		t.Errorf("handled %v while paused, want %v", got, want)
+	}
+
+	healthy.Store(true)
+	waitFor(t, "every offset to be committed", func() bool { return source.Committed(tp) == 2 })
+
+	if got, want := handler.handled(), []string{"first", "first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+	status, ok := c.FlowStatus()
+	if !ok || status.State != FlowRunning || status.Waiting != 0 {
+		t.Errorf("unexpected flow status after the resume: %+v", status)
+	}
+	var states []FlowState
+	for _, tr := range status.Transitions {
+		states = append(states, tr.To)
+	}
+	if want := []FlowState{FlowPaused, FlowRunning}; !slices.Equal(states, want) {
+		t.Errorf("transitions to %v, want %v", states, want)
+	}
+	if dep := status.Transitions[0].Dependency; dep != "smtp" {
+		t.Errorf("paused for dependency %q, want smtp", dep)
+	}
+}
+
+func TestConsumerRoutesTopicPatterns(t *testing.T) {
+	source := NewMemorySource()
+	produce(source, "orders.created", 0, "created")
+	produce(source, "orders.refunded", 0, "refunded")
+	produce(source, "orders.special", 0, "special")
+	produce(source, "payments", 0, "payment")
+
+	patternHandler := &handlerFunc{}
+	exactHandler := &handlerFunc{}
+	c, err := NewKafkaConsumer(source,
+		Route{Topic: `^orders\..+`, Handler: patternHandler},
+		Route{Topic: "orders.special", Handler: exactHandler},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "the routed topics to be committed", func() bool {
+		return source.Committed(TopicPartition{Topic: "orders.created"}) == 1 &&
+			source.Committed(TopicPartition{Topic: "orders.refunded"}) == 1 &&
+			source.Committed(TopicPartition{Topic: "orders.special"}) == 1
+	})
+	stop()
+
+	// Exact topic names win over patterns
+	if got, want := patternHandler.handled(), []string{"created", "refunded"}; !slices.Equal(got, want) {
+		t.Errorf("pattern route handled %v, want %v", got, want)
+	}
+	if got, want := exactHandler.handled(), []string{"special"}; !slices.Equal(got, want) {
+		t.Errorf("exact route handled %v, want %v", got, want)
+	}
+	if got := source.Committed(TopicPartition{Topic: "payments"}); got != 0 {
+		t.Errorf("committed offset of an unsubscribed topic = %d, want 0", got)
+	}
+	if _, ok := c.Stats()["payments"]; ok {
+		t.Error("received a message of an unsubscribed topic")
+	}
+}
+
+func TestNewKafkaConsumerRejectsBadRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []Route
+	}{
+		{"invalid pattern", []Route{{Topic: "^orders.(", Handler: &handlerFunc{}}}},
+		{"topic routed twice", []Route{{Topic: "orders", Handler: &handlerFunc{}}, {Topic: "orders", Handler: &handlerFunc{}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKafkaConsumer(NewMemorySource(), tt.routes...); err == nil {
+				t.Error("NewKafkaConsumer() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestConsumerForgetsRevokedPartitions(t *testing.T) {
+	source := NewMemorySource()
+	tp := TopicPartition{Topic: "events"}
+	produce(source, tp.Topic, 0, "first", "second")
+
+	var healthy, failed atomic.Bool
+	healthy.Store(true)
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		if value == "first" && !failed.Swap(true) {
+			healthy.Store(false)
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{Topic: tp.Topic, Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PauseWhenUnhealthy(10*time.Millisecond, HealthCheck{
+		Name: "smtp",
+		Check: func(ctx context.Context) error {
+			if !healthy.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+
+	stop := start(t, c)
+	defer stop()
+	waitFor(t, "the consumer to pause", func() bool {
+		status, _ := c.FlowStatus()
+		return status.State == FlowPaused && status.Waiting > 0
+	})
+
+	// The partition comes back unpaused from its committed offset; the
+	// messages kept for it would be handled twice
+	source.Reassign(tp)
+	waitFor(t, "the kept messages to be dropped", func() bool {
+		status, _ := c.FlowStatus()
+		return status.Waiting == 0
+	})
+	time.Sleep(50 * time.Millisecond)
+	if got := handler.handled(); len(got) != 1 {
+		t.Errorf("handled %v while paused, want the first message only", got)
+	}
+
+	healthy.Store(true)
+	waitFor(t, "every offset to be committed", func() bool { return source.Committed(tp) == 2 })
+	if got, want := handler.handled(), []string{"first", "first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+}
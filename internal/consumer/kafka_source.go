@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	log "github.com/sirupsen/logrus"
+)
+
+type kafkaSource struct {
+	consumer *kafka.Consumer
+	assigned func(partitions []TopicPartition)
+	revoked  func(partitions []TopicPartition)
+}
+
+// NewKafkaSource reads from a Kafka consumer created with
+// "enable.auto.offset.store" set to false: Commit stores the offset and the
+// next auto commit sends it to the broker
+func NewKafkaSource(consumer *kafka.Consumer) *kafkaSource {
+	return &kafkaSource{consumer: consumer}
+}
+
+func (s *kafkaSource) Subscribe(topics []string) error {
+	return s.consumer.SubscribeTopics(topics, s.rebalance)
+}
+
+func (s *kafkaSource) OnRebalance(assigned, revoked func(partitions []TopicPartition)) {
+	s.assigned, s.revoked = assigned, revoked
+}
+
+// rebalance assigns new partitions itself rather than after the callback
+// returns, so they can be paused again before anything is fetched
+func (s *kafkaSource) rebalance(c *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		var err error
+		if c.GetRebalanceProtocol() == "COOPERATIVE" {
+			err = c.IncrementalAssign(e.Partitions)
+		} else {
+			err = c.Assign(e.Partitions)
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to assign partitions")
+			return err
+		}
+		if s.assigned != nil {
+			s.assigned(topicPartitions(e.Partitions))
+		}
+	case kafka.RevokedPartitions:
+		if s.revoked != nil {
+			s.revoked(topicPartitions(e.Partitions))
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSource) Poll(timeout time.Duration) (*Message, error) {
+	ev := s.consumer.Poll(int(timeout.Milliseconds()))
+	switch e := ev.(type) {
+	case *kafka.Message:
+		msg := &Message{
+			Topic:     *e.TopicPartition.Topic,
+			Partition: e.TopicPartition.Partition,
+			Offset:    int64(e.TopicPartition.Offset),
+			Key:       e.Key,
+			Value:     e.Value,
+			Timestamp: e.Timestamp,
+		}
+		for _, h := range e.Headers {
+			msg.Headers = append(msg.Headers, Header{Key: h.Key, Value: h.Value})
+		}
+		return msg, nil
+	case kafka.Error:
+		if e.IsFatal() {
+			return nil, e
+		}
+		log.WithError(e).Error("Kafka error")
+	}
+	return nil, nil
+}
+
+func (s *kafkaSource) Commit(msg *Message) error {
+	_, err := s.consumer.StoreOffsets([]kafka.TopicPartition{{
+		Topic:     &msg.Topic,
+		Partition: msg.Partition,
+		Offset:    kafka.Offset(msg.Offset + 1),
+	}})
+	return err
+}
+
+func (s *kafkaSource) Assignment() ([]TopicPartition, error) {
+	assigned, err := s.consumer.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	return topicPartitions(assigned), nil
+}
+
+func (s *kafkaSource) Pause(partitions []TopicPartition) error {
+	return s.consumer.Pause(kafkaPartitions(partitions))
+}
+
+func (s *kafkaSource) Resume(partitions []TopicPartition) error {
+	return s.consumer.Resume(kafkaPartitions(partitions))
+}
+
+// Close commits the stored offsets and leaves the consumer group
+func (s *kafkaSource) Close() error {
+	return s.consumer.Close()
+}
+
+func topicPartitions(partitions []kafka.TopicPartition) []TopicPartition {
+	out := make([]TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		out[i] = TopicPartition{Topic: *tp.Topic, Partition: tp.Partition}
+	}
+	return out
+}
+
+func kafkaPartitions(partitions []TopicPartition) []kafka.TopicPartition {
+	out := make([]kafka.TopicPartition, len(partitions))
+	for i, p := range partitions {
+		topic := p.Topic
+		out[i] = kafka.TopicPartition{Topic: &topic, Partition: p.Partition}
+	}
+	return out
+}
@@ -0,0 +1,261 @@
+package consumer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryPartition struct {
+	messages []Message
+	// next is the offset of the next message to deliver
+	next int64
+	// committed is the offset of the first unprocessed message, as in Kafka
+	committed int64
+	paused    bool
+}
+
+// MemorySource is a MessageSource kept in memory, for tests and local demos.
+// Every partition starts at offset 0.
+type MemorySource struct {
+	mu         sync.Mutex
+	topics     map[string]bool
+	patterns   []*regexp.Regexp
+	partitions map[TopicPartition]*memoryPartition
+	order      []TopicPartition
+	notify     chan struct{}
+	closed     bool
+
+	assigned func(partitions []TopicPartition)
+	revoked  func(partitions []TopicPartition)
+	// reassigning are the partitions the next Poll takes away and gives back
+	reassigning []TopicPartition
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{
+		topics:     make(map[string]bool),
+		partitions: make(map[TopicPartition]*memoryPartition),
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+// Produce appends msg to its partition and returns it with its offset set
+func (s *MemorySource) Produce(msg Message) Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(msg.TopicPartition())
+	msg.Offset = int64(len(p.messages))
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	p.messages = append(p.messages, msg)
+	s.wake()
+	return msg
+}
+
+// Committed returns the offset the partition would resume from after a restart
+func (s *MemorySource) Committed(tp TopicPartition) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.partitions[tp]; ok {
+		return p.committed
+	}
+	return 0
+}
+
+// Rewind resumes every partition from its committed offset, as a restarted
+// consumer would
+func (s *MemorySource) Rewind() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.partitions {
+		p.next = p.committed
+		p.paused = false
+	}
+	s.wake()
+}
+
+func (s *MemorySource) OnRebalance(assigned, revoked func(partitions []TopicPartition)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assigned, s.revoked = assigned, revoked
+}
+
+// Reassign makes the next Poll take the partitions away and give them back,
+// as a Kafka rebalance does: they resume from their committed offsets,
+// unpaused
+func (s *MemorySource) Reassign(partitions ...TopicPartition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reassigning = append(s.reassigning, partitions...)
+	s.wake()
+}
+
+// rebalance runs a pending Reassign; it is called from Poll without s.mu
+func (s *MemorySource) rebalance() {
+	s.mu.Lock()
+	partitions := s.reassigning
+	s.reassigning = nil
+	assigned, revoked := s.assigned, s.revoked
+	s.mu.Unlock()
+	if len(partitions) == 0 {
+		return
+	}
+
+	if revoked != nil {
+		revoked(partitions)
+	}
+	s.mu.Lock()
+	for _, tp := range partitions {
+		p := s.partition(tp)
+		p.next = p.committed
+		p.paused = false
+	}
+	s.mu.Unlock()
+	if assigned != nil {
+		assigned(partitions)
+	}
+}
+
+func (s *MemorySource) Subscribe(topics []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.topics = make(map[string]bool)
+	s.patterns = nil
+	for _, topic := range topics {
+		if !strings.HasPrefix(topic, "^") {
+			s.topics[topic] = true
+			continue
+		}
+		pattern, err := regexp.Compile(topic)
+		if err != nil {
+			return fmt.Errorf("invalid topic pattern %q: %w", topic, err)
+		}
+		s.patterns = append(s.patterns, pattern)
+	}
+	s.wake()
+	return nil
+}
+
+func (s *MemorySource) Poll(timeout time.Duration) (*Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.rebalance()
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, ErrSourceClosed
+		}
+		msg := s.nextMessage()
+		s.mu.Unlock()
+		if msg != nil {
+			return msg, nil
+		}
+
+		select {
+		case <-s.notify:
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+// nextMessage returns the next message of the first subscribed partition
+// that has one, so partitions are drained in the order they were created
+func (s *MemorySource) nextMessage() *Message {
+	for _, tp := range s.order {
+		p := s.partitions[tp]
+		if p.paused || p.next >= int64(len(p.messages)) || !s.subscribed(tp.Topic) {
+			continue
+		}
+		msg := p.messages[p.next]
+		p.next++
+		return &msg
+	}
+	return nil
+}
+
+func (s *MemorySource) subscribed(topic string) bool {
+	if s.topics[topic] {
+		return true
+	}
+	return slices.ContainsFunc(s.patterns, func(p *regexp.Regexp) bool { return p.MatchString(topic) })
+}
+
+func (s *MemorySource) Commit(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.partitions[msg.TopicPartition()]
+	if !ok || msg.Offset >= int64(len(p.messages)) {
+		return fmt.Errorf("unknown message %s[%d]@%d", msg.Topic, msg.Partition, msg.Offset)
+	}
+	p.committed = max(p.committed, msg.Offset+1)
+	return nil
+}
+
+func (s *MemorySource) Assignment() ([]TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var partitions []TopicPartition
+	for _, tp := range s.order {
+		if s.subscribed(tp.Topic) {
+			partitions = append(partitions, tp)
+		}
+	}
+	return partitions, nil
+}
+
+func (s *MemorySource) Pause(partitions []TopicPartition) error {
+	return s.setPaused(partitions, true)
+}
+
+func (s *MemorySource) Resume(partitions []TopicPartition) error {
+	return s.setPaused(partitions, false)
+}
+
+func (s *MemorySource) setPaused(partitions []TopicPartition, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		s.partition(tp).paused = paused
+	}
+	s.wake()
+	return nil
+}
+
+func (s *MemorySource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.wake()
+	return nil
+}
+
+// partition returns the partition, creating it if needed; s.mu must be held
+func (s *MemorySource) partition(tp TopicPartition) *memoryPartition {
+	p, ok := s.partitions[tp]
+	if !ok {
+		p = &memoryPartition{}
+		s.partitions[tp] = p
+		s.order = append(s.order, tp)
+	}
+	return p
+}
+
+// wake lets a waiting Poll look again; s.mu must be held
+func (s *MemorySource) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
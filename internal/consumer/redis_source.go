@@ -0,0 +1,336 @@
+package consumer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const redisDialTimeout = 5 * time.Second
+
+// RedisConfig configures a consumer group reading Redis streams. Topics are
+// stream keys; an entry carries its payload in the field "value", an
+// optional key in "key" and headers in any other field.
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// TLS connects over TLS, verifying the server against the system roots
+	TLS   bool
+	Group string
+	// Consumer names this instance in the group and must survive restarts;
+	// the host name is used when empty
+	Consumer string
+	// ClaimIdle is how long a delivered entry stays unacked before it is delivered again
+	ClaimIdle time.Duration
+	// MaxDeliver is how often an entry is delivered before it is dead-lettered
+	MaxDeliver int
+	// Batch is the number of entries read per request
+	Batch int
+}
+
+type redisSource struct {
+	cfg    RedisConfig
+	client *redis.Client
+
+	mu        sync.Mutex
+	closed    bool
+	streams   []string
+	paused    map[string]bool
+	buffered  []*Message
+	lastClaim time.Time
+}
+
+// NewRedisSource connects to Redis; the consumer group is created on Subscribe
+func NewRedisSource(cfg RedisConfig) (*redisSource, error) {
+	if cfg.Consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to name Redis consumer: %w", err)
+		}
+		cfg.Consumer = hostname
+	}
+	cfg.Batch = max(cfg.Batch, 1)
+
+	opts := &redis.Options{
+		Addr:        cfg.Addr,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: redisDialTimeout,
+	}
+	if cfg.TLS {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis address: %w", err)
+		}
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: host}
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisDialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	log.WithField("addr", cfg.Addr).Info("Connected to Redis")
+	return &redisSource{cfg: cfg, client: client, paused: make(map[string]bool)}, nil
+}
+
+// Subscribe creates the consumer group on every stream; a new group starts
+// at the beginning of the stream
+func (s *redisSource) Subscribe(topics []string) error {
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, "^") {
+			return fmt.Errorf("topic pattern %q is not supported by Redis streams", topic)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = topics
+	return s.createGroups()
+}
+
+// createGroups creates missing consumer groups; s.mu must be held
+func (s *redisSource) createGroups() error {
+	for _, stream := range s.streams {
+		err := s.client.XGroupCreateMkStream(context.Background(), stream, s.cfg.Group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create Redis consumer group on %s: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+func (s *redisSource) Poll(timeout time.Duration) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSourceClosed
+	}
+	if msg := s.next(); msg != nil {
+		return msg, nil
+	}
+	if err := s.fill(timeout); err != nil {
+		log.WithError(err).Warn("Failed to read Redis streams, retrying...")
+		// A Redis restarted without its data lost the consumer groups
+		if strings.Contains(err.Error(), "NOGROUP") {
+			if err := s.createGroups(); err != nil {
+				log.WithError(err).Warn("Failed to recreate Redis consumer groups")
+			}
+		}
+		time.Sleep(timeout)
+		return nil, nil
+	}
+	return s.next(), nil
+}
+
+// next takes the first buffered entry of a stream that is not paused; s.mu must be held
+func (s *redisSource) next() *Message {
+	for i, msg := range s.buffered {
+		if !s.paused[msg.Topic] {
+			s.buffered = slices.Delete(s.buffered, i, i+1)
+			return msg
+		}
+	}
+	return nil
+}
+
+// fill buffers entries whose delivery timed out, or else waits up to timeout
+// for new ones; s.mu must be held
+func (s *redisSource) fill(timeout time.Duration) error {
+	var streams []string
+	for _, stream := range s.streams {
+		if !s.paused[stream] {
+			streams = append(streams, stream)
+		}
+	}
+	if len(streams) == 0 {
+		time.Sleep(timeout)
+		return nil
+	}
+
+	ctx := context.Background()
+	if time.Since(s.lastClaim) >= max(s.cfg.ClaimIdle/2, time.Second) {
+		s.lastClaim = time.Now()
+		for _, stream := range streams {
+			if err := s.claim(ctx, stream); err != nil {
+				return err
+			}
+		}
+		if len(s.buffered) > 0 {
+			return nil
+		}
+	}
+
+	ids := make([]string, 0, 2*len(streams))
+	ids = append(ids, streams...)
+	for range streams {
+		ids = append(ids, ">")
+	}
+	result, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.cfg.Group,
+		Consumer: s.cfg.Consumer,
+		Streams:  ids,
+		Count:    int64(s.cfg.Batch),
+		// A block of 0 would wait forever
+		Block: max(timeout, time.Millisecond),
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read Redis streams: %w", err)
+	}
+	for _, stream := range result {
+		for _, entry := range stream.Messages {
+			s.buffered = append(s.buffered, redisMessage(stream.Stream, entry, 1))
+		}
+	}
+	return nil
+}
+
+// claim takes over entries of stream that stayed unacked for ClaimIdle.
+// Entries past MaxDeliver are claimed too, so the consumer dead-letters them
+// instead of losing them; s.mu must be held
+func (s *redisSource) claim(ctx context.Context, stream string) error {
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  s.cfg.Group,
+		Idle:   s.cfg.ClaimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(s.cfg.Batch),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list pending Redis entries: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(pending))
+	deliveries := make(map[string]int, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+		deliveries[p.ID] = int(p.RetryCount) + 1
+	}
+	claimed, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    s.cfg.Group,
+		Consumer: s.cfg.Consumer,
+		MinIdle:  s.cfg.ClaimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to claim pending Redis entries: %w", err)
+	}
+	for _, entry := range claimed {
+		// Entries deleted from the stream come back empty
+		if entry.Values == nil {
+			continue
+		}
+		s.buffered = append(s.buffered, redisMessage(stream, entry, deliveries[entry.ID]))
+	}
+	return nil
+}
+
+// Commit acks msg in the consumer group
+func (s *redisSource) Commit(msg *Message) error {
+	id, _ := msg.receipt.(string)
+	if err := s.client.XAck(context.Background(), msg.Topic, s.cfg.Group, id).Err(); err != nil {
+		return fmt.Errorf("failed to ack Redis entry: %w", err)
+	}
+	return nil
+}
+
+// Nack leaves msg pending, so it is claimed again after ClaimIdle
+func (s *redisSource) Nack(msg *Message) error {
+	return nil
+}
+
+// LastDelivery reports whether msg was delivered MaxDeliver times
+func (s *redisSource) LastDelivery(msg *Message) bool {
+	return s.cfg.MaxDeliver > 0 && msg.deliveries >= s.cfg.MaxDeliver
+}
+
+// Assignment returns every subscribed stream as partition 0
+func (s *redisSource) Assignment() ([]TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return subjectPartitions(s.streams), nil
+}
+
+func (s *redisSource) Pause(partitions []TopicPartition) error {
+	s.setPaused(partitions, true)
+	return nil
+}
+
+func (s *redisSource) Resume(partitions []TopicPartition) error {
+	s.setPaused(partitions, false)
+	return nil
+}
+
+func (s *redisSource) setPaused(partitions []TopicPartition, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		if paused {
+			s.paused[tp.Topic] = true
+		} else {
+			delete(s.paused, tp.Topic)
+		}
+	}
+}
+
+// Close drops the connection; unacked entries are claimed again after ClaimIdle
+func (s *redisSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.client.Close()
+}
+
+// redisMessage converts a stream entry; headers are sorted by name
+func redisMessage(stream string, entry redis.XMessage, deliveries int) *Message {
+	millis, _, _ := strings.Cut(entry.ID, "-")
+	offset, _ := strconv.ParseInt(millis, 10, 64)
+
+	msg := &Message{
+		Topic:      stream,
+		Offset:     offset,
+		Timestamp:  time.UnixMilli(offset),
+		receipt:    entry.ID,
+		deliveries: deliveries,
+	}
+	for _, field := range slices.Sorted(maps.Keys(entry.Values)) {
+		value := fmt.Sprint(entry.Values[field])
+		switch field {
+		case "value":
+			msg.Value = []byte(value)
+		case "key":
+			msg.Key = []byte(value)
+		default:
+			msg.Headers = append(msg.Headers, Header{Key: field, Value: []byte(value)})
+		}
+	}
+	return msg
+}
@@ -0,0 +1,160 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisSource(t *testing.T, addr string, maxDeliver int) *redisSource {
+	t.Helper()
+	source, err := NewRedisSource(RedisConfig{
+		Addr:       addr,
+		Group:      "notification_service",
+		Consumer:   "test",
+		ClaimIdle:  10 * time.Millisecond,
+		MaxDeliver: maxDeliver,
+		Batch:      10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { source.Close() })
+	return source
+}
+
+// pendingEntries returns the number of delivered but unacked entries of stream
+func pendingEntries(t *testing.T, client *redis.Client, stream string) int64 {
+	pending, err := client.XPending(context.Background(), stream, "notification_service").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending.Count
+}
+
+func TestRedisSourceDeliversAndAcks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	source := newTestRedisSource(t, mr.Addr(), 5)
+	var received []*Message
+	handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+		received = append(received, MessageFrom(ctx))
+		return nil
+	}}
+	c, err := NewKafkaConsumer(source, Route{Topic: "payments", Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The group starts at the beginning of the stream, so this is consumed
+	err = client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: "payments",
+		ID:     "1700000000000-0",
+		Values: []string{"value", "payment", "key", "user-1", "ce-type", "payment_succeeded"},
+	}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, c)
+	waitFor(t, "the entry to be acked", func() bool {
+		return len(handler.handled()) == 1 && pendingEntries(t, client, "payments") == 0
+	})
+	stop()
+
+	msg := received[0]
+	if msg.Offset != 1700000000000 || string(msg.Key) != "user-1" || string(msg.Value) != "payment" || msg.deliveries != 1 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if want := []Header{{Key: "ce-type", Value: []byte("payment_succeeded")}}; !slices.EqualFunc(msg.Headers, want, func(a, b Header) bool {
+		return a.Key == b.Key && string(a.Value) == string(b.Value)
+	}) {
+		t.Errorf("headers = %v, want %v", msg.Headers, want)
+	}
+}
+
+func TestRedisSourceDeadLettersAtDeliveryLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		sinkErr    error
+		wantLetter bool
+	}{
+		{name: "dead letter saved", wantLetter: true},
+		{name: "dead letter failed", sinkErr: errors.New("database down")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+
+			handler := &handlerFunc{fn: func(ctx context.Context, value string) error {
+				return errors.New("smtp unavailable")
+			}}
+			deadLetters := &fakeDeadLetters{err: tt.sinkErr}
+			c, err := NewKafkaConsumer(newTestRedisSource(t, mr.Addr(), 2), Route{
+				Topic:   "payments",
+				Handler: handler,
+				Policy:  ErrorPolicy{Action: Hold, DeadLetters: deadLetters},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.XAdd(context.Background(), &redis.XAddArgs{Stream: "payments", Values: []string{"value", "payment"}}).Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			stop := start(t, c)
+			if tt.wantLetter {
+				waitFor(t, "the entry to be acked", func() bool {
+					return len(handler.handled()) == 2 && pendingEntries(t, client, "payments") == 0
+				})
+			} else {
+				// Claimed again past the limit rather than dropped
+				waitFor(t, "the entry to be claimed again", func() bool { return len(handler.handled()) >= 3 })
+			}
+			stop()
+
+			letters := deadLetters.saved()
+			if !tt.wantLetter {
+				if len(letters) != 0 || pendingEntries(t, client, "payments") != 1 {
+					t.Errorf("saved %d dead letters, want the entry kept pending", len(letters))
+				}
+				return
+			}
+			if len(letters) != 1 || string(letters[0].Payload) != "payment" || letters[0].Error != "smtp unavailable" {
+				t.Errorf("unexpected dead letters: %+v", letters)
+			}
+		})
+	}
+}
+
+func TestRedisSourceRecreatesLostGroups(t *testing.T) {
+	mr := miniredis.RunT(t)
+	source := newTestRedisSource(t, mr.Addr(), 5)
+	if err := source.Subscribe([]string{"payments"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart without persistence loses the stream and its group
+	mr.FlushAll()
+	if _, err := source.Poll(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	mr.XAdd("payments", "*", []string{"value", "payment"})
+
+	waitFor(t, "the entry to be delivered", func() bool {
+		msg, err := source.Poll(10 * time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg != nil && string(msg.Value) == "payment"
+	})
+}
@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"notification-service/internal/domain"
+	"time"
+)
+
+// ErrorAction decides what happens to a message once its handler has failed
+// every attempt allowed by the policy
+type ErrorAction int
+
+const (
+	// Skip logs the failure and moves on; the offset is committed
+	Skip ErrorAction = iota
+	// Hold leaves the offset uncommitted and pauses the partition, so the
+	// message is consumed again after a restart
+	Hold
+)
+
+// ErrorPolicy is how a route treats handler errors
+type ErrorPolicy struct {
+	// MaxAttempts is how often the handler runs per message; 0 means once
+	MaxAttempts int
+	// Backoff is slept before the second attempt and doubled afterwards
+	Backoff time.Duration
+	Action  ErrorAction
+	// DeadLetters receives the messages the route gives up on, permanent
+	// failures included; they are only logged when nil
+	DeadLetters DeadLetterSink
+}
+
+// DeadLetterSink stores messages with their raw payload for diagnostics
+type DeadLetterSink interface {
+	SaveDeadLetter(ctx context.Context, letter domain.DeadLetter) error
+}
+
+// Route sends the messages of a topic to its handler
+type Route struct {
+	// Topic is a topic name, or a regular expression when it starts with "^"
+	Topic   string
+	Handler MessageHandler
+	Policy  ErrorPolicy
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix, such as a malformed
+// message. Such messages are skipped whatever the route's policy says.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,95 @@
+package consumer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSourceClosed is returned by a source that has been closed
+var ErrSourceClosed = errors.New("message source closed")
+
+// TopicPartition identifies one ordered stream of messages
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// Header is a message header; keys may repeat
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a message read from a MessageSource
+type Message struct {
+	Topic     string
+	Partition int32
+	// Offset orders the messages of a partition: the Kafka offset, the
+	// JetStream stream sequence or the millisecond part of a Redis entry ID
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Timestamp time.Time
+
+	// receipt is what the source acks the message with: a jetstream.Msg or
+	// a Redis entry ID
+	receipt any
+	// deliveries counts how often the source delivered the message, if it knows
+	deliveries int
+	// userID is the user whose data the message carries, once a handler knows it
+	userID string
+}
+
+// TopicPartition returns the stream msg belongs to
+func (m *Message) TopicPartition() TopicPartition {
+	return TopicPartition{Topic: m.Topic, Partition: m.Partition}
+}
+
+// MessageSource is the broker a KafkaConsumer reads from
+type MessageSource interface {
+	// Subscribe replaces the subscription; a topic starting with "^" is a
+	// regular expression
+	Subscribe(topics []string) error
+	// Poll waits up to timeout for the next message and returns nil when none
+	// arrived. It returns an error only when the source cannot continue.
+	Poll(timeout time.Duration) (*Message, error)
+	// Commit marks msg as processed. KafkaConsumer commits the messages of a
+	// partition in order, so offset based sources may commit every earlier
+	// message with it.
+	Commit(msg *Message) error
+	// Assignment returns the partitions the source currently delivers from
+	Assignment() ([]TopicPartition, error)
+	// Pause stops delivery from the partitions until they are resumed
+	Pause(partitions []TopicPartition) error
+	Resume(partitions []TopicPartition) error
+	Close() error
+}
+
+// subjectPartitions maps the topics of sources without partitions to partition 0
+func subjectPartitions(topics []string) []TopicPartition {
+	partitions := make([]TopicPartition, len(topics))
+	for i, topic := range topics {
+		partitions[i] = TopicPartition{Topic: topic}
+	}
+	return partitions
+}
+
+// Redeliverer is implemented by sources that redeliver single messages, like
+// JetStream and Redis Streams. A message held by its route's error policy is
+// handed back with Nack instead of pausing its partition. Once it failed on
+// its last delivery, it is moved to the route's dead letters and committed.
+type Redeliverer interface {
+	// Nack asks the source to deliver msg again
+	Nack(msg *Message) error
+	// LastDelivery reports whether msg reached the source's delivery limit
+	LastDelivery(msg *Message) bool
+}
+
+// Rebalancer is implemented by sources whose partitions move between the
+// consumers of a group, like Kafka. The callbacks run within Poll: revoked
+// before the partitions are taken away, assigned once they are delivered
+// from, unpaused.
+type Rebalancer interface {
+	OnRebalance(assigned, revoked func(partitions []TopicPartition))
+}
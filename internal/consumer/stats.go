@@ -0,0 +1,61 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+)
+
+// TopicStats counts what happened to the messages of one topic
+type TopicStats struct {
+	Received uint64 `json:"received"`
+	Handled  uint64 `json:"handled"`
+	Retried  uint64 `json:"retried"`
+	Skipped  uint64 `json:"skipped"`
+	// DeadLettered counts messages given up on and saved to the dead letters
+	DeadLettered uint64 `json:"dead_lettered"`
+	Held         uint64 `json:"held"`
+	// Redelivered counts held messages handed back to a Redeliverer source
+	Redelivered uint64        `json:"redelivered"`
+	Unrouted    uint64        `json:"unrouted"`
+	TotalTime   time.Duration `json:"total_time_ns"`
+	LastError   string        `json:"last_error,omitempty"`
+	// LastErrorAt is nil until a handler has failed
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+type stats struct {
+	mu     sync.Mutex
+	topics map[string]*TopicStats
+}
+
+func (s *stats) update(topic string, fn func(*TopicStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.topics == nil {
+		s.topics = make(map[string]*TopicStats)
+	}
+	t, ok := s.topics[topic]
+	if !ok {
+		t = &TopicStats{}
+		s.topics[topic] = t
+	}
+	fn(t)
+}
+
+func (s *stats) recordError(topic string, err error) {
+	now := time.Now()
+	s.update(topic, func(t *TopicStats) {
+		t.LastError = err.Error()
+		t.LastErrorAt = &now
+	})
+}
+
+func (s *stats) snapshot() map[string]TopicStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]TopicStats, len(s.topics))
+	for topic, t := range s.topics {
+		out[topic] = *t
+	}
+	return out
+}
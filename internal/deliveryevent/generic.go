@@ -0,0 +1,99 @@
+package deliveryevent
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"notification-service/internal/domain"
+	"notification-service/internal/webhook"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericProvider accepts our own callback format, signed the same way as
+// the webhooks we send to partners (see webhook.SignatureHeader)
+type GenericProvider struct {
+	secret string
+	maxAge time.Duration
+}
+
+func NewGenericProvider(secret string, maxAge time.Duration) *GenericProvider {
+	return &GenericProvider{secret: secret, maxAge: maxAge}
+}
+
+func (p *GenericProvider) Name() string { return "generic" }
+
+func (p *GenericProvider) Verify(header http.Header, body []byte) error {
+	sig := header.Get(webhook.SignatureHeader)
+	var ts int64
+	for _, part := range strings.Split(sig, ",") {
+		if v, ok := strings.CutPrefix(part, "t="); ok {
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		}
+	}
+	if ts == 0 {
+		return ErrInvalidSignature
+	}
+
+	sent := time.Unix(ts, 0)
+	if age := time.Since(sent); age > p.maxAge || age < -p.maxAge {
+		return ErrStaleTimestamp
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhook.Sign(p.secret, sent, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type genericEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type genericPayload struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+var genericStatuses = map[string]domain.EmailStatus{
+	"delivered":  domain.StatusDelivered,
+	"opened":     domain.StatusOpened,
+	"clicked":    domain.StatusClicked,
+	"bounced":    domain.StatusBounced,
+	"complained": domain.StatusComplained,
+}
+
+func (p *GenericProvider) Parse(body []byte) ([]domain.DeliveryEvent, error) {
+	var payload genericPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode events: %w", err)
+	}
+
+	events := make([]domain.DeliveryEvent, 0, len(payload.Events))
+	for _, raw := range payload.Events {
+		var e genericEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		status, ok := genericStatuses[e.Type]
+		if !ok || e.ID == "" || e.MessageID == "" {
+			continue
+		}
+		events = append(events, domain.DeliveryEvent{
+			Provider:        p.Name(),
+			ProviderEventID: e.ID,
+			MessageID:       normalizeMessageID(e.MessageID),
+			Status:          status,
+			Recipient:       e.Recipient,
+			Reason:          e.Reason,
+			OccurredAt:      time.Unix(e.Timestamp, 0).UTC(),
+			Payload:         raw,
+		})
+	}
+	return events, nil
+}
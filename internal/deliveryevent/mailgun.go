@@ -0,0 +1,129 @@
+package deliveryevent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"notification-service/internal/domain"
+	"strconv"
+	"time"
+)
+
+// MailgunProvider handles Mailgun webhooks, which are signed with an
+// HMAC-SHA256 of timestamp and token inside the payload
+type MailgunProvider struct {
+	signingKey string
+	maxAge     time.Duration
+}
+
+func NewMailgunProvider(signingKey string, maxAge time.Duration) *MailgunProvider {
+	return &MailgunProvider{signingKey: signingKey, maxAge: maxAge}
+}
+
+func (p *MailgunProvider) Name() string { return "mailgun" }
+
+type mailgunPayload struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData json.RawMessage `json:"event-data"`
+}
+
+type mailgunEvent struct {
+	ID        string  `json:"id"`
+	Event     string  `json:"event"`
+	Severity  string  `json:"severity"`
+	Recipient string  `json:"recipient"`
+	Timestamp float64 `json:"timestamp"`
+	Message   struct {
+		Headers struct {
+			MessageID string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+	DeliveryStatus struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+		Message     string `json:"message"`
+	} `json:"delivery-status"`
+}
+
+func (p *MailgunProvider) Verify(_ http.Header, body []byte) error {
+	var payload mailgunPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(payload.Signature.Timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > p.maxAge || age < -p.maxAge {
+		return ErrStaleTimestamp
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.signingKey))
+	mac.Write([]byte(payload.Signature.Timestamp + payload.Signature.Token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(payload.Signature.Signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *MailgunProvider) Parse(body []byte) ([]domain.DeliveryEvent, error) {
+	var payload mailgunPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	var e mailgunEvent
+	if err := json.Unmarshal(payload.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode event data: %w", err)
+	}
+
+	var status domain.EmailStatus
+	reason := ""
+	switch e.Event {
+	case "delivered":
+		status = domain.StatusDelivered
+	case "opened":
+		status = domain.StatusOpened
+	case "clicked":
+		status = domain.StatusClicked
+	case "complained":
+		status = domain.StatusComplained
+	case "failed":
+		// Temporary failures are retried by Mailgun and may still be delivered
+		if e.Severity != "permanent" {
+			return nil, nil
+		}
+		status = domain.StatusBounced
+		reason = fmt.Sprintf("%d %s", e.DeliveryStatus.Code, e.DeliveryStatus.Description)
+		if e.DeliveryStatus.Description == "" {
+			reason = fmt.Sprintf("%d %s", e.DeliveryStatus.Code, e.DeliveryStatus.Message)
+		}
+	default:
+		return nil, nil
+	}
+
+	if e.ID == "" || e.Message.Headers.MessageID == "" {
+		return nil, nil
+	}
+
+	sec, frac := math.Modf(e.Timestamp)
+	return []domain.DeliveryEvent{{
+		Provider:        p.Name(),
+		ProviderEventID: e.ID,
+		MessageID:       normalizeMessageID(e.Message.Headers.MessageID),
+		Status:          status,
+		Recipient:       e.Recipient,
+		Reason:          reason,
+		OccurredAt:      time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+		Payload:         payload.EventData,
+	}}, nil
+}
@@ -0,0 +1,32 @@
+package deliveryevent
+
+import (
+	"errors"
+	"net/http"
+	"notification-service/internal/domain"
+	"strings"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleTimestamp   = errors.New("timestamp outside of the allowed window")
+)
+
+// Provider verifies and normalizes the callbacks of one email provider
+type Provider interface {
+	Name() string
+	// Verify checks that body was signed by the provider
+	Verify(header http.Header, body []byte) error
+	// Parse turns the callback body into normalized events. Events that do
+	// not affect the email status are dropped.
+	Parse(body []byte) ([]domain.DeliveryEvent, error)
+}
+
+// normalizeMessageID wraps bare message IDs in angle brackets, the form we store
+func normalizeMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.HasPrefix(id, "<") {
+		return id
+	}
+	return "<" + id + ">"
+}
@@ -0,0 +1,111 @@
+package deliveryevent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"notification-service/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxBodySize bounds provider callbacks; batches from real providers are far smaller
+const maxBodySize = 1 << 20
+
+// Repository defines the interface for storing delivery events
+type Repository interface {
+	Record(ctx context.Context, ev domain.DeliveryEvent) error
+}
+
+// SuppressionRepository defines the interface for suppressing bounced and complaining recipients
+type SuppressionRepository interface {
+	Add(ctx context.Context, s domain.SuppressedRecipient) error
+}
+
+// Receiver is the HTTP endpoint providers post delivery events to. The
+// provider is taken from the {provider} path segment.
+type Receiver struct {
+	providers    map[string]Provider
+	repo         Repository
+	suppressions SuppressionRepository
+}
+
+func NewReceiver(providers []Provider, repo Repository, suppressions SuppressionRepository) *Receiver {
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &Receiver{providers: byName, repo: repo, suppressions: suppressions}
+}
+
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	provider, ok := rc.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "unknown provider", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := provider.Verify(r.Header, body); err != nil {
+		log.WithError(err).WithField("provider", provider.Name()).Warn("Rejected delivery event callback")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	events, err := provider.Parse(body)
+	if err != nil {
+		log.WithError(err).WithField("provider", provider.Name()).Warn("Failed to parse delivery events")
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, ev := range events {
+		if err := rc.handle(r.Context(), ev); err != nil {
+			log.WithError(err).WithField("provider", provider.Name()).Error("Failed to record delivery event")
+			// A non-2xx response makes the provider retry; recording is idempotent
+			http.Error(w, "failed to record event", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rc *Receiver) handle(ctx context.Context, ev domain.DeliveryEvent) error {
+	if err := rc.repo.Record(ctx, ev); err != nil {
+		return err
+	}
+
+	var source domain.SuppressionSource
+	switch ev.Status {
+	case domain.StatusBounced:
+		source = domain.SuppressionBounce
+	case domain.StatusComplained:
+		source = domain.SuppressionComplaint
+	default:
+		return nil
+	}
+	if ev.Recipient == "" {
+		// The event is recorded; failing would only make the provider retry it forever
+		log.WithFields(log.Fields{
+			"provider":   ev.Provider,
+			"message_id": ev.MessageID,
+			"status":     ev.Status,
+		}).Warn("Bounce or complaint without recipient, nothing to suppress")
+		return nil
+	}
+
+	reason := string(ev.Status) + " reported by " + ev.Provider
+	if ev.Reason != "" {
+		reason += ": " + ev.Reason
+	}
+	return rc.suppressions.Add(ctx, domain.SuppressedRecipient{
+		Address: ev.Recipient,
+		Reason:  reason,
+		Source:  source,
+	})
+}
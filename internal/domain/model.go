@@ -1,11 +1,15 @@
 package domain
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type PurchaseInfo struct {
 	TransactionID  string `json:"transaction_id"`
 	UserID         string `json:"user_id"`
 	UserEmail      string `json:"user_email"`
+	UserPhone      string `json:"user_phone,omitempty"` // E.164, e.g. +77011234567
 	CoinsPurchased int    `json:"coins_purchased"`
 	Provider       string `json:"provider"`
 	Country        string `json:"country"`
@@ -18,8 +22,11 @@ type RefundInfo struct {
 	TransactionID string `json:"transaction_id"`
 	UserID        string `json:"user_id"`
 	UserEmail     string `json:"user_email"`
+	UserPhone     string `json:"user_phone,omitempty"` // E.164, e.g. +77011234567
+	Country       string `json:"country,omitempty"`
 	Amount        int64  `json:"amount"`         // in cents
 	CoinsDeducted int64  `json:"coins_deducted"` // coins that were deducted
+	Provider      string `json:"provider,omitempty"`
 	Reason        string `json:"reason,omitempty"`
 	ProcessedAt   string `json:"processed_at"` // ISO 8601 timestamp
 }
@@ -27,14 +34,355 @@ type RefundInfo struct {
 type EmailStatus string
 
 const (
-	StatusSent   EmailStatus = "sent"
-	StatusFailed EmailStatus = "failed"
+	StatusSent       EmailStatus = "sent"
+	StatusFailed     EmailStatus = "failed"
+	StatusSuppressed EmailStatus = "suppressed"
+	StatusBounced    EmailStatus = "bounced"
+	StatusDelivered  EmailStatus = "delivered"
+	StatusOpened     EmailStatus = "opened"
+	StatusClicked    EmailStatus = "clicked"
+	StatusComplained EmailStatus = "complained"
 )
 
+// statusRank orders the statuses an email moves through after it was sent
+var statusRank = map[EmailStatus]int{
+	StatusSent:      1,
+	StatusDelivered: 2,
+	StatusOpened:    3,
+	StatusClicked:   4,
+}
+
+// terminalStatuses never change once reached
+var terminalStatuses = map[EmailStatus]bool{
+	StatusFailed:     true,
+	StatusSuppressed: true,
+	StatusBounced:    true,
+	StatusComplained: true,
+}
+
+// TerminalStatuses returns the statuses that never change once reached
+func TerminalStatuses() []EmailStatus {
+	statuses := make([]EmailStatus, 0, len(terminalStatuses))
+	for status := range terminalStatuses {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// CanTransitionTo reports whether an email in status s may move to next.
+// Statuses only move forward, so events arriving out of order never
+// regress an email, and terminal statuses are final.
+func (s EmailStatus) CanTransitionTo(next EmailStatus) bool {
+	if terminalStatuses[s] || s == next {
+		return false
+	}
+	if terminalStatuses[next] {
+		return true
+	}
+	return statusRank[next] > statusRank[s]
+}
+
 type EmailLog struct {
-	TransactionID  string
-	RecipientEmail string
-	Subject        string
-	Status         EmailStatus
-	ErrorMessage   sql.NullString
+	TransactionID     string
+	EventType         EventType
+	RefundID          string
+	UserID            string
+	RecipientEmail    string
+	Subject           string
+	MessageID         string
+	Attempts          int
+	Provider          string
+	ProviderMessageID string
+	TemplateName      string
+	TemplateVersion   int
+	Locale            string
+	Duration          time.Duration
+	BodyHash          string // hex SHA-256 of the rendered body
+	BodyID            string // email_bodies row holding the rendered message, if stored
+	Status            EmailStatus
+	ErrorMessage      sql.NullString
+}
+
+// EventType identifies the business event a notification is sent for
+type EventType string
+
+const (
+	EventPurchase EventType = "purchase"
+	EventRefund   EventType = "refund"
+)
+
+// Notification is an event of any registered type reduced to what is needed
+// to notify the user about it
+type Notification struct {
+	Type          EventType
+	TransactionID string
+	RefundID      string
+	UserID        string
+	Email         string
+	Phone         string
+	Country       string
+	Template      string
+	TemplateData  any
+	// WebhookData is published to partner webhooks; nil publishes nothing
+	WebhookData any
+	// Payload is the decoded event, passed to the alert rules
+	Payload any
+}
+
+// Channel identifies the delivery channel of a notification
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// NotificationLog is the channel-agnostic outcome of a single notification
+type NotificationLog struct {
+	TransactionID string
+	EventType     EventType
+	RefundID      string // set for refunds, which a transaction may have several of
+	Channel       Channel
+	Recipient     string
+	Status        EmailStatus
+	ErrorMessage  sql.NullString
+	BodyID        string // email_bodies row holding the rendered message, if stored
+}
+
+// DeviceToken is a push token registered by one of the user's devices
+type DeviceToken struct {
+	UserID     string    `json:"user_id"`
+	Token      string    `json:"token"`
+	Platform   string    `json:"platform"` // "ios" or "android"
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// WebhookEndpoint is a partner endpoint subscribed to notification events
+type WebhookEndpoint struct {
+	ID           string `json:"id"`
+	SubscriberID string `json:"subscriber_id"`
+	URL          string `json:"url"`
+	// Secret is only returned when the endpoint is created
+	Secret              string    `json:"secret,omitempty"`
+	EventTypes          []string  `json:"event_types"`
+	Enabled             bool      `json:"enabled"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+	WebhookFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery records the outcome of delivering one event to one endpoint
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	EndpointID     string                `json:"endpoint_id"`
+	SubscriberID   string                `json:"subscriber_id"`
+	EventID        string                `json:"event_id"`
+	EventType      EventType             `json:"event_type"`
+	Attempts       int                   `json:"attempts"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	ErrorMessage   string                `json:"error_message,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+// Category groups notifications for opt-in and opt-out purposes
+type Category string
+
+const (
+	CategoryTransactional Category = "transactional"
+	CategoryMarketing     Category = "marketing"
+	CategoryAlerts        Category = "alerts"
+)
+
+// NotificationPreferences are the user's choices about what we send and how
+type NotificationPreferences struct {
+	UserID             string  `json:"user_id"`
+	TransactionalOptIn bool    `json:"transactional_opt_in"`
+	MarketingOptIn     bool    `json:"marketing_opt_in"`
+	AlertsOptIn        bool    `json:"alerts_opt_in"`
+	PreferredChannel   Channel `json:"preferred_channel,omitempty"`
+	PreferredLanguage  string  `json:"preferred_language,omitempty"`
+	// QuietHoursStart and QuietHoursEnd are "HH:MM" in Timezone; empty disables quiet hours
+	QuietHoursStart string    `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string    `json:"quiet_hours_end,omitempty"`
+	Timezone        string    `json:"timezone,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DefaultPreferences applies to users who never saved any preferences
+func DefaultPreferences(userID string) NotificationPreferences {
+	return NotificationPreferences{
+		UserID:             userID,
+		TransactionalOptIn: true,
+		AlertsOptIn:        true,
+	}
+}
+
+func (p NotificationPreferences) OptedIn(category Category) bool {
+	switch category {
+	case CategoryTransactional:
+		return p.TransactionalOptIn
+	case CategoryMarketing:
+		return p.MarketingOptIn
+	case CategoryAlerts:
+		return p.AlertsOptIn
+	}
+	return false
+}
+
+// InQuietHours reports whether now falls into the user's quiet hours.
+// Windows that cross midnight, such as 22:00-08:00, are supported.
+func (p NotificationPreferences) InQuietHours(now time.Time) bool {
+	start, errStart := time.Parse("15:04", p.QuietHoursStart)
+	end, errEnd := time.Parse("15:04", p.QuietHoursEnd)
+	if errStart != nil || errEnd != nil || start.Equal(end) {
+		return false
+	}
+
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			now = now.In(loc)
+		}
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// PreferenceOverride records a mandatory notification sent despite an opt-out
+type PreferenceOverride struct {
+	UserID        string
+	EventType     EventType
+	TransactionID string
+	Category      Category
+	Reason        string
+}
+
+type SuppressionSource string
+
+const (
+	SuppressionManual    SuppressionSource = "manual"
+	SuppressionBounce    SuppressionSource = "bounce"
+	SuppressionComplaint SuppressionSource = "complaint"
+)
+
+// SuppressedRecipient is an address we must not send to until it expires
+type SuppressedRecipient struct {
+	Address   string            `json:"address"`
+	Reason    string            `json:"reason"`
+	Source    SuppressionSource `json:"source"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// DeliveryEvent is a provider callback about an email, normalized across providers
+type DeliveryEvent struct {
+	Provider        string
+	ProviderEventID string
+	MessageID       string
+	Status          EmailStatus
+	Recipient       string
+	Reason          string
+	OccurredAt      time.Time
+	Payload         []byte
+}
+
+// EmailBody is a rendered message exactly as it was sent
+type EmailBody struct {
+	ID        string     `json:"id"`
+	Subject   string     `json:"subject"`
+	Text      string     `json:"text"`
+	HTML      string     `json:"html,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// UserDeletion is published when a user deletes their account
+type UserDeletion struct {
+	UserID    string `json:"user_id"`
+	UserEmail string `json:"user_email,omitempty"`
+	UserPhone string `json:"user_phone,omitempty"`
+}
+
+// PseudonymPrefix starts every recipient that replaced an erased address
+const PseudonymPrefix = "erased:"
+
+type ErasureSource string
+
+const (
+	ErasureKafka ErasureSource = "kafka"
+	ErasureAdmin ErasureSource = "admin"
+)
+
+// ErasureRecord is the audit entry written for every erasure run, with the
+// number of rows removed or pseudonymized per table
+type ErasureRecord struct {
+	ID           string           `json:"id"`
+	UserID       string           `json:"user_id"`
+	Source       ErasureSource    `json:"source"`
+	AffectedRows map[string]int64 `json:"affected_rows"`
+	ErasedAt     time.Time        `json:"erased_at"`
+}
+
+// KeyPurpose tells data keys, which encrypt PII columns, from the blind index key
+type KeyPurpose string
+
+const (
+	KeyPurposeData  KeyPurpose = "data"
+	KeyPurposeIndex KeyPurpose = "index"
+)
+
+// EncryptionKey is a key stored wrapped by the master key it names
+type EncryptionKey struct {
+	ID          string
+	Purpose     KeyPurpose
+	MasterKeyID string
+	Wrapped     []byte
+	CreatedAt   time.Time
+	RetiredAt   *time.Time
+}
+
+// DeadLetter is a message the consumer gave up on, kept with its raw
+// payload for diagnostics
+type DeadLetter struct {
+	ID        string            `json:"id"`
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       []byte            `json:"key,omitempty"`
+	Payload   []byte            `json:"payload"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	UserID    string            `json:"user_id,omitempty"` // owner of the payload, if known; not returned when encrypted
+	Error     string            `json:"error"`
+	// Permanent is false for messages dropped after their retries ran out
+	Permanent bool      `json:"permanent"`
+	Attempts  int       `json:"attempts"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// NotificationStatus is published to other services after every terminal
+// outcome of a notification on one channel
+type NotificationStatus struct {
+	NotificationID string      `json:"notification_id"`
+	EventType      EventType   `json:"event_type"`
+	TransactionID  string      `json:"transaction_id"`
+	RefundID       string      `json:"refund_id,omitempty"`
+	Channel        Channel     `json:"channel"`
+	Status         EmailStatus `json:"status"`
+	Attempt        int         `json:"attempt"`
+	// ErrorClass is a coarse reason for failures, without the error text
+	ErrorClass string    `json:"error_class,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
 }
@@ -0,0 +1,210 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// formatV1 starts every ciphertext: version byte, 16 byte data key ID, nonce, sealed data
+const formatV1 byte = 1
+
+var ErrUnknownKey = errors.New("ciphertext was encrypted with an unknown data key")
+
+// KeyStore defines the interface for persisting wrapped keys
+type KeyStore interface {
+	ListKeys(ctx context.Context) ([]domain.EncryptionKey, error)
+	// CreateKey returns the ID of the new key, or "" when a blind index key already exists
+	CreateKey(ctx context.Context, purpose domain.KeyPurpose, masterKeyID string, wrapped []byte) (string, error)
+	RewrapKey(ctx context.Context, id, masterKeyID string, wrapped []byte) error
+	RetireDataKeys(ctx context.Context, exceptID string) error
+}
+
+type dataKey struct {
+	raw         []byte
+	masterKeyID string
+	aead        cipher.AEAD
+}
+
+// Keyring encrypts PII columns with envelope encryption. Every value is
+// sealed with AES-256-GCM under the active data key; data keys are stored
+// wrapped by the master key. Values also get a blind index, an HMAC under
+// a separate stored key, so equality lookups work without decrypting.
+type Keyring struct {
+	store    KeyStore
+	master   MasterKey
+	masters  map[string]MasterKey
+	keys     map[uuid.UUID]*dataKey
+	active   uuid.UUID
+	indexID  uuid.UUID
+	indexKey []byte
+}
+
+// LoadKeyring unwraps every stored key, creating the first data key and the
+// blind index key when missing. previous master keys are only used to
+// unwrap keys not yet rewrapped by RewrapKeys.
+func LoadKeyring(ctx context.Context, store KeyStore, master MasterKey, previous ...MasterKey) (*Keyring, error) {
+	k := &Keyring{store: store, master: master, masters: map[string]MasterKey{master.ID(): master}}
+	for _, m := range previous {
+		k.masters[m.ID()] = m
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := k.reload(ctx); err != nil {
+			return nil, err
+		}
+		if k.indexKey != nil && k.active != uuid.Nil {
+			return k, nil
+		}
+
+		if k.indexKey == nil {
+			if _, err := k.createKey(ctx, domain.KeyPurposeIndex); err != nil {
+				return nil, err
+			}
+		}
+		if k.active == uuid.Nil {
+			if _, err := k.createKey(ctx, domain.KeyPurposeData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, errors.New("failed to initialize encryption keys")
+}
+
+func (k *Keyring) reload(ctx context.Context) error {
+	stored, err := k.store.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	k.keys = make(map[uuid.UUID]*dataKey, len(stored))
+	k.active, k.indexID, k.indexKey = uuid.Nil, uuid.Nil, nil
+
+	// Keys are listed oldest first, so the last unretired data key wins
+	for _, sk := range stored {
+		id, err := uuid.Parse(sk.ID)
+		if err != nil {
+			return fmt.Errorf("invalid key ID %q: %w", sk.ID, err)
+		}
+		master, ok := k.masters[sk.MasterKeyID]
+		if !ok {
+			return fmt.Errorf("key %s is wrapped by unknown master key %s", sk.ID, sk.MasterKeyID)
+		}
+		raw, err := master.Unwrap(sk.Wrapped)
+		if err != nil {
+			return fmt.Errorf("failed to unwrap key %s: %w", sk.ID, err)
+		}
+		aead, err := newGCM(raw)
+		if err != nil {
+			return err
+		}
+
+		k.keys[id] = &dataKey{raw: raw, masterKeyID: sk.MasterKeyID, aead: aead}
+		switch sk.Purpose {
+		case domain.KeyPurposeIndex:
+			k.indexID, k.indexKey = id, raw
+		case domain.KeyPurposeData:
+			if sk.RetiredAt == nil {
+				k.active = id
+			}
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) createKey(ctx context.Context, purpose domain.KeyPurpose) (string, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	wrapped, err := k.master.Wrap(raw)
+	if err != nil {
+		return "", err
+	}
+	return k.store.CreateKey(ctx, purpose, k.master.ID(), wrapped)
+}
+
+// Encrypt seals plaintext under the active data key. aad binds the
+// ciphertext to its column so it cannot be copied into another one.
+func (k *Keyring) Encrypt(plaintext, aad string) ([]byte, error) {
+	sealed, err := seal(k.keys[k.active].aead, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(k.active)+len(sealed))
+	out = append(out, formatV1)
+	out = append(out, k.active[:]...)
+	return append(out, sealed...), nil
+}
+
+func (k *Keyring) Decrypt(ciphertext []byte, aad string) (string, error) {
+	if len(ciphertext) < 1+len(uuid.Nil) || ciphertext[0] != formatV1 {
+		return "", errors.New("unsupported ciphertext format")
+	}
+	id, _ := uuid.FromBytes(ciphertext[1 : 1+len(uuid.Nil)])
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	plaintext, err := open(key.aead, ciphertext[1+len(uuid.Nil):], []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns the deterministic lookup token of a value. Values are
+// compared case-insensitively, like recipient addresses everywhere else.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ActiveKeyID returns the ID of the data key new values are encrypted with
+func (k *Keyring) ActiveKeyID() []byte {
+	return k.active[:]
+}
+
+// RewrapKeys wraps every key still under a previous master key with the
+// current one and returns how many were rewrapped
+func (k *Keyring) RewrapKeys(ctx context.Context) (int, error) {
+	rewrapped := 0
+	for id, key := range k.keys {
+		if key.masterKeyID == k.master.ID() {
+			continue
+		}
+		wrapped, err := k.master.Wrap(key.raw)
+		if err != nil {
+			return rewrapped, err
+		}
+		if err := k.store.RewrapKey(ctx, id.String(), k.master.ID(), wrapped); err != nil {
+			return rewrapped, err
+		}
+		key.masterKeyID = k.master.ID()
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// RotateDataKey creates a new active data key and retires the others.
+// Retired keys still decrypt existing values until they are re-encrypted.
+func (k *Keyring) RotateDataKey(ctx context.Context) error {
+	id, err := k.createKey(ctx, domain.KeyPurposeData)
+	if err != nil {
+		return err
+	}
+	if err := k.store.RetireDataKeys(ctx, id); err != nil {
+		return err
+	}
+	return k.reload(ctx)
+}
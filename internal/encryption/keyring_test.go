@@ -0,0 +1,223 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"notification-service/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeKeyStore keeps keys in creation order, as ListKeys returns them
+type fakeKeyStore struct {
+	keys []domain.EncryptionKey
+}
+
+func (f *fakeKeyStore) ListKeys(ctx context.Context) ([]domain.EncryptionKey, error) {
+	return f.keys, nil
+}
+
+func (f *fakeKeyStore) CreateKey(ctx context.Context, purpose domain.KeyPurpose, masterKeyID string, wrapped []byte) (string, error) {
+	id := uuid.NewString()
+	f.keys = append(f.keys, domain.EncryptionKey{ID: id, Purpose: purpose, MasterKeyID: masterKeyID, Wrapped: wrapped, CreatedAt: time.Now()})
+	return id, nil
+}
+
+func (f *fakeKeyStore) RewrapKey(ctx context.Context, id, masterKeyID string, wrapped []byte) error {
+	for i := range f.keys {
+		if f.keys[i].ID == id {
+			f.keys[i].MasterKeyID, f.keys[i].Wrapped = masterKeyID, wrapped
+		}
+	}
+	return nil
+}
+
+func (f *fakeKeyStore) RetireDataKeys(ctx context.Context, exceptID string) error {
+	now := time.Now()
+	for i := range f.keys {
+		if f.keys[i].Purpose == domain.KeyPurposeData && f.keys[i].ID != exceptID && f.keys[i].RetiredAt == nil {
+			f.keys[i].RetiredAt = &now
+		}
+	}
+	return nil
+}
+
+func masterKey(t *testing.T, b byte) MasterKey {
+	t.Helper()
+	key, err := NewAESMasterKey(bytes.Repeat([]byte{b}, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewAESMasterKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{name: "valid", size: keySize},
+		{name: "too short", size: 16, wantErr: true},
+		{name: "too long", size: 64, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewAESMasterKey(make([]byte, tt.size))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAESMasterKey() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			wrapped, err := key.Wrap([]byte("data key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := key.Unwrap(wrapped); err != nil || string(got) != "data key" {
+				t.Errorf("Unwrap() = %q, %v, want the wrapped key", got, err)
+			}
+		})
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{name: "valid", encoded: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"},
+		{name: "not base64", encoded: "not a key!", wantErr: true},
+		{name: "wrong size", encoded: "AAAAAAAAAAAAAAAAAAAAAA==", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMasterKey(tt.encoded); (err != nil) != tt.wantErr {
+				t.Errorf("ParseMasterKey() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringDecrypt(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeKeyStore{}
+	k, err := LoadKeyring(ctx, store, masterKey(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := k.Encrypt("user@example.com", "email_logs.recipient_email")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 1
+	unknownKey := bytes.Clone(ciphertext)
+	unknownID := uuid.New()
+	copy(unknownKey[1:], unknownID[:])
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		aad        string
+		wantErr    bool
+	}{
+		{name: "round trip", ciphertext: ciphertext, aad: "email_logs.recipient_email"},
+		{name: "other column", ciphertext: ciphertext, aad: "notification_logs.recipient", wantErr: true},
+		{name: "tampered", ciphertext: tampered, aad: "email_logs.recipient_email", wantErr: true},
+		{name: "unknown key", ciphertext: unknownKey, aad: "email_logs.recipient_email", wantErr: true},
+		{name: "unknown format", ciphertext: append([]byte{2}, ciphertext[1:]...), aad: "email_logs.recipient_email", wantErr: true},
+		{name: "too short", ciphertext: []byte{formatV1}, aad: "email_logs.recipient_email", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Decrypt(tt.ciphertext, tt.aad)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt() = %q, %v, want error %v", got, err, tt.wantErr)
+			}
+			if err == nil && got != "user@example.com" {
+				t.Errorf("Decrypt() = %q, want the plaintext", got)
+			}
+		})
+	}
+	if _, err := k.Decrypt(unknownKey, "email_logs.recipient_email"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() with an unknown key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyringBlindIndex(t *testing.T) {
+	k, err := LoadKeyring(context.Background(), &fakeKeyStore{}, masterKey(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := k.BlindIndex("user@example.com")
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "user@example.com", want: true},
+		{value: " USER@Example.com ", want: true},
+		{value: "other@example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := k.BlindIndex(tt.value) == index; got != tt.want {
+				t.Errorf("BlindIndex(%q) matches = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeKeyStore{}
+	oldMaster, newMaster := masterKey(t, 1), masterKey(t, 2)
+	k, err := LoadKeyring(ctx, store, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := k.BlindIndex("user@example.com")
+	before, err := k.Encrypt("before", "aad")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.RotateDataKey(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(k.ActiveKeyID(), before[1:1+len(uuid.Nil)]) {
+		t.Error("the data key did not change")
+	}
+
+	// A new master key unwraps nothing until the keys are rewrapped
+	if _, err := LoadKeyring(ctx, store, newMaster); err == nil {
+		t.Fatal("LoadKeyring() with only the new master key succeeded")
+	}
+	k, err = LoadKeyring(ctx, store, newMaster, oldMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := k.RewrapKeys(ctx); err != nil || n != 3 {
+		t.Fatalf("RewrapKeys() = %d, %v, want 3 keys", n, err)
+	}
+
+	k, err = LoadKeyring(ctx, store, newMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k.Decrypt(before, "aad"); err != nil || got != "before" {
+		t.Errorf("Decrypt() under a retired key = %q, %v, want the plaintext", got, err)
+	}
+	if k.BlindIndex("user@example.com") != index {
+		t.Error("the blind index changed with the rotation")
+	}
+}
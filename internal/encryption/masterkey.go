@@ -0,0 +1,114 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const keySize = 32
+
+// MasterKey wraps and unwraps the keys stored in the database. Only its ID
+// is persisted, so a KMS can be plugged in behind the same interface.
+type MasterKey interface {
+	ID() string
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+type aesMasterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewAESMasterKey wraps keys with AES-256-GCM under key
+func NewAESMasterKey(key []byte) (MasterKey, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(key))
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &aesMasterKey{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// ParseMasterKey decodes a base64 encoded 32 byte master key
+func ParseMasterKey(encoded string) (MasterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode master key: %w", err)
+	}
+	return NewAESMasterKey(key)
+}
+
+// LoadKeyFile reads the master key from path, generating it on first use.
+// It is meant for local development; production keys come from configuration.
+func LoadKeyFile(path string) (MasterKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate master key: %w", err)
+		}
+		data = []byte(base64.StdEncoding.EncodeToString(key) + "\n")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write key file: %w", err)
+		}
+		log.WithField("path", path).Warn("Generated a new local master key file")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return ParseMasterKey(string(data))
+}
+
+func (k *aesMasterKey) ID() string {
+	return k.id
+}
+
+func (k *aesMasterKey) Wrap(key []byte) ([]byte, error) {
+	return seal(k.aead, key, nil)
+}
+
+func (k *aesMasterKey) Unwrap(wrapped []byte) ([]byte, error) {
+	key, err := open(k.aead, wrapped, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
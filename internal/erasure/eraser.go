@@ -0,0 +1,93 @@
+package erasure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"notification-service/internal/domain"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMissingUserID = errors.New("user_id is required")
+	// ErrMissingKey is returned instead of storing unkeyed hashes, which a
+	// dictionary of addresses would reverse
+	ErrMissingKey = errors.New("pseudonym key is not configured")
+)
+
+// Repository defines the interface for erasing a user's notification data
+type Repository interface {
+	ListAddresses(ctx context.Context, userID string) ([]string, error)
+	Erase(ctx context.Context, userID string, source domain.ErasureSource, addresses, pseudonyms []string) (domain.ErasureRecord, error)
+}
+
+// Eraser handles right-to-erasure requests. Recipients are replaced by a
+// keyed hash, so support can still tell whether two erased rows belonged to
+// the same address without being able to recover it. Running it again for
+// the same user finds nothing left to change and only adds an audit record.
+type Eraser struct {
+	repo Repository
+	key  []byte
+}
+
+func NewEraser(repo Repository, key string) *Eraser {
+	return &Eraser{repo: repo, key: []byte(key)}
+}
+
+func (e *Eraser) Erase(ctx context.Context, req domain.UserDeletion, source domain.ErasureSource) (domain.ErasureRecord, error) {
+	if strings.TrimSpace(req.UserID) == "" {
+		return domain.ErasureRecord{}, ErrMissingUserID
+	}
+	if len(e.key) == 0 {
+		return domain.ErasureRecord{}, ErrMissingKey
+	}
+
+	known, err := e.repo.ListAddresses(ctx, req.UserID)
+	if err != nil {
+		return domain.ErasureRecord{}, err
+	}
+
+	unique := make(map[string]bool)
+	for _, address := range append(known, req.UserEmail, req.UserPhone) {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address == "" || strings.HasPrefix(address, domain.PseudonymPrefix) {
+			continue
+		}
+		unique[address] = true
+	}
+
+	addresses := make([]string, 0, len(unique))
+	for address := range unique {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	pseudonyms := make([]string, len(addresses))
+	for i, address := range addresses {
+		pseudonyms[i] = e.Pseudonymize(address)
+	}
+
+	record, err := e.repo.Erase(ctx, req.UserID, source, addresses, pseudonyms)
+	if err != nil {
+		return record, err
+	}
+
+	log.WithFields(log.Fields{
+		"user_id":       req.UserID,
+		"source":        source,
+		"affected_rows": record.AffectedRows,
+	}).Info("User notification data erased")
+	return record, nil
+}
+
+// Pseudonymize returns the tombstone stored in place of a normalized address
+func (e *Eraser) Pseudonymize(address string) string {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte(address))
+	return domain.PseudonymPrefix + hex.EncodeToString(mac.Sum(nil))
+}
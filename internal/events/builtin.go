@@ -0,0 +1,92 @@
+package events
+
+import (
+	"notification-service/internal/domain"
+	"notification-service/internal/templates"
+	"notification-service/internal/validator"
+)
+
+// purchaseWebhookData is what partners receive about a notified purchase.
+// Contact details of the user are deliberately left out.
+type purchaseWebhookData struct {
+	TransactionID  string `json:"transaction_id"`
+	UserID         string `json:"user_id"`
+	CoinsPurchased int    `json:"coins_purchased"`
+	ProductID      string `json:"product_id,omitempty"`
+	Provider       string `json:"provider,omitempty"`
+	Country        string `json:"country,omitempty"`
+}
+
+type refundWebhookData struct {
+	RefundID      string `json:"refund_id"`
+	TransactionID string `json:"transaction_id"`
+	UserID        string `json:"user_id"`
+	Amount        int64  `json:"amount"`
+	CoinsDeducted int64  `json:"coins_deducted"`
+	Reason        string `json:"reason,omitempty"`
+	ProcessedAt   string `json:"processed_at,omitempty"`
+}
+
+// RegisterBuiltin registers the purchase and refund events
+func RegisterBuiltin(r *Registry) {
+	Register(r, Spec[domain.PurchaseInfo]{
+		Type:     domain.EventPurchase,
+		Topic:    "successful_payments",
+		Template: templates.PurchaseConfirmation,
+		Validate: validator.ValidatePurchaseInfo,
+		Required: []string{"transaction_id", "user_id", "user_email", "coins_purchased"},
+		Recipient: func(p domain.PurchaseInfo) Recipient {
+			return Recipient{UserID: p.UserID, Email: p.UserEmail, Phone: p.UserPhone, Country: p.Country}
+		},
+		TransactionID: func(p domain.PurchaseInfo) string { return p.TransactionID },
+		TemplateData: func(p domain.PurchaseInfo) any {
+			return templates.PurchaseData{
+				ProductID:      p.ProductID,
+				CoinsPurchased: p.CoinsPurchased,
+				TransactionID:  p.TransactionID,
+			}
+		},
+		Webhook: func(p domain.PurchaseInfo) any {
+			return purchaseWebhookData{
+				TransactionID:  p.TransactionID,
+				UserID:         p.UserID,
+				CoinsPurchased: p.CoinsPurchased,
+				ProductID:      p.ProductID,
+				Provider:       p.Provider,
+				Country:        p.Country,
+			}
+		},
+	})
+
+	Register(r, Spec[domain.RefundInfo]{
+		Type:     domain.EventRefund,
+		Topic:    "refund_events",
+		Template: templates.RefundProcessed,
+		Validate: validator.ValidateRefundInfo,
+		Required: []string{"refund_id", "transaction_id", "user_id", "user_email", "amount", "coins_deducted"},
+		Recipient: func(rf domain.RefundInfo) Recipient {
+			return Recipient{UserID: rf.UserID, Email: rf.UserEmail, Phone: rf.UserPhone, Country: rf.Country}
+		},
+		TransactionID: func(rf domain.RefundInfo) string { return rf.TransactionID },
+		RefundID:      func(rf domain.RefundInfo) string { return rf.RefundID },
+		TemplateData: func(rf domain.RefundInfo) any {
+			return templates.RefundData{
+				AmountDollars: float64(rf.Amount) / 100.0,
+				CoinsDeducted: rf.CoinsDeducted,
+				TransactionID: rf.TransactionID,
+				RefundID:      rf.RefundID,
+			}
+		},
+		Webhook: func(rf domain.RefundInfo) any {
+			return refundWebhookData{
+				RefundID:      rf.RefundID,
+				TransactionID: rf.TransactionID,
+				UserID:        rf.UserID,
+				Amount:        rf.Amount,
+				CoinsDeducted: rf.CoinsDeducted,
+				Reason:        rf.Reason,
+				ProcessedAt:   rf.ProcessedAt,
+			}
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"strings"
+)
+
+// DecodeError is a message that can never become an event of its type, so
+// retrying it is pointless
+type DecodeError struct {
+	EventType domain.EventType
+	// Reason is "malformed", "missing_field", "unknown_field", "wrong_type" or "invalid"
+	Reason string
+	// Field is the offending JSON field, when known
+	Field string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("cannot decode %s event: %s field %q: %v", e.EventType, strings.ReplaceAll(e.Reason, "_", " "), e.Field, e.Err)
+	}
+	return fmt.Sprintf("cannot decode %s event: %s: %v", e.EventType, e.Reason, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsDecodeError reports whether err comes from a message that cannot be decoded
+func IsDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
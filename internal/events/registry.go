@@ -0,0 +1,203 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"reflect"
+	"strings"
+)
+
+// Recipient is who an event notifies and how to reach them
+type Recipient struct {
+	UserID  string
+	Email   string
+	Phone   string // E.164, may be empty
+	Country string // ISO code used by the routing rules
+}
+
+// Spec describes an event type whose Kafka messages decode into T
+type Spec[T any] struct {
+	Type     domain.EventType
+	Topic    string
+	Template string
+	// Validate rejects malformed events before anything is sent; optional
+	Validate  func(T) error
+	Recipient func(T) Recipient
+	// TransactionID and RefundID are recorded in the logs; RefundID is optional
+	TransactionID func(T) string
+	RefundID      func(T) string
+	// TemplateData is passed to the template; the payload itself when nil
+	TemplateData func(T) any
+	// Webhook is what partners receive about the event; nothing is published when nil
+	Webhook func(T) any
+	// Required lists the JSON fields a message must carry with a non-null value
+	Required []string
+	// CloudEventType is the type attribute of CloudEvents carrying the event;
+	// the event type itself when empty
+	CloudEventType string
+}
+
+// Definition is a registered event type
+type Definition struct {
+	Type           domain.EventType
+	Topic          string
+	CloudEventType string
+	// Payload is the Go type messages decode into
+	Payload reflect.Type
+	decode  func(message []byte) (domain.Notification, error)
+}
+
+// Decode parses and validates a message of the event type
+func (d Definition) Decode(message []byte) (domain.Notification, error) {
+	return d.decode(message)
+}
+
+// Registry holds the event types the service consumes
+type Registry struct {
+	definitions           []Definition
+	disallowUnknownFields bool
+}
+
+type Option func(*Registry)
+
+// DisallowUnknownFields rejects messages with fields their event type does
+// not define, instead of ignoring them
+func DisallowUnknownFields() Option {
+	return func(r *Registry) {
+		r.disallowUnknownFields = true
+	}
+}
+
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register adds an event type and panics on an incomplete or duplicate
+// spec, so mistakes fail at startup
+func Register[T any](r *Registry, spec Spec[T]) {
+	if spec.Type == "" || spec.Topic == "" || spec.Template == "" || spec.Recipient == nil || spec.TransactionID == nil {
+		panic(fmt.Sprintf("events: incomplete spec for event type %q", spec.Type))
+	}
+	if spec.CloudEventType == "" {
+		spec.CloudEventType = string(spec.Type)
+	}
+	for _, d := range r.definitions {
+		if d.Type == spec.Type || d.Topic == spec.Topic || d.CloudEventType == spec.CloudEventType {
+			panic(fmt.Sprintf("events: event type %q, topic %q or CloudEvents type %q registered twice", spec.Type, spec.Topic, spec.CloudEventType))
+		}
+	}
+	fields := jsonFields(reflect.TypeFor[T]())
+	for _, name := range spec.Required {
+		if !fields[name] {
+			panic(fmt.Sprintf("events: required field %q is not a field of event type %q", name, spec.Type))
+		}
+	}
+	disallowUnknownFields := r.disallowUnknownFields
+
+	r.definitions = append(r.definitions, Definition{
+		Type:           spec.Type,
+		Topic:          spec.Topic,
+		CloudEventType: spec.CloudEventType,
+		Payload:        reflect.TypeFor[T](),
+		decode: func(message []byte) (domain.Notification, error) {
+			payload, err := decodeStrict[T](message, spec.Required, disallowUnknownFields)
+			if err != nil {
+				err.EventType = spec.Type
+				return domain.Notification{}, err
+			}
+			if spec.Validate != nil {
+				if err := spec.Validate(payload); err != nil {
+					return domain.Notification{}, &DecodeError{EventType: spec.Type, Reason: "invalid", Err: err}
+				}
+			}
+
+			recipient := spec.Recipient(payload)
+			n := domain.Notification{
+				Type:          spec.Type,
+				TransactionID: spec.TransactionID(payload),
+				UserID:        recipient.UserID,
+				Email:         recipient.Email,
+				Phone:         recipient.Phone,
+				Country:       recipient.Country,
+				Template:      spec.Template,
+				TemplateData:  payload,
+				Payload:       payload,
+			}
+			if spec.RefundID != nil {
+				n.RefundID = spec.RefundID(payload)
+			}
+			if spec.TemplateData != nil {
+				n.TemplateData = spec.TemplateData(payload)
+			}
+			if spec.Webhook != nil {
+				n.WebhookData = spec.Webhook(payload)
+			}
+			return n, nil
+		},
+	})
+}
+
+// Definitions returns the registered event types in registration order
+func (r *Registry) Definitions() []Definition {
+	return r.definitions
+}
+
+// decodeStrict decodes a JSON object into T after checking that every
+// required field is present. Every failure is a *DecodeError.
+func decodeStrict[T any](message []byte, required []string, disallowUnknownFields bool) (T, *DecodeError) {
+	var payload T
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return payload, &DecodeError{Reason: "malformed", Err: err}
+	}
+	for _, name := range required {
+		if v, ok := fields[name]; !ok || string(v) == "null" {
+			return payload, &DecodeError{Reason: "missing_field", Field: name, Err: errors.New("field is required")}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(message))
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&payload); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return payload, &DecodeError{Reason: "wrong_type", Field: typeErr.Field, Err: fmt.Errorf("got JSON %s, want %s", typeErr.Value, typeErr.Type)}
+		}
+		// encoding/json reports unknown fields only in the error text
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return payload, &DecodeError{Reason: "unknown_field", Field: strings.Trim(field, `"`), Err: errors.New("field is not part of the event")}
+		}
+		return payload, &DecodeError{Reason: "malformed", Err: err}
+	}
+	return payload, nil
+}
+
+// jsonFields returns the JSON names of the fields of struct type t
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"notification-service/internal/cloudevents"
+	"notification-service/internal/consumer"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Dispatcher handles the messages of several event types. A CloudEvent, in
+// binary or structured mode, goes with its data to the handler of its type
+// attribute, whatever topic it arrived on. A plain payload goes to the
+// handler of its topic.
+type Dispatcher struct {
+	byType  map[string]consumer.MessageHandler
+	byTopic map[string]consumer.MessageHandler
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		byType:  make(map[string]consumer.MessageHandler),
+		byTopic: make(map[string]consumer.MessageHandler),
+	}
+}
+
+// Handle registers the handler of a CloudEvents type and of the topic its
+// plain payloads arrive on; topic may be empty
+func (d *Dispatcher) Handle(eventType, topic string, h consumer.MessageHandler) {
+	d.byType[eventType] = h
+	if topic != "" {
+		d.byTopic[topic] = h
+	}
+}
+
+func (d *Dispatcher) HandleMessage(ctx context.Context, message []byte) error {
+	msg := consumer.MessageFrom(ctx)
+	var (
+		topic   string
+		headers map[string]string
+	)
+	if msg != nil {
+		topic = msg.Topic
+		headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headers[strings.ToLower(h.Key)] = string(h.Value)
+		}
+	}
+
+	event, ok, err := cloudevents.Parse(message, headers)
+	if err != nil {
+		log.WithError(err).WithField("topic", topic).Error("Failed to parse CloudEvent")
+		return consumer.Permanent(err)
+	}
+	if !ok {
+		h, found := d.byTopic[topic]
+		if !found {
+			return consumer.Permanent(fmt.Errorf("no handler for plain messages on topic %q", topic))
+		}
+		return h.HandleMessage(ctx, message)
+	}
+
+	h, found := d.byType[event.Type]
+	if !found {
+		return consumer.Permanent(fmt.Errorf("no handler for CloudEvents type %q", event.Type))
+	}
+	log.WithFields(log.Fields{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"source":     event.Source,
+	}).Debug("Dispatching CloudEvent")
+	return h.HandleMessage(ctx, event.Data)
+}
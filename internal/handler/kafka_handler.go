@@ -3,43 +3,94 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"notification-service/internal/consumer"
 	"notification-service/internal/domain"
+	"notification-service/internal/erasure"
+	"notification-service/internal/events"
+	"notification-service/internal/schema"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // NotificationService defines the interface for notification business logic
 type NotificationService interface {
-	ProcessPurchase(ctx context.Context, purchase domain.PurchaseInfo) error
-	ProcessRefund(ctx context.Context, refund domain.RefundInfo) error
+	ProcessEvent(ctx context.Context, n domain.Notification) error
 }
 
-type purchaseHandler struct {
-	notificationService NotificationService
+// PayloadDecoder turns schema-encoded payloads into the JSON events decode
+type PayloadDecoder interface {
+	Decode(ctx context.Context, eventType domain.EventType, target reflect.Type, message []byte) ([]byte, error)
 }
 
-type refundHandler struct {
+// eventHandler decodes the messages of one registered event type and
+// passes them to the notification service
+type eventHandler struct {
+	definition          events.Definition
 	notificationService NotificationService
+	payloads            PayloadDecoder
 }
 
-func NewPurchaseHandler(notificationService NotificationService) *purchaseHandler {
-	return &purchaseHandler{notificationService: notificationService}
+// NewEventHandler accepts only JSON payloads when payloads is nil
+func NewEventHandler(definition events.Definition, notificationService NotificationService, payloads PayloadDecoder) *eventHandler {
+	return &eventHandler{definition: definition, notificationService: notificationService, payloads: payloads}
 }
 
-func NewRefundHandler(notificationService NotificationService) *refundHandler {
-	return &refundHandler{notificationService: notificationService}
-}
+func (h *eventHandler) HandleMessage(ctx context.Context, message []byte) error {
+	if h.payloads != nil {
+		var err error
+		message, err = h.payloads.Decode(ctx, h.definition.Type, h.definition.Payload, message)
+		if err != nil {
+			log.WithError(err).WithField("event_type", h.definition.Type).Error("Failed to decode schema-encoded event")
+			if errors.Is(err, schema.ErrInvalidPayload) {
+				return consumer.Permanent(err)
+			}
+			return err
+		}
+	}
 
-func (h *purchaseHandler) HandleMessage(ctx context.Context, message []byte) error {
-	var purchase domain.PurchaseInfo
-	if err := json.Unmarshal(message, &purchase); err != nil {
+	n, err := h.definition.Decode(message)
+	if err != nil {
+		log.WithError(err).WithField("event_type", h.definition.Type).Error("Failed to decode event")
+		// An event that fails validation may still name its user
+		var owner struct {
+			UserID string `json:"user_id"`
+		}
+		if json.Unmarshal(message, &owner) == nil {
+			consumer.SetUserID(ctx, owner.UserID)
+		}
+		// Only a message that cannot become an event is not worth retrying
+		if events.IsDecodeError(err) {
+			return consumer.Permanent(err)
+		}
 		return err
 	}
-	return h.notificationService.ProcessPurchase(ctx, purchase)
+	consumer.SetUserID(ctx, n.UserID)
+	return h.notificationService.ProcessEvent(ctx, n)
 }
 
-func (h *refundHandler) HandleMessage(ctx context.Context, message []byte) error {
-	var refund domain.RefundInfo
-	if err := json.Unmarshal(message, &refund); err != nil {
-		return err
+// UserEraser defines the interface for the right-to-erasure workflow
+type UserEraser interface {
+	Erase(ctx context.Context, req domain.UserDeletion, source domain.ErasureSource) (domain.ErasureRecord, error)
+}
+
+type userDeletedHandler struct {
+	eraser UserEraser
+}
+
+func NewUserDeletedHandler(eraser UserEraser) *userDeletedHandler {
+	return &userDeletedHandler{eraser: eraser}
+}
+
+func (h *userDeletedHandler) HandleMessage(ctx context.Context, message []byte) error {
+	var deletion domain.UserDeletion
+	if err := json.Unmarshal(message, &deletion); err != nil {
+		return consumer.Permanent(err)
+	}
+	_, err := h.eraser.Erase(ctx, deletion, domain.ErasureKafka)
+	if errors.Is(err, erasure.ErrMissingUserID) {
+		return consumer.Permanent(err)
 	}
-	return h.notificationService.ProcessRefund(ctx, refund)
+	return err
 }
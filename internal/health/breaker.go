@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCircuitOpen is returned instead of calling a dependency whose circuit is open
+var ErrCircuitOpen = errors.New("circuit is open")
+
+// Breaker is a circuit breaker for a dependency. It opens after threshold
+// consecutive failures and stays open, failing calls fast, until a probe of
+// the dependency succeeds.
+type Breaker struct {
+	name      string
+	threshold int
+	probe     func(ctx context.Context) error
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	lastErr  error
+}
+
+func NewBreaker(name string, threshold int, probe func(ctx context.Context) error) *Breaker {
+	return &Breaker{name: name, threshold: max(threshold, 1), probe: probe}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen while the circuit is open
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return fmt.Errorf("%s: %w: %v", b.name, ErrCircuitOpen, b.lastErr)
+	}
+	return nil
+}
+
+// Record counts the outcome of a call and returns err, wrapped with
+// ErrCircuitOpen when it is the failure that opened the circuit
+func (b *Breaker) Record(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return nil
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.threshold && !b.open {
+		b.open = true
+		return fmt.Errorf("%s: %w: %w", b.name, ErrCircuitOpen, err)
+	}
+	return err
+}
+
+// Check returns nil while the circuit is closed. An open circuit is closed
+// again when the probe succeeds.
+func (b *Breaker) Check(ctx context.Context) error {
+	if err := b.Allow(); err == nil {
+		return nil
+	}
+	if err := b.probe(ctx); err != nil {
+		return fmt.Errorf("%s is unavailable: %w", b.name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open = false
+	b.failures = 0
+	return nil
+}
@@ -0,0 +1,100 @@
+package logwriter
+
+import (
+	"context"
+	"sync"
+)
+
+// Ack tracks the log rows queued while handling one message, so the message
+// is only acknowledged once every one of them is durable
+type Ack struct {
+	mu      sync.Mutex
+	pending int
+	sealed  bool
+	err     error
+	done    chan struct{}
+}
+
+func NewAck() *Ack {
+	return &Ack{done: make(chan struct{})}
+}
+
+type ackKey struct{}
+
+// WithAck returns a context whose queued log rows are tracked by ack
+func WithAck(ctx context.Context, ack *Ack) context.Context {
+	return context.WithValue(ctx, ackKey{}, ack)
+}
+
+func ackFrom(ctx context.Context) *Ack {
+	ack, _ := ctx.Value(ackKey{}).(*Ack)
+	return ack
+}
+
+// Track registers work other than a log row that must finish before the
+// message of ctx is acknowledged, such as an event awaiting its delivery
+// report. The returned func completes it and does nothing without an Ack.
+func Track(ctx context.Context) func(err error) {
+	ack := ackFrom(ctx)
+	if ack == nil {
+		return func(error) {}
+	}
+	ack.add()
+	return ack.complete
+}
+
+// Seal marks that no more rows will be queued for the message
+func (a *Ack) Seal() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sealed = true
+	a.closeIfDone()
+}
+
+// Fail marks the message as not processed, so its offset is never stored
+func (a *Ack) Fail(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// Done is closed once the ack is sealed and all its rows were written or failed
+func (a *Ack) Done() <-chan struct{} {
+	return a.done
+}
+
+// Err returns the first error that kept a row from becoming durable
+func (a *Ack) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *Ack) add() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending++
+}
+
+func (a *Ack) complete(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending--
+	if err != nil && a.err == nil {
+		a.err = err
+	}
+	a.closeIfDone()
+}
+
+func (a *Ack) closeIfDone() {
+	if !a.sealed || a.pending > 0 {
+		return
+	}
+	select {
+	case <-a.done:
+	default:
+		close(a.done)
+	}
+}
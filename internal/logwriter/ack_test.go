@@ -0,0 +1,95 @@
+package logwriter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAck(t *testing.T) {
+	rowErr := errors.New("database down")
+	tests := []struct {
+		name     string
+		run      func(ctx context.Context, ack *Ack)
+		wantDone bool
+		wantErr  error
+	}{
+		{
+			name:     "no rows",
+			run:      func(ctx context.Context, ack *Ack) { ack.Seal() },
+			wantDone: true,
+		},
+		{
+			name:     "not sealed",
+			run:      func(ctx context.Context, ack *Ack) { Track(ctx)(nil) },
+			wantDone: false,
+		},
+		{
+			name: "row pending",
+			run: func(ctx context.Context, ack *Ack) {
+				Track(ctx)
+				ack.Seal()
+			},
+			wantDone: false,
+		},
+		{
+			name: "rows completed after seal",
+			run: func(ctx context.Context, ack *Ack) {
+				first, second := Track(ctx), Track(ctx)
+				ack.Seal()
+				first(nil)
+				second(nil)
+			},
+			wantDone: true,
+		},
+		{
+			name: "first error kept",
+			run: func(ctx context.Context, ack *Ack) {
+				first, second := Track(ctx), Track(ctx)
+				first(rowErr)
+				second(errors.New("later"))
+				ack.Seal()
+			},
+			wantDone: true,
+			wantErr:  rowErr,
+		},
+		{
+			name: "failed message",
+			run: func(ctx context.Context, ack *Ack) {
+				ack.Fail(context.Canceled)
+				ack.Seal()
+				ack.Seal()
+			},
+			wantDone: true,
+			wantErr:  context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := NewAck()
+			tt.run(WithAck(context.Background(), ack), ack)
+
+			if done := isDone(ack); done != tt.wantDone {
+				t.Errorf("done = %v, want %v", done, tt.wantDone)
+			}
+			if err := ack.Err(); !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Err() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTrackWithoutAck(t *testing.T) {
+	// Work outside of a consumed message has nothing to report to
+	Track(context.Background())(errors.New("ignored"))
+}
+
+func isDone(ack *Ack) bool {
+	select {
+	case <-ack.Done():
+		return true
+	default:
+		return false
+	}
+}
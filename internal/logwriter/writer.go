@@ -0,0 +1,248 @@
+package logwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrClosed = errors.New("log writer is closed")
+
+// maxRetryDelay caps the backoff between attempts to write a failed batch
+const maxRetryDelay = 30 * time.Second
+
+// Store defines the interface for inserting email logs in bulk
+type Store interface {
+	SaveLogs(ctx context.Context, logs []domain.EmailLog) error
+}
+
+type Config struct {
+	// BatchSize flushes the buffer once this many logs are waiting
+	BatchSize int
+	// FlushInterval flushes a partial batch after this long
+	FlushInterval time.Duration
+	// BufferSize is the number of queued logs after which SaveLog blocks
+	BufferSize int
+	RetryDelay time.Duration
+}
+
+type entry struct {
+	log domain.EmailLog
+	ack *Ack
+}
+
+// Writer collects email logs and inserts them in batches in the background.
+// A batch that fails because the database is unavailable is retried until it
+// succeeds, so acks are only completed once their rows are durable.
+type Writer struct {
+	store  Store
+	cfg    Config
+	queue  chan entry
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// failing is the error of the batch being retried, nil while writes succeed
+	failing error
+}
+
+func NewWriter(store Store, cfg Config) *Writer {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Writer{
+		store:  store,
+		cfg:    cfg,
+		queue:  make(chan entry, cfg.BufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go w.run()
+	return w
+}
+
+// SaveLog queues l for the next batch. It blocks while the buffer is full
+// and registers the row with the Ack carried by ctx, if any.
+func (w *Writer) SaveLog(ctx context.Context, l domain.EmailLog) error {
+	ack := ackFrom(ctx)
+	if ack != nil {
+		ack.add()
+	}
+
+	// A row queued once the writer stopped would never be written
+	var err error
+	select {
+	case <-w.stop:
+		err = ErrClosed
+	default:
+		select {
+		case w.queue <- entry{log: l, ack: ack}:
+			return nil
+		case <-w.stop:
+			err = ErrClosed
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if ack != nil {
+		ack.complete(err)
+	}
+	return fmt.Errorf("failed to queue email log: %w", err)
+}
+
+// Close flushes every queued log. When ctx expires first, pending rows are
+// abandoned and their acks fail, leaving the messages uncommitted.
+func (w *Writer) Close(ctx context.Context) error {
+	w.once.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	defer w.cancel()
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entry, 0, w.cfg.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.write(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case e := <-w.queue:
+			batch = append(batch, e)
+			if len(batch) >= w.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.stop:
+			for {
+				select {
+				case e := <-w.queue:
+					batch = append(batch, e)
+					if len(batch) >= w.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write stores batch, retrying while the database is unavailable. A batch
+// rejected because of its data is split up so one bad row cannot block the rest.
+func (w *Writer) write(batch []entry) {
+	logs := make([]domain.EmailLog, len(batch))
+	for i, e := range batch {
+		logs[i] = e.log
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := w.store.SaveLogs(w.ctx, logs)
+		if err == nil || isDataError(err) {
+			w.setFailing(nil)
+		} else {
+			w.setFailing(err)
+		}
+		if err == nil {
+			complete(batch, nil)
+			return
+		}
+
+		if isDataError(err) {
+			if len(batch) > 1 {
+				for i := range batch {
+					w.write(batch[i : i+1])
+				}
+				return
+			}
+			// Same outcome as a failed synchronous insert: logged and dropped
+			log.WithError(err).WithField("transaction_id", batch[0].log.TransactionID).Error("Failed to save email log to database")
+			complete(batch, nil)
+			return
+		}
+
+		delay := w.backoff(attempt)
+		log.WithError(err).WithFields(log.Fields{
+			"attempt": attempt,
+			"rows":    len(batch),
+			"delay":   delay,
+		}).Warn("Failed to write email log batch, retrying...")
+
+		select {
+		case <-time.After(delay):
+		case <-w.ctx.Done():
+			log.WithError(err).WithField("rows", len(batch)).Error("Abandoning email log batch on shutdown")
+			complete(batch, err)
+			return
+		}
+	}
+}
+
+func (w *Writer) setFailing(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.failing = err
+}
+
+// Err returns the error of the batch being retried because the database is
+// unavailable, or nil while writes succeed
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.failing
+}
+
+func (w *Writer) backoff(attempt int) time.Duration {
+	delay := w.cfg.RetryDelay << (attempt - 1)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+func complete(batch []entry, err error) {
+	for _, e := range batch {
+		if e.ack != nil {
+			e.ack.complete(err)
+		}
+	}
+}
+
+// isDataError reports whether Postgres rejected the rows themselves
+// (data exception or constraint violation) rather than being unavailable
+func isDataError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	class := pqErr.Code.Class()
+	return class == "22" || class == "23"
+}
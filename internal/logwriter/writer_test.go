@@ -0,0 +1,146 @@
+package logwriter
+
+import (
+	"context"
+	"errors"
+	"notification-service/internal/domain"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fakeStore fails the next calls with errs in turn and rejects the rows
+// whose transaction ID is in invalid
+type fakeStore struct {
+	mu      sync.Mutex
+	errs    []error
+	invalid map[string]bool
+	batches [][]string
+}
+
+func (f *fakeStore) SaveLogs(ctx context.Context, logs []domain.EmailLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	var ids []string
+	for _, l := range logs {
+		if f.invalid[l.TransactionID] {
+			return &pq.Error{Code: "23502", Message: "null value violates not-null constraint"}
+		}
+		ids = append(ids, l.TransactionID)
+	}
+	f.batches = append(f.batches, ids)
+	return nil
+}
+
+func (f *fakeStore) saved() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.batches)
+}
+
+func TestWriter(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	tests := []struct {
+		name        string
+		cfg         Config
+		errs        []error
+		invalid     []string
+		wantBatches [][]string
+	}{
+		{
+			name:        "full batches",
+			cfg:         Config{BatchSize: 2, FlushInterval: time.Hour, BufferSize: 10},
+			wantBatches: [][]string{{"tx-1", "tx-2"}, {"tx-3"}},
+		},
+		{
+			name:        "partial batch on interval",
+			cfg:         Config{BatchSize: 10, FlushInterval: 10 * time.Millisecond, BufferSize: 10},
+			wantBatches: [][]string{{"tx-1", "tx-2", "tx-3"}},
+		},
+		{
+			name:        "retried while unavailable",
+			cfg:         Config{BatchSize: 3, FlushInterval: time.Hour, BufferSize: 10, RetryDelay: time.Millisecond},
+			errs:        []error{unavailable, unavailable},
+			wantBatches: [][]string{{"tx-1", "tx-2", "tx-3"}},
+		},
+		{
+			name:        "bad row dropped",
+			cfg:         Config{BatchSize: 3, FlushInterval: time.Hour, BufferSize: 10},
+			invalid:     []string{"tx-2"},
+			wantBatches: [][]string{{"tx-1"}, {"tx-3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{errs: tt.errs, invalid: make(map[string]bool)}
+			for _, id := range tt.invalid {
+				store.invalid[id] = true
+			}
+			w := NewWriter(store, tt.cfg)
+
+			ack := NewAck()
+			ctx := WithAck(context.Background(), ack)
+			for _, id := range []string{"tx-1", "tx-2", "tx-3"} {
+				if err := w.SaveLog(ctx, domain.EmailLog{TransactionID: id}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ack.Seal()
+			if tt.cfg.FlushInterval < time.Hour {
+				<-ack.Done()
+			}
+
+			if err := w.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !isDone(ack) || ack.Err() != nil {
+				t.Errorf("ack done = %v with %v, want done without error", isDone(ack), ack.Err())
+			}
+			if got := store.saved(); !slices.EqualFunc(got, tt.wantBatches, slices.Equal) {
+				t.Errorf("saved batches %v, want %v", got, tt.wantBatches)
+			}
+			if err := w.SaveLog(ctx, domain.EmailLog{}); !errors.Is(err, ErrClosed) {
+				t.Errorf("SaveLog() after Close = %v, want ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestWriterCloseAbandonsFailingBatch(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	store := &fakeStore{errs: slices.Repeat([]error{unavailable}, 1000)}
+	w := NewWriter(store, Config{BatchSize: 1, FlushInterval: time.Hour, BufferSize: 10, RetryDelay: time.Millisecond})
+
+	ack := NewAck()
+	if err := w.SaveLog(WithAck(context.Background(), ack), domain.EmailLog{TransactionID: "tx-1"}); err != nil {
+		t.Fatal(err)
+	}
+	ack.Seal()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !errors.Is(w.Err(), unavailable) {
+		t.Fatalf("Err() = %v while the database is down, want %v", w.Err(), unavailable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() = %v, want DeadlineExceeded", err)
+	}
+	// The message of the abandoned row must not be committed
+	if !isDone(ack) || !errors.Is(ack.Err(), unavailable) {
+		t.Errorf("ack done = %v with %v, want done with %v", isDone(ack), ack.Err(), unavailable)
+	}
+}
@@ -0,0 +1,105 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Repository defines the interface for managing monthly email_logs partitions
+type Repository interface {
+	ListMonths(ctx context.Context) ([]time.Time, error)
+	CreateMonth(ctx context.Context, month time.Time) error
+	DropMonth(ctx context.Context, month time.Time) error
+}
+
+type Config struct {
+	Interval time.Duration
+	// MonthsAhead is how many future months always have a partition
+	MonthsAhead int
+	// Retention drops partitions whose newest possible row is older than this; 0 keeps all
+	Retention time.Duration
+}
+
+// Maintainer keeps future partitions created ahead of time and drops expired ones
+type Maintainer struct {
+	repo Repository
+	cfg  Config
+	now  func() time.Time
+}
+
+func NewMaintainer(repo Repository, cfg Config) *Maintainer {
+	return &Maintainer{repo: repo, cfg: cfg, now: time.Now}
+}
+
+// Run maintains partitions on every interval until ctx is cancelled
+func (m *Maintainer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Maintain(ctx); err != nil {
+			log.WithError(err).Error("Partition maintenance failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Partition maintainer stopping due to context cancellation")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain runs one round of partition creation and removal. A month that
+// fails is logged and left for the next round, so it never holds up the
+// others; the failures are returned together.
+func (m *Maintainer) Maintain(ctx context.Context) error {
+	now := m.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	existing, err := m.repo.ListMonths(ctx)
+	if err != nil {
+		return err
+	}
+	have := make(map[time.Time]bool, len(existing))
+	for _, month := range existing {
+		have[month] = true
+	}
+
+	var errs []error
+	for i := 0; i <= m.cfg.MonthsAhead; i++ {
+		month := current.AddDate(0, i, 0)
+		if have[month] {
+			continue
+		}
+		logger := log.WithField("month", month.Format("2006-01"))
+		if err := m.repo.CreateMonth(ctx, month); err != nil {
+			logger.WithError(err).Error("Failed to create email_logs partition")
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info("Created email_logs partition")
+	}
+
+	if m.cfg.Retention <= 0 {
+		return errors.Join(errs...)
+	}
+	cutoff := now.Add(-m.cfg.Retention)
+	for _, month := range existing {
+		// Only drop once every row in the partition is past retention
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		logger := log.WithField("month", month.Format("2006-01"))
+		if err := m.repo.DropMonth(ctx, month); err != nil {
+			logger.WithError(err).Error("Failed to drop expired email_logs partition")
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info("Dropped expired email_logs partition")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	months  []time.Time
+	failing map[time.Time]bool
+
+	created []time.Time
+	dropped []time.Time
+}
+
+func (f *fakeRepository) ListMonths(ctx context.Context) ([]time.Time, error) {
+	return f.months, nil
+}
+
+func (f *fakeRepository) CreateMonth(ctx context.Context, month time.Time) error {
+	if f.failing[month] {
+		return errors.New("default partition holds rows of the month")
+	}
+	f.created = append(f.created, month)
+	return nil
+}
+
+func (f *fakeRepository) DropMonth(ctx context.Context, month time.Time) error {
+	if f.failing[month] {
+		return errors.New("lock timeout")
+	}
+	f.dropped = append(f.dropped, month)
+	return nil
+}
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMaintain(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		months      []time.Time
+		failing     []time.Time
+		wantCreated []time.Time
+		wantDropped []time.Time
+		wantErr     bool
+	}{
+		{
+			name:        "creates missing months",
+			months:      []time.Time{month(2026, 10)},
+			wantCreated: []time.Time{month(2026, 11), month(2026, 12)},
+		},
+		{
+			name:        "drops expired months",
+			months:      []time.Time{month(2026, 7), month(2026, 8), month(2026, 10), month(2026, 11), month(2026, 12)},
+			wantDropped: []time.Time{month(2026, 7)},
+		},
+		{
+			name:        "continues after a failed month",
+			months:      []time.Time{month(2026, 6), month(2026, 7)},
+			failing:     []time.Time{month(2026, 10), month(2026, 6)},
+			wantCreated: []time.Time{month(2026, 11), month(2026, 12)},
+			wantDropped: []time.Time{month(2026, 7)},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{months: tt.months, failing: make(map[time.Time]bool)}
+			for _, m := range tt.failing {
+				repo.failing[m] = true
+			}
+			m := NewMaintainer(repo, Config{MonthsAhead: 2, Retention: 60 * 24 * time.Hour})
+			m.now = func() time.Time { return now }
+
+			if err := m.Maintain(context.Background()); (err != nil) != tt.wantErr {
+				t.Fatalf("Maintain() = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(repo.created, tt.wantCreated) {
+				t.Errorf("created %v, want %v", repo.created, tt.wantCreated)
+			}
+			if !slices.Equal(repo.dropped, tt.wantDropped) {
+				t.Errorf("dropped %v, want %v", repo.dropped, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"notification-service/internal/domain"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// postgresBodyRepository stores rendered messages gzip-compressed
+type postgresBodyRepository struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// NewPostgresBodyRepository creates a body store. Bodies expire after
+// retention; zero keeps them forever.
+func NewPostgresBodyRepository(db *sql.DB, retention time.Duration) *postgresBodyRepository {
+	return &postgresBodyRepository{db: db, retention: retention}
+}
+
+// Save stores the body and returns its ID
+func (r *postgresBodyRepository) Save(ctx context.Context, b domain.EmailBody) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	textGz, err := compress(b.Text)
+	if err != nil {
+		return "", err
+	}
+	var htmlGz interface{}
+	if b.HTML != "" {
+		if htmlGz, err = compress(b.HTML); err != nil {
+			return "", err
+		}
+	}
+	var expiresAt interface{}
+	if r.retention > 0 {
+		expiresAt = time.Now().Add(r.retention)
+	}
+
+	const query = `
+        INSERT INTO email_bodies (subject, text_gz, html_gz, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id;
+    `
+
+	var id string
+	if err := r.db.QueryRowContext(ctx, query, b.Subject, textGz, htmlGz, expiresAt).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to insert email body: %w", err)
+	}
+	return id, nil
+}
+
+// GetByLogID returns the body of the email log with the given ID.
+// Expired bodies are treated as missing even before they are purged.
+func (r *postgresBodyRepository) GetByLogID(ctx context.Context, logID string) (domain.EmailBody, error) {
+	return r.getByLog(ctx, "email_logs", logID)
+}
+
+// GetByNotificationLogID returns the body of the notification log of any
+// channel with the given ID
+func (r *postgresBodyRepository) GetByNotificationLogID(ctx context.Context, logID string) (domain.EmailBody, error) {
+	return r.getByLog(ctx, "notification_logs", logID)
+}
+
+func (r *postgresBodyRepository) getByLog(ctx context.Context, table, logID string) (domain.EmailBody, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+        SELECT b.id, b.subject, b.text_gz, b.html_gz, b.created_at, b.expires_at
+        FROM ` + table + ` l
+        JOIN email_bodies b ON b.id = l.body_id
+        WHERE l.id = $1 AND (b.expires_at IS NULL OR b.expires_at > NOW());
+    `
+
+	var (
+		b              domain.EmailBody
+		textGz, htmlGz []byte
+		expiresAt      sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, logID).Scan(&b.ID, &b.Subject, &textGz, &htmlGz, &b.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.EmailBody{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.EmailBody{}, fmt.Errorf("failed to query email body: %w", err)
+	}
+
+	if b.Text, err = decompress(textGz); err != nil {
+		return domain.EmailBody{}, err
+	}
+	if htmlGz != nil {
+		if b.HTML, err = decompress(htmlGz); err != nil {
+			return domain.EmailBody{}, err
+		}
+	}
+	if expiresAt.Valid {
+		b.ExpiresAt = &expiresAt.Time
+	}
+	return b, nil
+}
+
+// DeleteExpired removes bodies past their retention window
+func (r *postgresBodyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM email_bodies WHERE expires_at <= NOW();`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired email bodies: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func compress(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		return nil, fmt.Errorf("failed to compress email body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress email body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(b []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress email body: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress email body: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+)
+
+// deadLetterPayloadAAD binds encrypted payloads to their column
+const deadLetterPayloadAAD = "dead_letters.payload"
+
+type postgresDeadLetterRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+// NewPostgresDeadLetterRepository stores payloads encrypted when cipher is
+// not nil. Unlike recipients they are not re-encrypted by rotate-keys.
+func NewPostgresDeadLetterRepository(db *sql.DB, cipher FieldCipher) *postgresDeadLetterRepository {
+	return &postgresDeadLetterRepository{db: db, cipher: cipher}
+}
+
+func (r *postgresDeadLetterRepository) SaveDeadLetter(ctx context.Context, letter domain.DeadLetter) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO dead_letters (topic, message_partition, message_offset, message_key, payload, payload_enc, headers, error_message, permanent, attempts, user_id, user_id_bidx)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+    `
+
+	// The user is kept so erasure finds the letter whatever its payload
+	var payload, payloadEnc interface{} = letter.Payload, nil
+	var userID, userIDBidx interface{}
+	if letter.UserID != "" {
+		userID = letter.UserID
+	}
+	if r.cipher != nil {
+		encrypted, err := r.cipher.Encrypt(string(letter.Payload), deadLetterPayloadAAD)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt dead letter payload: %w", err)
+		}
+		payload, payloadEnc = nil, encrypted
+		if letter.UserID != "" {
+			userID, userIDBidx = nil, r.cipher.BlindIndex(letter.UserID)
+		}
+	}
+
+	var headers interface{}
+	if len(letter.Headers) > 0 {
+		encoded, err := json.Marshal(letter.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to encode dead letter headers: %w", err)
+		}
+		headers = encoded
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, letter.Topic, letter.Partition, letter.Offset, letter.Key, payload, payloadEnc, headers, letter.Error, letter.Permanent, letter.Attempts, userID, userIDBidx); err != nil {
+		return fmt.Errorf("failed to insert dead letter: %w", err)
+	}
+	return nil
+}
+
+// ListDeadLetters returns the newest dead letters, of one topic unless topic is empty
+func (r *postgresDeadLetterRepository) ListDeadLetters(ctx context.Context, topic string, limit, offset int) ([]domain.DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, topic, message_partition, message_offset, message_key, payload, payload_enc, headers, user_id, error_message, permanent, attempts, failed_at
+        FROM dead_letters
+        WHERE $1 = '' OR topic = $1
+        ORDER BY failed_at DESC
+        LIMIT $2 OFFSET $3;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, topic, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []domain.DeadLetter{}
+	for rows.Next() {
+		var l domain.DeadLetter
+		var encrypted, headers []byte
+		var userID sql.NullString
+		if err := rows.Scan(&l.ID, &l.Topic, &l.Partition, &l.Offset, &l.Key, &l.Payload, &encrypted, &headers, &userID, &l.Error, &l.Permanent, &l.Attempts, &l.FailedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		l.UserID = userID.String
+		if encrypted != nil {
+			if r.cipher == nil {
+				return nil, fmt.Errorf("failed to read dead letter: encrypted payloads need the encryption keys")
+			}
+			payload, err := r.cipher.Decrypt(encrypted, deadLetterPayloadAAD)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt dead letter payload: %w", err)
+			}
+			l.Payload = []byte(payload)
+		}
+		if headers != nil {
+			if err := json.Unmarshal(headers, &l.Headers); err != nil {
+				return nil, fmt.Errorf("failed to decode dead letter headers: %w", err)
+			}
+		}
+		letters = append(letters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	return letters, nil
+}
+
+// DeleteDeadLetter removes a dead letter once it has been dealt with
+func (r *postgresDeadLetterRepository) DeleteDeadLetter(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1;`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	return n > 0, nil
+}
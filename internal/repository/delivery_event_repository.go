@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type postgresDeliveryEventRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresDeliveryEventRepository(db *sql.DB) *postgresDeliveryEventRepository {
+	return &postgresDeliveryEventRepository{db: db}
+}
+
+// Record appends the event and moves the matching email log forward.
+// Duplicate events are ignored, and the status never moves backwards.
+func (r *postgresDeliveryEventRepository) Record(ctx context.Context, ev domain.DeliveryEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const insertQuery = `
+        INSERT INTO delivery_events (provider, provider_event_id, message_id, status, recipient, reason, occurred_at, payload)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (provider, provider_event_id) DO NOTHING;
+    `
+
+	var payload interface{}
+	if len(ev.Payload) > 0 {
+		payload = string(ev.Payload)
+	}
+	res, err := tx.ExecContext(ctx, insertQuery, ev.Provider, ev.ProviderEventID, ev.MessageID, string(ev.Status),
+		emptyToNil(ev.Recipient), emptyToNil(ev.Reason), ev.OccurredAt, payload)
+	if err != nil {
+		return fmt.Errorf("failed to insert delivery event: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to insert delivery event: %w", err)
+	} else if n == 0 {
+		log.WithField("provider_event_id", ev.ProviderEventID).Debug("Duplicate delivery event ignored")
+		return tx.Commit()
+	}
+
+	var current domain.EmailStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM email_logs WHERE message_id = $1 OR provider_message_id = $1 FOR UPDATE;`, ev.MessageID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.WithField("message_id", ev.MessageID).Warn("Delivery event does not match any email log")
+		return tx.Commit()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query email log status: %w", err)
+	}
+
+	if current.CanTransitionTo(ev.Status) {
+		const updateQuery = `
+            UPDATE email_logs
+            SET status = $2, error_message = COALESCE($3, error_message)
+            WHERE message_id = $1 OR provider_message_id = $1;
+        `
+		if _, err := tx.ExecContext(ctx, updateQuery, ev.MessageID, string(ev.Status), emptyToNil(ev.Reason)); err != nil {
+			return fmt.Errorf("failed to update email log status: %w", err)
+		}
+	} else {
+		log.WithFields(log.Fields{
+			"message_id": ev.MessageID,
+			"current":    current,
+			"event":      ev.Status,
+		}).Debug("Delivery event does not advance email status")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delivery event: %w", err)
+	}
+	return nil
+}
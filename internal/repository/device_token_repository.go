@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+)
+
+type postgresDeviceTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresDeviceTokenRepository(db *sql.DB) *postgresDeviceTokenRepository {
+	return &postgresDeviceTokenRepository{db: db}
+}
+
+// Register stores a device token for a user or refreshes its last seen time
+func (r *postgresDeviceTokenRepository) Register(ctx context.Context, t domain.DeviceToken) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO device_tokens (token, user_id, platform, last_seen_at)
+        VALUES ($1, $2, $3, NOW())
+        ON CONFLICT (token) DO UPDATE
+        SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, last_seen_at = NOW();
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, t.Token, t.UserID, t.Platform); err != nil {
+		return fmt.Errorf("failed to register device token: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresDeviceTokenRepository) ListByUser(ctx context.Context, userID string) ([]domain.DeviceToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT token, user_id, platform, last_seen_at
+        FROM device_tokens
+        WHERE user_id = $1
+        ORDER BY last_seen_at DESC;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []domain.DeviceToken
+	for rows.Next() {
+		var t domain.DeviceToken
+		if err := rows.Scan(&t.Token, &t.UserID, &t.Platform, &t.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read device tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *postgresDeviceTokenRepository) Delete(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM device_tokens WHERE token = $1;`, token); err != nil {
+		return fmt.Errorf("failed to delete device token: %w", err)
+	}
+	return nil
+}
+
+// Unregister removes a device token of a user and reports whether it existed
+func (r *postgresDeviceTokenRepository) Unregister(ctx context.Context, userID, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM device_tokens WHERE user_id = $1 AND token = $2;`, userID, token)
+	if err != nil {
+		return false, fmt.Errorf("failed to unregister device token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+)
+
+// FieldCipher encrypts PII columns. Repositories given a nil FieldCipher
+// keep storing those columns in plaintext.
+type FieldCipher interface {
+	Encrypt(plaintext, aad string) ([]byte, error)
+	Decrypt(ciphertext []byte, aad string) (string, error)
+	BlindIndex(value string) string
+	ActiveKeyID() []byte
+}
+
+// piiColumn is a plaintext column with its ciphertext and blind index counterparts
+type piiColumn struct {
+	table     string
+	plaintext string
+	encrypted string
+	index     string
+}
+
+// aad binds a ciphertext to its column
+func (c piiColumn) aad() string {
+	return c.table + "." + c.plaintext
+}
+
+var (
+	emailRecipientColumn = piiColumn{
+		table:     "email_logs",
+		plaintext: "recipient_email",
+		encrypted: "recipient_email_enc",
+		index:     "recipient_email_bidx",
+	}
+	notificationRecipientColumn = piiColumn{
+		table:     "notification_logs",
+		plaintext: "recipient",
+		encrypted: "recipient_enc",
+		index:     "recipient_bidx",
+	}
+
+	// piiColumns lists every encrypted column for re-encryption
+	piiColumns = []piiColumn{emailRecipientColumn, notificationRecipientColumn}
+)
+
+// encryptPII returns the plaintext, ciphertext and blind index arguments
+// for storing value in column
+func encryptPII(cipher FieldCipher, column piiColumn, value string) (interface{}, interface{}, interface{}, error) {
+	if cipher == nil {
+		return value, nil, nil, nil
+	}
+	encrypted, err := cipher.Encrypt(value, column.aad())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encrypt %s: %w", column.plaintext, err)
+	}
+	return nil, encrypted, cipher.BlindIndex(value), nil
+}
+
+type postgresEncryptionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresEncryptionRepository(db *sql.DB) *postgresEncryptionRepository {
+	return &postgresEncryptionRepository{db: db}
+}
+
+// ListKeys returns every stored key, oldest first
+func (r *postgresEncryptionRepository) ListKeys(ctx context.Context) ([]domain.EncryptionKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, purpose, master_key_id, wrapped_key, created_at, retired_at
+        FROM encryption_keys
+        ORDER BY created_at, id;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query encryption keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []domain.EncryptionKey
+	for rows.Next() {
+		var k domain.EncryptionKey
+		if err := rows.Scan(&k.ID, &k.Purpose, &k.MasterKeyID, &k.Wrapped, &k.CreatedAt, &k.RetiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan encryption key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read encryption keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateKey stores a wrapped key. Only one blind index key may exist, so
+// creating a second one returns "" and leaves the first in place.
+func (r *postgresEncryptionRepository) CreateKey(ctx context.Context, purpose domain.KeyPurpose, masterKeyID string, wrapped []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO encryption_keys (purpose, master_key_id, wrapped_key)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING
+        RETURNING id;
+    `
+
+	var id string
+	err := r.db.QueryRowContext(ctx, query, string(purpose), masterKeyID, wrapped).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to insert encryption key: %w", err)
+	}
+	return id, nil
+}
+
+func (r *postgresEncryptionRepository) RewrapKey(ctx context.Context, id, masterKeyID string, wrapped []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `UPDATE encryption_keys SET master_key_id = $2, wrapped_key = $3 WHERE id = $1;`
+	if _, err := r.db.ExecContext(ctx, query, id, masterKeyID, wrapped); err != nil {
+		return fmt.Errorf("failed to rewrap encryption key: %w", err)
+	}
+	return nil
+}
+
+// RetireDataKeys retires every data key except exceptID
+func (r *postgresEncryptionRepository) RetireDataKeys(ctx context.Context, exceptID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        UPDATE encryption_keys SET retired_at = NOW()
+        WHERE purpose = $1 AND id <> $2 AND retired_at IS NULL;
+    `
+	if _, err := r.db.ExecContext(ctx, query, string(domain.KeyPurposeData), exceptID); err != nil {
+		return fmt.Errorf("failed to retire data keys: %w", err)
+	}
+	return nil
+}
+
+// Reencrypt moves up to limit values of every PII column that are in
+// plaintext or under another data key to the active key, and returns how
+// many rows it changed. Erased recipients are left alone.
+func (r *postgresEncryptionRepository) Reencrypt(ctx context.Context, cipher FieldCipher, limit int) (int64, error) {
+	var total int64
+	for _, column := range piiColumns {
+		n, err := r.reencryptColumn(ctx, cipher, column, limit)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (r *postgresEncryptionRepository) reencryptColumn(ctx context.Context, cipher FieldCipher, column piiColumn, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// ctid identifies the row within its partition without knowing the key
+	query := fmt.Sprintf(`
+        SELECT tableoid, ctid, %[2]s, %[3]s
+        FROM %[1]s
+        WHERE (%[3]s IS NOT NULL AND substring(%[3]s FROM 2 FOR 16) <> $1)
+           OR (%[3]s IS NULL AND %[2]s IS NOT NULL AND %[2]s NOT LIKE $2)
+        LIMIT $3
+        FOR UPDATE SKIP LOCKED;
+    `, column.table, column.plaintext, column.encrypted)
+
+	rows, err := tx.QueryContext(ctx, query, cipher.ActiveKeyID(), domain.PseudonymPrefix+"%", limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query %s for re-encryption: %w", column.table, err)
+	}
+
+	type pending struct {
+		tableOID  int64
+		ctid      string
+		encrypted []byte
+		index     string
+	}
+	var updates []pending
+	for rows.Next() {
+		var (
+			p         pending
+			plaintext sql.NullString
+			encrypted []byte
+		)
+		if err := rows.Scan(&p.tableOID, &p.ctid, &plaintext, &encrypted); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan %s: %w", column.table, err)
+		}
+
+		value := plaintext.String
+		if encrypted != nil {
+			if value, err = cipher.Decrypt(encrypted, column.aad()); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to decrypt %s: %w", column.aad(), err)
+			}
+		}
+		if p.encrypted, err = cipher.Encrypt(value, column.aad()); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to encrypt %s: %w", column.aad(), err)
+		}
+		p.index = cipher.BlindIndex(value)
+		updates = append(updates, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("failed to read %s: %w", column.table, err)
+	}
+	rows.Close()
+
+	update := fmt.Sprintf(`
+        UPDATE %[1]s SET %[2]s = NULL, %[3]s = $3, %[4]s = $4
+        WHERE tableoid = $1 AND ctid = $2::tid;
+    `, column.table, column.plaintext, column.encrypted, column.index)
+	for _, p := range updates {
+		if _, err := tx.ExecContext(ctx, update, p.tableOID, p.ctid, p.encrypted, p.index); err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt %s: %w", column.table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit re-encryption: %w", err)
+	}
+	return int64(len(updates)), nil
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type erasureArgs int
+
+const (
+	// argsUser passes $1 = user ID
+	argsUser erasureArgs = iota
+	// argsIndexedUser passes $1 = user ID and $2 = its blind index
+	argsIndexedUser
+	// argsAddresses passes $1 = normalized addresses
+	argsAddresses
+	// argsIndexedAddresses passes $1 = normalized addresses and $2 = their blind indexes
+	argsIndexedAddresses
+	// argsPseudonyms passes $1 = normalized addresses and $2 = their pseudonyms
+	argsPseudonyms
+	// argsIndexedPseudonyms passes $1 = normalized addresses, $2 = their pseudonyms and $3 = their blind indexes
+	argsIndexedPseudonyms
+)
+
+// scrubAddress replaces every spelling of m.address in a text column with
+// m.pseudonym; providers echo addresses in any case. Every non-alphanumeric
+// character of the address is escaped so it matches literally.
+func scrubAddress(column string) string {
+	return `regexp_replace(` + column + `, regexp_replace(m.address, '([^[:alnum:]])', '\\\1', 'g'), m.pseudonym, 'gi')`
+}
+
+// erasureSteps remove or pseudonymize everything we keep about a user, in
+// order. Every new table holding a user ID or a recipient needs a step here.
+// Encrypted recipients are found through their blind index.
+var erasureSteps = []struct {
+	table string
+	query string
+	args  erasureArgs
+}{
+	// Bodies first, while the logs still point at them by address
+	{"email_bodies", `
+        DELETE FROM email_bodies
+        WHERE id IN (
+            SELECT body_id FROM email_logs
+            WHERE body_id IS NOT NULL AND (lower(recipient_email) = ANY($1) OR recipient_email_bidx = ANY($2))
+            UNION
+            SELECT body_id FROM notification_logs
+            WHERE body_id IS NOT NULL AND (lower(recipient) = ANY($1) OR recipient_bidx = ANY($2))
+        );
+    `, argsIndexedAddresses},
+	{"email_logs", `
+        UPDATE email_logs l
+        SET recipient_email = m.pseudonym,
+            recipient_email_enc = NULL,
+            recipient_email_bidx = NULL,
+            error_message = ` + scrubAddress("l.error_message") + `
+        FROM unnest($1::text[], $2::text[], $3::text[]) AS m(address, pseudonym, bidx)
+        WHERE lower(l.recipient_email) = m.address OR l.recipient_email_bidx = m.bidx;
+    `, argsIndexedPseudonyms},
+	{"notification_logs", `
+        UPDATE notification_logs l
+        SET recipient = m.pseudonym,
+            recipient_enc = NULL,
+            recipient_bidx = NULL,
+            error_message = ` + scrubAddress("l.error_message") + `
+        FROM unnest($1::text[], $2::text[], $3::text[]) AS m(address, pseudonym, bidx)
+        WHERE lower(l.recipient) = m.address OR l.recipient_bidx = m.bidx;
+    `, argsIndexedPseudonyms},
+	{"delivery_events", `
+        UPDATE delivery_events d
+        SET recipient = m.pseudonym, payload = NULL, reason = ` + scrubAddress("d.reason") + `
+        FROM unnest($1::text[], $2::text[]) AS m(address, pseudonym)
+        WHERE lower(d.recipient) = m.address;
+    `, argsPseudonyms},
+	{"suppressed_recipients", `DELETE FROM suppressed_recipients WHERE address = ANY($1);`, argsAddresses},
+	{"device_tokens", `DELETE FROM device_tokens WHERE user_id = $1;`, argsUser},
+	{"notification_preferences", `DELETE FROM notification_preferences WHERE user_id = $1;`, argsUser},
+	{"preference_overrides", `DELETE FROM preference_overrides WHERE user_id = $1;`, argsUser},
+	// Letters saved before their user was recorded are only found in plaintext payloads
+	{"dead_letters", `
+        DELETE FROM dead_letters
+        WHERE user_id = $1 OR user_id_bidx = $2
+           OR (payload IS NOT NULL AND position(convert_to('"' || $1 || '"', 'UTF8') IN payload) > 0);
+    `, argsIndexedUser},
+}
+
+type postgresErasureRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+// NewPostgresErasureRepository also erases recipients encrypted with cipher,
+// which may be nil when encryption is disabled
+func NewPostgresErasureRepository(db *sql.DB, cipher FieldCipher) *postgresErasureRepository {
+	return &postgresErasureRepository{db: db, cipher: cipher}
+}
+
+// ListAddresses returns every recipient we sent to on behalf of userID that
+// has not been pseudonymized yet: email addresses and push device tokens
+func (r *postgresErasureRepository) ListAddresses(ctx context.Context, userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT DISTINCT ON (COALESCE(recipient_email_bidx, lower(recipient_email)))
+               lower(recipient_email), recipient_email_enc
+        FROM email_logs
+        WHERE user_id = $1 AND (recipient_email_enc IS NOT NULL OR recipient_email NOT LIKE $2);
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, domain.PseudonymPrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user addresses: %w", err)
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address sql.NullString
+		var encrypted []byte
+		if err := rows.Scan(&address, &encrypted); err != nil {
+			return nil, fmt.Errorf("failed to scan user address: %w", err)
+		}
+		if encrypted != nil {
+			if r.cipher == nil {
+				return nil, fmt.Errorf("failed to read user address: encrypted recipients need the encryption keys")
+			}
+			if address.String, err = r.cipher.Decrypt(encrypted, emailRecipientColumn.aad()); err != nil {
+				return nil, fmt.Errorf("failed to decrypt user address: %w", err)
+			}
+		}
+		addresses = append(addresses, address.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user addresses: %w", err)
+	}
+
+	tokens, err := r.db.QueryContext(ctx, `SELECT lower(token) FROM device_tokens WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user device tokens: %w", err)
+	}
+	defer tokens.Close()
+
+	for tokens.Next() {
+		var token string
+		if err := tokens.Scan(&token); err != nil {
+			return nil, fmt.Errorf("failed to scan user device token: %w", err)
+		}
+		addresses = append(addresses, token)
+	}
+	if err := tokens.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user device tokens: %w", err)
+	}
+	return addresses, nil
+}
+
+// Erase runs every erasure step and writes the audit record in one
+// transaction. addresses must be normalized and pseudonyms[i] belongs to addresses[i].
+func (r *postgresErasureRepository) Erase(ctx context.Context, userID string, source domain.ErasureSource, addresses, pseudonyms []string) (domain.ErasureRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	record := domain.ErasureRecord{UserID: userID, Source: source, AffectedRows: make(map[string]int64, len(erasureSteps))}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return record, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Without encryption no row has a blind index and "" matches nothing
+	var userIndex string
+	indexes := make([]string, len(addresses))
+	if r.cipher != nil {
+		userIndex = r.cipher.BlindIndex(userID)
+		for i, address := range addresses {
+			indexes[i] = r.cipher.BlindIndex(address)
+		}
+	}
+
+	for _, step := range erasureSteps {
+		var args []interface{}
+		switch step.args {
+		case argsUser:
+			args = []interface{}{userID}
+		case argsIndexedUser:
+			args = []interface{}{userID, userIndex}
+		case argsAddresses:
+			args = []interface{}{pq.Array(addresses)}
+		case argsIndexedAddresses:
+			args = []interface{}{pq.Array(addresses), pq.Array(indexes)}
+		case argsPseudonyms:
+			args = []interface{}{pq.Array(addresses), pq.Array(pseudonyms)}
+		case argsIndexedPseudonyms:
+			args = []interface{}{pq.Array(addresses), pq.Array(pseudonyms), pq.Array(indexes)}
+		}
+
+		res, err := tx.ExecContext(ctx, step.query, args...)
+		if err != nil {
+			return record, fmt.Errorf("failed to erase user data from %s: %w", step.table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return record, fmt.Errorf("failed to erase user data from %s: %w", step.table, err)
+		}
+		record.AffectedRows[step.table] = n
+	}
+
+	affected, err := json.Marshal(record.AffectedRows)
+	if err != nil {
+		return record, fmt.Errorf("failed to encode erasure audit: %w", err)
+	}
+
+	const query = `
+        INSERT INTO erasure_audit (user_id, source, affected_rows)
+        VALUES ($1, $2, $3)
+        RETURNING id, erased_at;
+    `
+	if err := tx.QueryRowContext(ctx, query, userID, string(source), affected).Scan(&record.ID, &record.ErasedAt); err != nil {
+		return record, fmt.Errorf("failed to insert erasure audit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return record, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+	return record, nil
+}
+
+// ListErasures returns the audit records of a user, newest first
+func (r *postgresErasureRepository) ListErasures(ctx context.Context, userID string) ([]domain.ErasureRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, user_id, source, affected_rows, erased_at
+        FROM erasure_audit
+        WHERE user_id = $1
+        ORDER BY erased_at DESC;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query erasure audit: %w", err)
+	}
+	defer rows.Close()
+
+	records := []domain.ErasureRecord{}
+	for rows.Next() {
+		var rec domain.ErasureRecord
+		var affected []byte
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Source, &affected, &rec.ErasedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan erasure audit: %w", err)
+		}
+		if err := json.Unmarshal(affected, &rec.AffectedRows); err != nil {
+			return nil, fmt.Errorf("failed to decode erasure audit: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read erasure audit: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type postgresNotificationLogRepository struct {
+	db     *sql.DB
+	cipher FieldCipher
+}
+
+// NewPostgresNotificationLogRepository stores recipients encrypted when
+// cipher is not nil
+func NewPostgresNotificationLogRepository(db *sql.DB, cipher FieldCipher) *postgresNotificationLogRepository {
+	return &postgresNotificationLogRepository{db: db, cipher: cipher}
+}
+
+func (r *postgresNotificationLogRepository) SaveLog(ctx context.Context, l domain.NotificationLog) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	log.WithFields(log.Fields{
+		"transaction_id": l.TransactionID,
+		"event_type":     l.EventType,
+		"channel":        l.Channel,
+		"status":         l.Status,
+	}).Debug("Saving notification log to database")
+
+	const query = `
+        INSERT INTO notification_logs (transaction_id, event_type, refund_id, channel, recipient, recipient_enc, recipient_bidx, status, error_message, body_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+    `
+
+	recipient, recipientEnc, recipientIndex, err := encryptPII(r.cipher, notificationRecipientColumn, l.Recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, query, l.TransactionID, string(l.EventType), emptyToNil(l.RefundID), string(l.Channel), recipient, recipientEnc, recipientIndex, string(l.Status), nullStringOrNil(l.ErrorMessage), emptyToNil(l.BodyID)); err != nil {
+		return fmt.Errorf("failed to insert notification log: %w", err)
+	}
+	return nil
+}
+
+// NotifiedChannels returns the channels the event of a transaction was
+// already notified on; refundID tells refunds apart and is empty for other
+// events. Failed sends do not count.
+func (r *postgresNotificationLogRepository) NotifiedChannels(ctx context.Context, transactionID string, eventType domain.EventType, refundID string) ([]domain.Channel, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT DISTINCT channel
+        FROM notification_logs
+        WHERE transaction_id = $1 AND event_type = $2 AND COALESCE(refund_id, '') = $3 AND status <> $4;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, transactionID, string(eventType), refundID, string(domain.StatusFailed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notified channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []domain.Channel
+	for rows.Next() {
+		var channel string
+		if err := rows.Scan(&channel); err != nil {
+			return nil, fmt.Errorf("failed to scan notified channel: %w", err)
+		}
+		channels = append(channels, domain.Channel(channel))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read notified channels: %w", err)
+	}
+	return channels, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// partitionPrefix is the naming scheme of monthly email_logs partitions: email_logs_YYYY_MM
+const partitionPrefix = "email_logs_"
+
+type postgresPartitionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPartitionRepository(db *sql.DB) *postgresPartitionRepository {
+	return &postgresPartitionRepository{db: db}
+}
+
+// ListMonths returns the start of every monthly email_logs partition
+func (r *postgresPartitionRepository) ListMonths(ctx context.Context) ([]time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'email_logs';
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email_logs partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var months []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan partition name: %w", err)
+		}
+		// Skips email_logs_default and anything not created by us
+		month, err := time.Parse("2006_01", strings.TrimPrefix(name, partitionPrefix))
+		if err != nil {
+			continue
+		}
+		months = append(months, month)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read partitions: %w", err)
+	}
+	return months, nil
+}
+
+// CreateMonth creates the partition holding rows sent in the given UTC
+// month. Rows of the month that landed in email_logs_default before the
+// partition existed are moved into it, as Postgres refuses to attach a
+// partition whose rows the default partition holds.
+func (r *postgresPartitionRepository) CreateMonth(ctx context.Context, month time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	name := pq.QuoteIdentifier(partitionName(from))
+	fromLiteral := pq.QuoteLiteral(from.Format(time.RFC3339))
+	toLiteral := pq.QuoteLiteral(to.Format(time.RFC3339))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Attaching locks the default partition anyway; taking the lock first
+	// keeps rows of the month from landing there meanwhile
+	statements := []string{
+		`LOCK TABLE email_logs_default IN ACCESS EXCLUSIVE MODE;`,
+		fmt.Sprintf(`CREATE TABLE %s (LIKE email_logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS);`, name),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM email_logs_default WHERE sent_at >= %s AND sent_at < %s RETURNING *) INSERT INTO %s SELECT * FROM moved;`,
+			fromLiteral, toLiteral, name),
+		fmt.Sprintf(`ALTER TABLE email_logs ATTACH PARTITION %s FOR VALUES FROM (%s) TO (%s);`, name, fromLiteral, toLiteral),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", partitionName(from), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit partition creation: %w", err)
+	}
+	return nil
+}
+
+// DropMonth detaches and drops a monthly partition together with the
+// email bodies referenced from it
+func (r *postgresPartitionRepository) DropMonth(ctx context.Context, month time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	name := pq.QuoteIdentifier(partitionName(month))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		fmt.Sprintf(`ALTER TABLE email_logs DETACH PARTITION %s;`, name),
+		fmt.Sprintf(`DELETE FROM email_bodies WHERE id IN (SELECT body_id FROM %s WHERE body_id IS NOT NULL);`, name),
+		fmt.Sprintf(`DROP TABLE %s;`, name),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to drop partition %s: %w", partitionName(month), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit partition drop: %w", err)
+	}
+	return nil
+}
+
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.Format("2006_01")
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+)
+
+type postgresPreferenceRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPreferenceRepository(db *sql.DB) *postgresPreferenceRepository {
+	return &postgresPreferenceRepository{db: db}
+}
+
+// Get returns the user's preferences, or the defaults when none were saved
+func (r *postgresPreferenceRepository) Get(ctx context.Context, userID string) (domain.NotificationPreferences, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT user_id, transactional_opt_in, marketing_opt_in, alerts_opt_in,
+               COALESCE(preferred_channel, ''), COALESCE(preferred_language, ''),
+               COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''),
+               COALESCE(timezone, ''), updated_at
+        FROM notification_preferences
+        WHERE user_id = $1;
+    `
+
+	var p domain.NotificationPreferences
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&p.UserID, &p.TransactionalOptIn, &p.MarketingOptIn, &p.AlertsOptIn,
+		&p.PreferredChannel, &p.PreferredLanguage,
+		&p.QuietHoursStart, &p.QuietHoursEnd,
+		&p.Timezone, &p.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DefaultPreferences(userID), nil
+	}
+	if err != nil {
+		return domain.NotificationPreferences{}, fmt.Errorf("failed to query notification preferences: %w", err)
+	}
+	return p, nil
+}
+
+func (r *postgresPreferenceRepository) Save(ctx context.Context, p domain.NotificationPreferences) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO notification_preferences (user_id, transactional_opt_in, marketing_opt_in, alerts_opt_in,
+                                              preferred_channel, preferred_language, quiet_hours_start, quiet_hours_end, timezone, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+        ON CONFLICT (user_id) DO UPDATE
+        SET transactional_opt_in = EXCLUDED.transactional_opt_in,
+            marketing_opt_in = EXCLUDED.marketing_opt_in,
+            alerts_opt_in = EXCLUDED.alerts_opt_in,
+            preferred_channel = EXCLUDED.preferred_channel,
+            preferred_language = EXCLUDED.preferred_language,
+            quiet_hours_start = EXCLUDED.quiet_hours_start,
+            quiet_hours_end = EXCLUDED.quiet_hours_end,
+            timezone = EXCLUDED.timezone,
+            updated_at = NOW();
+    `
+
+	if _, err := r.db.ExecContext(ctx, query,
+		p.UserID, p.TransactionalOptIn, p.MarketingOptIn, p.AlertsOptIn,
+		emptyToNil(string(p.PreferredChannel)), emptyToNil(p.PreferredLanguage),
+		emptyToNil(p.QuietHoursStart), emptyToNil(p.QuietHoursEnd), emptyToNil(p.Timezone),
+	); err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresPreferenceRepository) RecordOverride(ctx context.Context, o domain.PreferenceOverride) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO preference_overrides (user_id, event_type, transaction_id, category, reason)
+        VALUES ($1, $2, $3, $4, $5);
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, o.UserID, string(o.EventType), emptyToNil(o.TransactionID), string(o.Category), o.Reason); err != nil {
+		return fmt.Errorf("failed to insert preference override: %w", err)
+	}
+	return nil
+}
+
+func emptyToNil(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -5,17 +5,22 @@ import (
 	"database/sql"
 	"fmt"
 	"notification-service/internal/domain"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 )
 
 type postgresEmailRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher FieldCipher
 }
 
-func NewPostgresEmailRepository(db *sql.DB) *postgresEmailRepository {
-	return &postgresEmailRepository{db: db}
+// NewPostgresEmailRepository stores recipient addresses encrypted when
+// cipher is not nil
+func NewPostgresEmailRepository(db *sql.DB, cipher FieldCipher) *postgresEmailRepository {
+	return &postgresEmailRepository{db: db, cipher: cipher}
 }
 
 func (r *postgresEmailRepository) SaveLog(ctx context.Context, l domain.EmailLog) error {
@@ -24,23 +29,129 @@ func (r *postgresEmailRepository) SaveLog(ctx context.Context, l domain.EmailLog
 
 	log.WithFields(log.Fields{
 		"transaction_id": l.TransactionID,
+		"event_type": l.EventType,
 		"recipient_email": l.RecipientEmail,
 		"subject": l.Subject,
 		"status": l.Status,
+		"attempts": l.Attempts,
 		"error_message": l.ErrorMessage,
 	}).Info("Saving email log to database")
 
-	const query = `
-        INSERT INTO email_logs (transaction_id, recipient_email, subject, status, error_message)
-        VALUES ($1, $2, $3, $4, $5);
-    `
+	const query = insertEmailLogs + `VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20);`
 
-	if _, err := r.db.ExecContext(ctx, query, l.TransactionID, l.RecipientEmail, l.Subject, string(l.Status), nullStringOrNil(l.ErrorMessage)); err != nil {
+	args, err := r.emailLogArgs(l)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
 		return fmt.Errorf("failed to insert email log: %w", err)
 	}
 	return nil
 }
 
+// SaveLogs inserts logs with multi-row INSERTs in a single transaction,
+// so either every row is stored or none is
+func (r *postgresEmailRepository) SaveLogs(ctx context.Context, logs []domain.EmailLog) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(logs); start += maxLogsPerInsert {
+		end := min(start+maxLogsPerInsert, len(logs))
+
+		var query strings.Builder
+		query.WriteString(insertEmailLogs + "VALUES ")
+		args := make([]interface{}, 0, (end-start)*emailLogColumns)
+		for i, l := range logs[start:end] {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("(")
+			for c := 0; c < emailLogColumns; c++ {
+				if c > 0 {
+					query.WriteString(", ")
+				}
+				fmt.Fprintf(&query, "$%d", len(args)+c+1)
+			}
+			query.WriteString(")")
+			logArgs, err := r.emailLogArgs(l)
+			if err != nil {
+				return err
+			}
+			args = append(args, logArgs...)
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("failed to insert email logs: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit email logs: %w", err)
+	}
+	return nil
+}
+
+const (
+	insertEmailLogs = `
+        INSERT INTO email_logs (transaction_id, event_type, refund_id, user_id, recipient_email, recipient_email_enc,
+                                recipient_email_bidx, subject, message_id, attempts, provider, provider_message_id,
+                                template_name, template_version, locale, duration_ms, body_hash, body_id, status, error_message)
+        `
+	emailLogColumns = 20
+	// maxLogsPerInsert keeps a statement below the 65535 bind parameter limit of Postgres
+	maxLogsPerInsert = 65535 / emailLogColumns
+)
+
+func (r *postgresEmailRepository) emailLogArgs(l domain.EmailLog) ([]interface{}, error) {
+	recipient, recipientEnc, recipientIndex, err := encryptPII(r.cipher, emailRecipientColumn, l.RecipientEmail)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		l.TransactionID, emptyToNil(string(l.EventType)), emptyToNil(l.RefundID), emptyToNil(l.UserID),
+		recipient, recipientEnc, recipientIndex, l.Subject, emptyToNil(l.MessageID),
+		l.Attempts, emptyToNil(l.Provider), emptyToNil(l.ProviderMessageID),
+		emptyToNil(l.TemplateName), l.TemplateVersion, emptyToNil(l.Locale),
+		l.Duration.Milliseconds(), emptyToNil(l.BodyHash), emptyToNil(l.BodyID),
+		string(l.Status), nullStringOrNil(l.ErrorMessage),
+	}, nil
+}
+
+// MarkBounced sets the status of the email sent with messageID to bounced
+// and reports whether a matching log entry was found. Like delivery events,
+// a bounce never replaces a terminal status such as complained.
+func (r *postgresEmailRepository) MarkBounced(ctx context.Context, messageID, diagnostic string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        WITH bounced AS (
+            UPDATE email_logs
+            SET status = $2, error_message = $3
+            WHERE message_id = $1 AND status <> ALL($4)
+            RETURNING 1
+        )
+        SELECT EXISTS (SELECT 1 FROM email_logs WHERE message_id = $1);
+    `
+
+	terminal := make([]string, 0, 4)
+	for _, status := range domain.TerminalStatuses() {
+		terminal = append(terminal, string(status))
+	}
+
+	var found bool
+	if err := r.db.QueryRowContext(ctx, query, messageID, string(domain.StatusBounced), diagnostic, pq.Array(terminal)).Scan(&found); err != nil {
+		return false, fmt.Errorf("failed to mark email as bounced: %w", err)
+	}
+	return found, nil
+}
+
 func nullStringOrNil(ns sql.NullString) interface{} {
 	if ns.Valid {
 		return ns.String
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type postgresRetentionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRetentionRepository(db *sql.DB) *postgresRetentionRepository {
+	return &postgresRetentionRepository{db: db}
+}
+
+// CountExpired returns how many email logs with status were sent before the cutoff
+func (r *postgresRetentionRepository) CountExpired(ctx context.Context, status domain.EmailStatus, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var n int64
+	const query = `SELECT COUNT(*) FROM email_logs WHERE status = $1 AND sent_at < $2;`
+	if err := r.db.QueryRowContext(ctx, query, string(status), before).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count expired email logs: %w", err)
+	}
+	return n, nil
+}
+
+// PurgeBatch deletes up to limit expired email logs together with their
+// stored bodies. When archive is not nil it receives the deleted rows as
+// JSON before the transaction commits, so rows are only removed once the
+// archive has accepted them.
+func (r *postgresRetentionRepository) PurgeBatch(ctx context.Context, status domain.EmailStatus, before time.Time, limit int, archive func(rows [][]byte) error) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+        DELETE FROM email_logs
+        WHERE id IN (
+            SELECT id FROM email_logs
+            WHERE status = $1 AND sent_at < $2
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING body_id, to_jsonb(email_logs.*)::text;
+    `
+
+	rows, err := tx.QueryContext(ctx, query, string(status), before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired email logs: %w", err)
+	}
+
+	var (
+		records [][]byte
+		bodyIDs []string
+	)
+	for rows.Next() {
+		var bodyID sql.NullString
+		var record []byte
+		if err := rows.Scan(&bodyID, &record); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan deleted email log: %w", err)
+		}
+		records = append(records, record)
+		if bodyID.Valid {
+			bodyIDs = append(bodyIDs, bodyID.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("failed to read deleted email logs: %w", err)
+	}
+	rows.Close()
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	if len(bodyIDs) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_bodies WHERE id = ANY($1);`, pq.Array(bodyIDs)); err != nil {
+			return 0, fmt.Errorf("failed to delete email bodies: %w", err)
+		}
+	}
+
+	if archive != nil {
+		if err := archive(records); err != nil {
+			return 0, fmt.Errorf("failed to archive email logs: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return int64(len(records)), nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"notification-service/internal/domain"
+	"strings"
+	"time"
+)
+
+type postgresSuppressionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSuppressionRepository(db *sql.DB) *postgresSuppressionRepository {
+	return &postgresSuppressionRepository{db: db}
+}
+
+// normalizeAddress makes lookups case-insensitive for email addresses
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// IsSuppressed reports whether address has an unexpired suppression entry
+func (r *postgresSuppressionRepository) IsSuppressed(ctx context.Context, address string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT EXISTS (
+            SELECT 1 FROM suppressed_recipients
+            WHERE address = $1 AND (expires_at IS NULL OR expires_at > NOW())
+        );
+    `
+
+	var suppressed bool
+	if err := r.db.QueryRowContext(ctx, query, normalizeAddress(address)).Scan(&suppressed); err != nil {
+		return false, fmt.Errorf("failed to query suppression list: %w", err)
+	}
+	return suppressed, nil
+}
+
+// Add inserts or replaces the suppression entry for an address
+func (r *postgresSuppressionRepository) Add(ctx context.Context, s domain.SuppressedRecipient) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO suppressed_recipients (address, reason, source, expires_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (address) DO UPDATE
+        SET reason = EXCLUDED.reason, source = EXCLUDED.source, expires_at = EXCLUDED.expires_at, created_at = NOW();
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, normalizeAddress(s.Address), s.Reason, string(s.Source), s.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to add suppressed recipient: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the suppression entry and reports whether one existed
+func (r *postgresSuppressionRepository) Remove(ctx context.Context, address string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM suppressed_recipients WHERE address = $1;`, normalizeAddress(address))
+	if err != nil {
+		return false, fmt.Errorf("failed to remove suppressed recipient: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove suppressed recipient: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *postgresSuppressionRepository) List(ctx context.Context, limit, offset int) ([]domain.SuppressedRecipient, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT address, reason, source, expires_at, created_at
+        FROM suppressed_recipients
+        ORDER BY created_at DESC
+        LIMIT $1 OFFSET $2;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suppressed recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []domain.SuppressedRecipient{}
+	for rows.Next() {
+		var s domain.SuppressedRecipient
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&s.Address, &s.Reason, &s.Source, &expiresAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan suppressed recipient: %w", err)
+		}
+		if expiresAt.Valid {
+			s.ExpiresAt = &expiresAt.Time
+		}
+		recipients = append(recipients, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read suppressed recipients: %w", err)
+	}
+	return recipients, nil
+}
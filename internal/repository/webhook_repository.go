@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"notification-service/internal/domain"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type postgresWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookRepository(db *sql.DB) *postgresWebhookRepository {
+	return &postgresWebhookRepository{db: db}
+}
+
+// ListActiveEndpoints returns enabled endpoints subscribed to eventType.
+// An endpoint with no event types is subscribed to everything.
+func (r *postgresWebhookRepository) ListActiveEndpoints(ctx context.Context, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, subscriber_id, url, secret, event_types, enabled, consecutive_failures
+        FROM webhook_endpoints
+        WHERE enabled AND (cardinality(event_types) = 0 OR $1 = ANY(event_types));
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, string(eventType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []domain.WebhookEndpoint
+	for rows.Next() {
+		var e domain.WebhookEndpoint
+		if err := rows.Scan(&e.ID, &e.SubscriberID, &e.URL, &e.Secret, pq.Array(&e.EventTypes), &e.Enabled, &e.ConsecutiveFailures); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// CreateEndpoint registers an endpoint of a subscriber and returns it with its ID
+func (r *postgresWebhookRepository) CreateEndpoint(ctx context.Context, e domain.WebhookEndpoint) (domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO webhook_endpoints (subscriber_id, url, secret, event_types)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, enabled, created_at;
+    `
+
+	if e.EventTypes == nil {
+		e.EventTypes = []string{}
+	}
+	if err := r.db.QueryRowContext(ctx, query, e.SubscriberID, e.URL, e.Secret, pq.Array(e.EventTypes)).Scan(&e.ID, &e.Enabled, &e.CreatedAt); err != nil {
+		return domain.WebhookEndpoint{}, fmt.Errorf("failed to insert webhook endpoint: %w", err)
+	}
+	return e, nil
+}
+
+// ListEndpoints returns the endpoints of a subscriber without their secrets
+func (r *postgresWebhookRepository) ListEndpoints(ctx context.Context, subscriberID string) ([]domain.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, subscriber_id, url, event_types, enabled, consecutive_failures, created_at
+        FROM webhook_endpoints
+        WHERE subscriber_id = $1
+        ORDER BY created_at;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, subscriberID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []domain.WebhookEndpoint{}
+	for rows.Next() {
+		var e domain.WebhookEndpoint
+		if err := rows.Scan(&e.ID, &e.SubscriberID, &e.URL, pq.Array(&e.EventTypes), &e.Enabled, &e.ConsecutiveFailures, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// EnableEndpoint re-enables an endpoint of a subscriber and resets its failure counter
+func (r *postgresWebhookRepository) EnableEndpoint(ctx context.Context, subscriberID, endpointID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        UPDATE webhook_endpoints
+        SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL
+        WHERE subscriber_id = $1 AND id::text = $2;
+    `
+	res, err := r.db.ExecContext(ctx, query, subscriberID, endpointID)
+	if err != nil {
+		return false, fmt.Errorf("failed to enable webhook endpoint: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteEndpoint removes an endpoint of a subscriber together with its deliveries
+func (r *postgresWebhookRepository) DeleteEndpoint(ctx context.Context, subscriberID, endpointID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `DELETE FROM webhook_endpoints WHERE subscriber_id = $1 AND id::text = $2;`
+	res, err := r.db.ExecContext(ctx, query, subscriberID, endpointID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RecordSuccess resets the failure counter of an endpoint
+func (r *postgresWebhookRepository) RecordSuccess(ctx context.Context, endpointID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `UPDATE webhook_endpoints SET consecutive_failures = 0 WHERE id = $1;`
+	if _, err := r.db.ExecContext(ctx, query, endpointID); err != nil {
+		return fmt.Errorf("failed to reset webhook endpoint failures: %w", err)
+	}
+	return nil
+}
+
+// RecordFailure increments the failure counter of an endpoint and disables
+// it once disableAfter consecutive deliveries have failed. It reports
+// whether the endpoint got disabled by this call.
+func (r *postgresWebhookRepository) RecordFailure(ctx context.Context, endpointID string, disableAfter int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        UPDATE webhook_endpoints
+        SET consecutive_failures = consecutive_failures + 1,
+            enabled = CASE WHEN consecutive_failures + 1 >= $2 THEN FALSE ELSE enabled END,
+            disabled_at = CASE WHEN consecutive_failures + 1 >= $2 AND enabled THEN NOW() ELSE disabled_at END
+        WHERE id = $1
+        RETURNING enabled;
+    `
+
+	var enabled bool
+	if err := r.db.QueryRowContext(ctx, query, endpointID, disableAfter).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to record webhook endpoint failure: %w", err)
+	}
+	return !enabled, nil
+}
+
+func (r *postgresWebhookRepository) SaveDelivery(ctx context.Context, d domain.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        INSERT INTO webhook_deliveries (endpoint_id, subscriber_id, event_id, event_type, attempts, status, response_status, error_message)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+    `
+
+	var responseStatus interface{}
+	if d.ResponseStatus != 0 {
+		responseStatus = d.ResponseStatus
+	}
+	var errorMessage interface{}
+	if d.ErrorMessage != "" {
+		errorMessage = d.ErrorMessage
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, d.EndpointID, d.SubscriberID, d.EventID, string(d.EventType), d.Attempts, string(d.Status), responseStatus, errorMessage); err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries for a subscriber
+func (r *postgresWebhookRepository) ListDeliveries(ctx context.Context, subscriberID string, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	const query = `
+        SELECT id, endpoint_id, subscriber_id, event_id, event_type, attempts, status,
+               COALESCE(response_status, 0), COALESCE(error_message, ''), created_at
+        FROM webhook_deliveries
+        WHERE subscriber_id = $1
+        ORDER BY created_at DESC
+        LIMIT $2;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, subscriberID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.SubscriberID, &d.EventID, &d.EventType, &d.Attempts, &d.Status, &d.ResponseStatus, &d.ErrorMessage, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,52 @@
+package retention
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveFile appends purged rows to a gzip-compressed NDJSON file
+type archiveFile struct {
+	f  *os.File
+	zw *gzip.Writer
+}
+
+func openArchive(dir, name string, now time.Time) (*archiveFile, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("email_logs-%s-%s.ndjson.gz", name, now.UTC().Format("20060102T150405Z")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive file: %w", err)
+	}
+	return &archiveFile{f: f, zw: gzip.NewWriter(f)}, nil
+}
+
+// Write appends rows and syncs them to disk before returning
+func (a *archiveFile) Write(rows [][]byte) error {
+	for _, row := range rows {
+		if _, err := a.zw.Write(row); err != nil {
+			return err
+		}
+		if _, err := a.zw.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	if err := a.zw.Flush(); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+func (a *archiveFile) Close() error {
+	if err := a.zw.Close(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
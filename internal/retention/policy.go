@@ -0,0 +1,34 @@
+package retention
+
+import (
+	"fmt"
+	"notification-service/internal/domain"
+	"strings"
+	"time"
+)
+
+// Policy maps an email status to how long logs in that status are kept
+type Policy map[domain.EmailStatus]time.Duration
+
+// ParsePolicy parses a spec such as "sent=2160h,failed=4320h". Statuses
+// that are not listed are kept forever.
+func ParsePolicy(spec string) (Policy, error) {
+	policy := Policy{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		status, ttl, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention rule %q: expected status=duration", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid retention rule %q: duration must be positive", item)
+		}
+		policy[domain.EmailStatus(strings.TrimSpace(status))] = d
+	}
+	return policy, nil
+}
@@ -0,0 +1,176 @@
+package retention
+
+import (
+	"context"
+	"notification-service/internal/domain"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Repository defines the interface for deleting expired email logs
+type Repository interface {
+	CountExpired(ctx context.Context, status domain.EmailStatus, before time.Time) (int64, error)
+	PurgeBatch(ctx context.Context, status domain.EmailStatus, before time.Time, limit int, archive func(rows [][]byte) error) (int64, error)
+}
+
+// BodyRepository defines the interface for deleting email bodies past their own retention window
+type BodyRepository interface {
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+	// BatchPause is slept between batches to leave room for regular traffic
+	BatchPause time.Duration
+	// ArchiveDir receives purged rows as gzip NDJSON; empty disables archiving
+	ArchiveDir string
+}
+
+// Report holds the rows removed, or that would be removed in a dry run, per status
+type Report struct {
+	DryRun    bool
+	EmailLogs map[domain.EmailStatus]int64
+	Bodies    int64
+}
+
+// Worker deletes email logs older than the TTL of their status in small batches
+type Worker struct {
+	repo   Repository
+	bodies BodyRepository
+	policy Policy
+	cfg    Config
+}
+
+// NewWorker creates a retention worker; bodies may be nil when bodies are not stored
+func NewWorker(repo Repository, bodies BodyRepository, policy Policy, cfg Config) *Worker {
+	return &Worker{repo: repo, bodies: bodies, policy: policy, cfg: cfg}
+}
+
+// Run purges on every interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := w.Purge(ctx, false)
+		if err != nil {
+			log.WithError(err).Error("Retention run failed")
+		} else {
+			report.Log()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Retention worker stopping due to context cancellation")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes expired rows once. With dryRun it only counts them.
+func (w *Worker) Purge(ctx context.Context, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, EmailLogs: map[domain.EmailStatus]int64{}}
+	now := time.Now()
+
+	for _, status := range w.statuses() {
+		before := now.Add(-w.policy[status])
+
+		if dryRun {
+			n, err := w.repo.CountExpired(ctx, status, before)
+			if err != nil {
+				return report, err
+			}
+			report.EmailLogs[status] = n
+			continue
+		}
+
+		n, err := w.purgeStatus(ctx, status, before, now)
+		report.EmailLogs[status] = n
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if w.bodies != nil && !dryRun {
+		n, err := w.bodies.DeleteExpired(ctx)
+		if err != nil {
+			return report, err
+		}
+		report.Bodies = n
+	}
+	return report, nil
+}
+
+func (w *Worker) purgeStatus(ctx context.Context, status domain.EmailStatus, before, now time.Time) (int64, error) {
+	var archive func(rows [][]byte) error
+	if w.cfg.ArchiveDir != "" {
+		var file *archiveFile
+		defer func() {
+			if file != nil {
+				if err := file.Close(); err != nil {
+					log.WithError(err).Error("Failed to close retention archive")
+				}
+			}
+		}()
+		// The archive file is only created once there is something to archive
+		archive = func(rows [][]byte) error {
+			if file == nil {
+				var err error
+				if file, err = openArchive(w.cfg.ArchiveDir, string(status), now); err != nil {
+					return err
+				}
+			}
+			return file.Write(rows)
+		}
+	}
+
+	var total int64
+	for {
+		n, err := w.repo.PurgeBatch(ctx, status, before, w.cfg.BatchSize, archive)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(w.cfg.BatchSize) {
+			return total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(w.cfg.BatchPause):
+		}
+	}
+}
+
+// statuses returns the policy statuses in a stable order
+func (w *Worker) statuses() []domain.EmailStatus {
+	statuses := make([]domain.EmailStatus, 0, len(w.policy))
+	for status := range w.policy {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
+
+func (r Report) Log() {
+	fields := log.Fields{"dry_run": r.DryRun, "email_bodies": r.Bodies}
+	var total int64
+	for status, n := range r.EmailLogs {
+		fields["email_logs_"+string(status)] = n
+		total += n
+	}
+	if total == 0 && r.Bodies == 0 && !r.DryRun {
+		log.WithFields(fields).Debug("Retention run found nothing to purge")
+		return
+	}
+	if r.DryRun {
+		log.WithFields(fields).Info("Retention dry run: rows that would be purged")
+		return
+	}
+	log.WithFields(fields).Info("Retention run purged expired rows")
+}
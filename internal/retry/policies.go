@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidPolicy = errors.New("invalid retry policy")
+
+// anyValue matches every event type or channel in a selector
+const anyValue = "*"
+
+type selector struct {
+	eventType domain.EventType
+	channel   domain.Channel
+}
+
+// Policies holds the retry policies of event types and channels
+type Policies struct {
+	defaults Policy
+	policies map[selector]Policy
+}
+
+// For returns the most specific policy of an event type on a channel:
+// "event:channel", then "event:*", then "*:channel", then the defaults
+func (p *Policies) For(eventType domain.EventType, channel domain.Channel) Policy {
+	for _, s := range []selector{{eventType, channel}, {eventType, anyValue}, {anyValue, channel}} {
+		if policy, ok := p.policies[s]; ok {
+			return policy
+		}
+	}
+	return p.defaults
+}
+
+// ParsePolicies parses a spec such as
+// "*:email=attempts:5,timeout:10s;refund:sms=attempts:2,base:500ms,max:5s".
+// Each entry is event_type:channel=settings, where either side of the
+// selector may be "*" and settings are attempts, base, max and timeout.
+// Settings left out keep the value of defaults.
+func ParsePolicies(spec string, defaults Policy) (*Policies, error) {
+	policies := &Policies{defaults: defaults, policies: make(map[selector]Policy)}
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		target, settings, ok := strings.Cut(raw, "=")
+		eventType, channel, ok2 := strings.Cut(target, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%w %q: expected event_type:channel=settings", ErrInvalidPolicy, raw)
+		}
+		s := selector{
+			eventType: domain.EventType(strings.TrimSpace(eventType)),
+			channel:   domain.Channel(strings.TrimSpace(channel)),
+		}
+		if s.eventType == "" {
+			return nil, fmt.Errorf("%w %q: empty event type", ErrInvalidPolicy, raw)
+		}
+		switch s.channel {
+		case domain.ChannelEmail, domain.ChannelSMS, domain.ChannelPush, anyValue:
+		default:
+			return nil, fmt.Errorf("%w %q: unknown channel %q", ErrInvalidPolicy, raw, s.channel)
+		}
+
+		policy := defaults
+		for _, setting := range strings.Split(settings, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(setting), ":")
+			if !ok {
+				return nil, fmt.Errorf("%w %q: expected name:value, got %q", ErrInvalidPolicy, raw, setting)
+			}
+			if err := policy.set(strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("%w %q: %v", ErrInvalidPolicy, raw, err)
+			}
+		}
+		policies.policies[s] = policy
+	}
+	return policies, nil
+}
+
+func (p *Policy) set(name, value string) error {
+	if name == "attempts" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("attempts must be a positive number, got %q", value)
+		}
+		p.MaxAttempts = n
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("%s must be a duration, got %q", name, value)
+	}
+	switch name {
+	case "base":
+		p.BaseDelay = d
+	case "max":
+		p.MaxDelay = d
+	case "timeout":
+		p.AttemptTimeout = d
+	default:
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package retry
+
+import (
+	"errors"
+	"notification-service/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	defaults := Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+	policies, err := ParsePolicies(" *:email=attempts:5,timeout:10s ; refund:sms=attempts:2,base:500ms,max:5s; refund:*=attempts:4 ;", defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		eventType domain.EventType
+		channel   domain.Channel
+		want      Policy
+	}{
+		{domain.EventRefund, domain.ChannelSMS, Policy{MaxAttempts: 2, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second}},
+		{domain.EventRefund, domain.ChannelEmail, Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second}},
+		{domain.EventPurchase, domain.ChannelEmail, Policy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 30 * time.Second, AttemptTimeout: 10 * time.Second}},
+		{domain.EventPurchase, domain.ChannelPush, defaults},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.eventType)+":"+string(tt.channel), func(t *testing.T) {
+			got := policies.For(tt.eventType, tt.channel)
+			if got.MaxAttempts != tt.want.MaxAttempts || got.BaseDelay != tt.want.BaseDelay ||
+				got.MaxDelay != tt.want.MaxDelay || got.AttemptTimeout != tt.want.AttemptTimeout {
+				t.Errorf("For() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePoliciesRejectsInvalidSpecs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "no settings", spec: "refund:sms"},
+		{name: "no channel", spec: "refund=attempts:2"},
+		{name: "empty event type", spec: ":sms=attempts:2"},
+		{name: "unknown channel", spec: "refund:fax=attempts:2"},
+		{name: "setting without value", spec: "refund:sms=attempts"},
+		{name: "zero attempts", spec: "refund:sms=attempts:0"},
+		{name: "bad duration", spec: "refund:sms=base:soon"},
+		{name: "negative duration", spec: "refund:sms=max:-1s"},
+		{name: "unknown setting", spec: "refund:sms=jitter:1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePolicies(tt.spec, Policy{}); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("ParsePolicies(%q) = %v, want ErrInvalidPolicy", tt.spec, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/textproto"
+	"time"
+)
+
+// Policy decides how often and how fast a failed operation is attempted again
+type Policy struct {
+	// MaxAttempts counts the first attempt; 0 means one attempt
+	MaxAttempts int
+	// BaseDelay is the delay cap before the second attempt, doubled for every
+	// attempt after it up to MaxDelay. The actual delay is a random duration
+	// below the cap (full jitter).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout bounds every single attempt; 0 leaves only the deadline of ctx
+	AttemptTimeout time.Duration
+	// Retryable separates errors worth another attempt from permanent ones;
+	// IsRetryable when nil
+	Retryable func(error) bool
+}
+
+// Do runs op until it succeeds, fails with an error that is not retryable
+// or runs out of attempts, and returns the number of attempts with the last
+// error. Waiting between attempts ends early when ctx is done; the error
+// then wraps ctx.Err(). onRetry, if not nil, is called before every wait.
+func (p Policy) Do(ctx context.Context, op func(ctx context.Context) error, onRetry func(attempt int, err error, delay time.Duration)) (int, error) {
+	maxAttempts := max(p.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := p.attempt(ctx, op)
+		if err == nil || attempt >= maxAttempts || !p.retryable(err) {
+			return attempt, err
+		}
+		if ctx.Err() != nil {
+			return attempt, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+
+		delay := p.Backoff(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, op func(ctx context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return op(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return op(ctx)
+}
+
+func (p Policy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// Backoff returns the delay after the given failed attempt, with full jitter
+func (p Policy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if p.MaxDelay > 0 && (delay <= 0 || delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that another attempt cannot fix, such as a
+// recipient the provider rejected
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked Permanent
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// IsRetryable is the default classifier. SMTP replies are retried for 4xx
+// codes only; errors marked Permanent and cancellations never are. Network
+// errors, timeouts and anything else unknown are retried.
+func IsRetryable(err error) bool {
+	var permanent permanentError
+	var reply *textproto.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.As(err, &permanent):
+		return false
+	case errors.As(err, &reply):
+		return reply.Code >= 400 && reply.Code < 500
+	default:
+		return true
+	}
+}
@@ -0,0 +1,127 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration // the cap; the delay is at most this
+	}{
+		{name: "first retry", policy: Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, attempt: 1, want: time.Second},
+		{name: "doubled", policy: Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, attempt: 3, want: 4 * time.Second},
+		{name: "capped", policy: Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, attempt: 10, want: 30 * time.Second},
+		{name: "overflow capped", policy: Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, attempt: 80, want: 30 * time.Second},
+		{name: "no max", policy: Policy{BaseDelay: time.Millisecond}, attempt: 4, want: 8 * time.Millisecond},
+		{name: "no delay", policy: Policy{}, attempt: 2, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				if got := tt.policy.Backoff(tt.attempt); got < 0 || got > tt.want {
+					t.Fatalf("Backoff(%d) = %v, want within [0, %v]", tt.attempt, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "permanent", err: Permanent(errors.New("invalid recipient")), want: false},
+		{name: "wrapped permanent", err: fmt.Errorf("send: %w", Permanent(errors.New("invalid recipient"))), want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: true},
+		{name: "smtp 4xx", err: &textproto.Error{Code: 421, Msg: "try again later"}, want: true},
+		{name: "smtp 5xx", err: &textproto.Error{Code: 550, Msg: "mailbox unavailable"}, want: false},
+		{name: "unknown", err: errors.New("connection reset"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error // returned by the attempts in turn, nil afterwards
+		maxAttempts  int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "first attempt", maxAttempts: 3, wantAttempts: 1},
+		{name: "retried", errs: []error{errors.New("timeout")}, maxAttempts: 3, wantAttempts: 2},
+		{name: "exhausted", errs: []error{errors.New("a"), errors.New("b"), errors.New("c")}, maxAttempts: 3, wantAttempts: 3, wantErr: true},
+		{name: "permanent", errs: []error{Permanent(errors.New("rejected"))}, maxAttempts: 3, wantAttempts: 1, wantErr: true},
+		{name: "zero attempts", errs: []error{errors.New("timeout")}, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policy{MaxAttempts: tt.maxAttempts, BaseDelay: time.Millisecond}
+			calls := 0
+			attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}, nil)
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts || (err != nil) != tt.wantErr {
+				t.Errorf("Do() = %d, %v after %d calls, want %d attempts, error %v", attempts, err, calls, tt.wantAttempts, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoBoundsEveryAttempt(t *testing.T) {
+	policy := Policy{MaxAttempts: 2, AttemptTimeout: 10 * time.Millisecond}
+	var deadlines []time.Time
+	attempts, err := policy.Do(context.Background(), func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("attempt has no deadline")
+		}
+		deadlines = append(deadlines, deadline)
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+	if attempts != 2 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do() = %d, %v, want 2 attempts ending in DeadlineExceeded", attempts, err)
+	}
+	if !deadlines[1].After(deadlines[0]) {
+		t.Error("the second attempt shares the deadline of the first")
+	}
+}
+
+func TestDoStopsWaitingWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	attempts, err := policy.Do(ctx, func(ctx context.Context) error {
+		return errors.New("timeout")
+	}, func(attempt int, err error, delay time.Duration) {
+		cancel()
+	})
+	if attempts != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() = %d, %v, want 1 attempt ending in Canceled", attempts, err)
+	}
+}
@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"strings"
+)
+
+var ErrInvalidRule = errors.New("invalid routing rule")
+
+// anyCountry matches every country in a rule
+const anyCountry = "*"
+
+// Rule selects the channels used for an event type in a set of countries
+type Rule struct {
+	EventType domain.EventType
+	Countries []string
+	Channels  []domain.Channel
+}
+
+func (r Rule) matches(eventType domain.EventType, country string) bool {
+	if r.EventType != eventType {
+		return false
+	}
+	for _, c := range r.Countries {
+		if c == anyCountry || strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// Router decides which channels an event is delivered through.
+// Rules are checked in order and the first match wins; events that
+// match no rule go to email.
+type Router struct {
+	rules []Rule
+}
+
+func NewRouter(rules []Rule) *Router {
+	return &Router{rules: rules}
+}
+
+func (r *Router) Route(eventType domain.EventType, country string) []domain.Channel {
+	for _, rule := range r.rules {
+		if rule.matches(eventType, country) {
+			return rule.Channels
+		}
+	}
+	return []domain.Channel{domain.ChannelEmail}
+}
+
+// ParseRules parses a spec such as "purchase:KZ,UZ:sms+push;refund:*:email".
+// Each rule is event_type:countries:channels, where countries is a comma
+// separated list of ISO codes or "*" and channels are joined with "+".
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		parts := strings.Split(raw, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w %q: expected event_type:countries:channels", ErrInvalidRule, raw)
+		}
+
+		rule := Rule{EventType: domain.EventType(strings.TrimSpace(parts[0]))}
+		if rule.EventType == "" {
+			return nil, fmt.Errorf("%w %q: empty event type", ErrInvalidRule, raw)
+		}
+
+		for _, c := range strings.Split(parts[1], ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				rule.Countries = append(rule.Countries, strings.ToUpper(c))
+			}
+		}
+		if len(rule.Countries) == 0 {
+			return nil, fmt.Errorf("%w %q: no countries", ErrInvalidRule, raw)
+		}
+
+		for _, ch := range strings.Split(parts[2], "+") {
+			channel := domain.Channel(strings.TrimSpace(ch))
+			switch channel {
+			case domain.ChannelEmail, domain.ChannelSMS, domain.ChannelPush:
+				rule.Channels = append(rule.Channels, channel)
+			default:
+				return nil, fmt.Errorf("%w %q: unknown channel %q", ErrInvalidRule, raw, channel)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"errors"
+	"notification-service/internal/domain"
+	"slices"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rules, err := ParseRules("purchase:KZ,uz:sms+push; refund:*:email+sms; purchase:*:push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(rules)
+
+	tests := []struct {
+		name      string
+		eventType domain.EventType
+		country   string
+		want      []domain.Channel
+	}{
+		{name: "listed country", eventType: domain.EventPurchase, country: "KZ", want: []domain.Channel{domain.ChannelSMS, domain.ChannelPush}},
+		{name: "country case", eventType: domain.EventPurchase, country: "uz", want: []domain.Channel{domain.ChannelSMS, domain.ChannelPush}},
+		{name: "first match wins", eventType: domain.EventPurchase, country: "DE", want: []domain.Channel{domain.ChannelPush}},
+		{name: "any country", eventType: domain.EventRefund, country: "", want: []domain.Channel{domain.ChannelEmail, domain.ChannelSMS}},
+		{name: "no rule", eventType: domain.EventType("subscription_renewed"), country: "KZ", want: []domain.Channel{domain.ChannelEmail}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := router.Route(tt.eventType, tt.country); !slices.Equal(got, tt.want) {
+				t.Errorf("Route(%s, %q) = %v, want %v", tt.eventType, tt.country, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Rule
+		wantErr bool
+	}{
+		{name: "empty", spec: ""},
+		{name: "blank rules skipped", spec: " ; purchase : kz , : sms ;", want: []Rule{
+			{EventType: domain.EventPurchase, Countries: []string{"KZ"}, Channels: []domain.Channel{domain.ChannelSMS}},
+		}},
+		{name: "several rules", spec: "purchase:*:push+email;refund:KZ:sms", want: []Rule{
+			{EventType: domain.EventPurchase, Countries: []string{"*"}, Channels: []domain.Channel{domain.ChannelPush, domain.ChannelEmail}},
+			{EventType: domain.EventRefund, Countries: []string{"KZ"}, Channels: []domain.Channel{domain.ChannelSMS}},
+		}},
+		{name: "missing part", spec: "purchase:KZ", wantErr: true},
+		{name: "extra part", spec: "purchase:KZ:sms:push", wantErr: true},
+		{name: "empty event type", spec: " :KZ:sms", wantErr: true},
+		{name: "no countries", spec: "purchase: , :sms", wantErr: true},
+		{name: "unknown channel", spec: "purchase:KZ:fax", wantErr: true},
+		{name: "empty channel", spec: "purchase:KZ:sms+", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRules(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Errorf("ParseRules(%q) = %v, want ErrInvalidRule", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b Rule) bool {
+				return a.EventType == b.EventType && slices.Equal(a.Countries, b.Countries) && slices.Equal(a.Channels, b.Channels)
+			}) {
+				t.Errorf("ParseRules(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
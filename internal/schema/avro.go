@@ -0,0 +1,332 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// avroType is a parsed Avro schema; kind is a primitive name or one of
+// "record", "enum", "fixed", "array", "map" and "union"
+type avroType struct {
+	kind     string
+	name     string
+	fields   []avroField
+	symbols  []string
+	size     int
+	items    *avroType
+	branches []*avroType
+}
+
+type avroField struct {
+	name string
+	typ  *avroType
+}
+
+type avroParser struct {
+	names map[string]*avroType
+}
+
+func parseAvro(definition string) (*avroType, error) {
+	var raw any
+	if err := json.Unmarshal([]byte(definition), &raw); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	p := avroParser{names: make(map[string]*avroType)}
+	return p.parse(raw, "")
+}
+
+func (p *avroParser) parse(raw any, namespace string) (*avroType, error) {
+	switch v := raw.(type) {
+	case string:
+		if avroPrimitives[v] {
+			return &avroType{kind: v}, nil
+		}
+		if t, ok := p.names[v]; ok {
+			return t, nil
+		}
+		if t, ok := p.names[qualify(v, namespace)]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown Avro type %q", v)
+
+	case []any:
+		t := &avroType{kind: "union"}
+		for _, branch := range v {
+			bt, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			t.branches = append(t.branches, bt)
+		}
+		return t, nil
+
+	case map[string]any:
+		kind, ok := v["type"].(string)
+		if !ok {
+			// {"type": {...}} wraps another schema
+			return p.parse(v["type"], namespace)
+		}
+
+		switch kind {
+		case "record", "error":
+			t := &avroType{kind: "record"}
+			if err := p.define(t, v, &namespace); err != nil {
+				return nil, err
+			}
+			fields, _ := v["fields"].([]any)
+			for _, raw := range fields {
+				f, _ := raw.(map[string]any)
+				name, _ := f["name"].(string)
+				if name == "" {
+					return nil, fmt.Errorf("Avro record %s has a field without name", t.name)
+				}
+				ft, err := p.parse(f["type"], namespace)
+				if err != nil {
+					return nil, err
+				}
+				t.fields = append(t.fields, avroField{name: name, typ: ft})
+			}
+			return t, nil
+		case "enum":
+			t := &avroType{kind: "enum"}
+			if err := p.define(t, v, &namespace); err != nil {
+				return nil, err
+			}
+			symbols, _ := v["symbols"].([]any)
+			for _, s := range symbols {
+				symbol, _ := s.(string)
+				t.symbols = append(t.symbols, symbol)
+			}
+			return t, nil
+		case "fixed":
+			t := &avroType{kind: "fixed"}
+			if err := p.define(t, v, &namespace); err != nil {
+				return nil, err
+			}
+			size, _ := v["size"].(float64)
+			t.size = int(size)
+			return t, nil
+		case "array", "map":
+			key := "items"
+			if kind == "map" {
+				key = "values"
+			}
+			items, err := p.parse(v[key], namespace)
+			if err != nil {
+				return nil, err
+			}
+			return &avroType{kind: kind, items: items}, nil
+		default:
+			// A primitive, possibly with a logical type we decode as its underlying type
+			return p.parse(kind, namespace)
+		}
+	}
+	return nil, fmt.Errorf("invalid Avro schema element %v", raw)
+}
+
+// define registers a named type before its body is parsed, so it can refer
+// to itself, and moves namespace into the type's own
+func (p *avroParser) define(t *avroType, v map[string]any, namespace *string) error {
+	name, _ := v["name"].(string)
+	if name == "" {
+		return fmt.Errorf("Avro %s without name", t.kind)
+	}
+	if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+		*namespace = ns
+	}
+	t.name = qualify(name, *namespace)
+	if i := strings.LastIndex(t.name, "."); i >= 0 {
+		*namespace = t.name[:i]
+	}
+	p.names[t.name] = t
+	return nil
+}
+
+func qualify(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// fieldType reduces the type to what a Go field can hold
+func (t *avroType) fieldType() fieldType {
+	switch t.kind {
+	case "boolean":
+		return typeBool
+	case "int", "long":
+		return typeInt
+	case "float", "double":
+		return typeFloat
+	case "bytes", "fixed":
+		return typeBytes
+	case "string", "enum":
+		return typeString
+	case "record":
+		return typeRecord
+	case "array":
+		return typeArray
+	case "map":
+		return typeMap
+	case "union":
+		// ["null", T] is an optional T
+		var nonNull []*avroType
+		for _, b := range t.branches {
+			if b.kind != "null" {
+				nonNull = append(nonNull, b)
+			}
+		}
+		if len(nonNull) == 1 {
+			return nonNull[0].fieldType()
+		}
+	}
+	return typeAny
+}
+
+type avroCodec struct {
+	root *avroType
+}
+
+func (c avroCodec) fields() map[string]fieldType {
+	fields := make(map[string]fieldType)
+	if c.root.kind == "record" {
+		for _, f := range c.root.fields {
+			fields[f.name] = f.typ.fieldType()
+		}
+	}
+	return fields
+}
+
+func (c avroCodec) decode(data []byte) (any, error) {
+	r := &wireReader{buf: data}
+	v, err := c.root.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if !r.done() {
+		return nil, fmt.Errorf("%d bytes left after the Avro record", len(r.buf)-r.pos)
+	}
+	return v, nil
+}
+
+// decode reads a value in Avro binary encoding. Unions decode to the value
+// of their branch, so the result marshals to the JSON our events expect.
+func (t *avroType) decode(r *wireReader) (any, error) {
+	switch t.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		return r.zigzag()
+	case "float":
+		bits, err := r.fixed32()
+		return math.Float32frombits(bits), err
+	case "double":
+		bits, err := r.fixed64()
+		return math.Float64frombits(bits), err
+	case "bytes", "string":
+		n, err := r.zigzag()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == "string" {
+			return string(b), nil
+		}
+		return b, nil
+	case "fixed":
+		return r.next(t.size)
+	case "enum":
+		i, err := r.zigzag()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(t.symbols) {
+			return nil, fmt.Errorf("enum %s has no symbol %d", t.name, i)
+		}
+		return t.symbols[i], nil
+	case "union":
+		i, err := r.zigzag()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(t.branches) {
+			return nil, fmt.Errorf("union has no branch %d", i)
+		}
+		return t.branches[i].decode(r)
+	case "record":
+		out := make(map[string]any, len(t.fields))
+		for _, f := range t.fields {
+			v, err := f.typ.decode(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.name, f.name, err)
+			}
+			out[f.name] = v
+		}
+		return out, nil
+	case "array":
+		items := []any{}
+		err := readAvroBlocks(r, func() error {
+			v, err := t.items.decode(r)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		values := map[string]any{}
+		err := readAvroBlocks(r, func() error {
+			n, err := r.zigzag()
+			if err != nil {
+				return err
+			}
+			key, err := r.next(int(n))
+			if err != nil {
+				return err
+			}
+			values[string(key)], err = t.items.decode(r)
+			return err
+		})
+		return values, err
+	}
+	return nil, fmt.Errorf("unsupported Avro type %q", t.kind)
+}
+
+// readAvroBlocks reads the blocks arrays and maps are encoded in; a negative
+// count is followed by the block's size in bytes
+func readAvroBlocks(r *wireReader, item func() error) error {
+	for {
+		count, err := r.zigzag()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			count = -count
+			if _, err := r.zigzag(); err != nil {
+				return err
+			}
+		}
+		for ; count > 0; count-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
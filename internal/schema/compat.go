@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fieldType is a schema field's type reduced to what a Go field can hold
+type fieldType int
+
+const (
+	typeAny fieldType = iota
+	typeString
+	typeInt
+	typeFloat
+	typeBool
+	typeBytes
+	typeRecord
+	typeArray
+	typeMap
+)
+
+func (t fieldType) String() string {
+	return [...]string{"any", "string", "integer", "float", "boolean", "bytes", "record", "array", "map"}[t]
+}
+
+// codec decodes the payloads of one schema
+type codec interface {
+	// fields lists the top-level fields of the decoded record
+	fields() map[string]fieldType
+	decode(data []byte) (any, error)
+}
+
+// checkCompatible rejects a schema that lacks a field target needs, or has
+// one of a type the field cannot hold. Fields tagged omitempty are optional.
+// Renaming a field upstream thus fails loudly instead of decoding to zero.
+func checkCompatible(c codec, target reflect.Type) error {
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := c.fields()
+	for i := 0; i < target.NumField(); i++ {
+		sf := target.Field(i)
+		tag := sf.Tag.Get("json")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+
+		ft, ok := fields[name]
+		if !ok {
+			if strings.Contains(opts, "omitempty") {
+				continue
+			}
+			return fmt.Errorf("schema has no field %q", name)
+		}
+		if !holds(sf.Type, ft) {
+			return fmt.Errorf("field %q is %s in the schema but %s in %s", name, ft, sf.Type, target.Name())
+		}
+	}
+	return nil
+}
+
+// holds reports whether a Go value of type t can be decoded from JSON
+// produced for a schema field of type ft
+func holds(t reflect.Type, ft fieldType) bool {
+	if ft == typeAny {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return holds(t.Elem(), ft)
+	case reflect.Interface:
+		return true
+	case reflect.String:
+		return ft == typeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ft == typeInt
+	case reflect.Float32, reflect.Float64:
+		return ft == typeInt || ft == typeFloat
+	case reflect.Bool:
+		return ft == typeBool
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ft == typeBytes
+		}
+		return ft == typeArray
+	case reflect.Struct:
+		return ft == typeRecord
+	case reflect.Map:
+		return ft == typeMap || ft == typeRecord
+	}
+	return false
+}
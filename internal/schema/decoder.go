@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"reflect"
+	"sync"
+)
+
+// magicByte starts every Confluent wire-format payload, followed by the
+// schema ID as a big-endian uint32
+const magicByte = 0
+
+// PayloadStats counts the payloads of an event type by schema version
+type PayloadStats struct {
+	// Versions is keyed by "<subject> v<version>", or "json" for plain JSON payloads
+	Versions map[string]uint64 `json:"versions"`
+	Rejected uint64            `json:"rejected"`
+}
+
+type parsedSchema struct {
+	info  Schema
+	avro  *avroType
+	proto *protoFile
+}
+
+type compatKey struct {
+	codec     string
+	eventType domain.EventType
+}
+
+// Decoder turns Confluent wire-format Avro and Protobuf payloads into the
+// JSON the event specs decode. Plain JSON payloads pass through unchanged.
+type Decoder struct {
+	registry Registry
+
+	mu      sync.Mutex
+	schemas map[int]*parsedSchema
+	compat  map[compatKey]error
+	stats   map[domain.EventType]*PayloadStats
+}
+
+func NewDecoder(registry Registry) *Decoder {
+	return &Decoder{
+		registry: registry,
+		schemas:  make(map[int]*parsedSchema),
+		compat:   make(map[compatKey]error),
+		stats:    make(map[domain.EventType]*PayloadStats),
+	}
+}
+
+// Decode returns message as JSON. Errors wrapping ErrInvalidPayload are
+// permanent; others, like an unreachable registry, may succeed on retry.
+func (d *Decoder) Decode(ctx context.Context, eventType domain.EventType, target reflect.Type, message []byte) ([]byte, error) {
+	if len(message) == 0 || message[0] != magicByte {
+		d.count(eventType, "json")
+		return message, nil
+	}
+
+	out, version, err := d.decodeWire(ctx, eventType, target, message)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPayload) {
+			d.count(eventType, "")
+		}
+		return nil, err
+	}
+	d.count(eventType, version)
+	return out, nil
+}
+
+func (d *Decoder) decodeWire(ctx context.Context, eventType domain.EventType, target reflect.Type, message []byte) ([]byte, string, error) {
+	if len(message) < 5 {
+		return nil, "", fmt.Errorf("%w: wire-format header truncated", ErrInvalidPayload)
+	}
+	id := int(binary.BigEndian.Uint32(message[1:5]))
+	body := message[5:]
+
+	s, err := d.schema(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	version := fmt.Sprintf("%s v%d", s.info.Subject, s.info.Version)
+	if s.info.Subject == "" {
+		version = fmt.Sprintf("id %d", id)
+	}
+
+	var c codec
+	key := compatKey{codec: fmt.Sprint(id), eventType: eventType}
+	switch {
+	case s.avro != nil:
+		c = avroCodec{root: s.avro}
+	case s.proto != nil:
+		indexes, rest, err := readMessageIndexes(body)
+		if err != nil {
+			return nil, version, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		m, err := s.proto.message(indexes)
+		if err != nil {
+			return nil, version, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		c, body = protoCodec{message: m}, rest
+		key.codec = fmt.Sprint(id, indexes)
+	}
+
+	if err := d.checkCompatible(key, c, target); err != nil {
+		return nil, version, fmt.Errorf("%w: schema %s is incompatible with %s events: %v", ErrInvalidPayload, version, eventType, err)
+	}
+
+	value, err := c.decode(body)
+	if err != nil {
+		return nil, version, fmt.Errorf("%w: payload does not match schema %s: %v", ErrInvalidPayload, version, err)
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, version, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return out, version, nil
+}
+
+// schema returns the parsed schema, fetching it on first use
+func (d *Decoder) schema(ctx context.Context, id int) (*parsedSchema, error) {
+	d.mu.Lock()
+	s, ok := d.schemas[id]
+	d.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	info, err := d.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s = &parsedSchema{info: info}
+	switch info.Type {
+	case "", Avro:
+		s.avro, err = parseAvro(info.Definition)
+	case Protobuf:
+		s.proto, err = parseProto(info.Definition)
+	default:
+		err = fmt.Errorf("unsupported schema type %q", info.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema %d: %v", ErrInvalidPayload, id, err)
+	}
+
+	d.mu.Lock()
+	d.schemas[id] = s
+	d.mu.Unlock()
+	return s, nil
+}
+
+func (d *Decoder) checkCompatible(key compatKey, c codec, target reflect.Type) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err, ok := d.compat[key]
+	if !ok {
+		err = checkCompatible(c, target)
+		d.compat[key] = err
+	}
+	return err
+}
+
+// count records a decoded payload, or a rejected one when version is empty
+func (d *Decoder) count(eventType domain.EventType, version string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.stats[eventType]
+	if !ok {
+		s = &PayloadStats{Versions: make(map[string]uint64)}
+		d.stats[eventType] = s
+	}
+	if version == "" {
+		s.Rejected++
+		return
+	}
+	s.Versions[version]++
+}
+
+// Stats returns the payload counters of every event type seen so far
+func (d *Decoder) Stats() map[domain.EventType]PayloadStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make(map[domain.EventType]PayloadStats, len(d.stats))
+	for eventType, s := range d.stats {
+		versions := make(map[string]uint64, len(s.Versions))
+		for v, n := range s.Versions {
+			versions[v] = n
+		}
+		out[eventType] = PayloadStats{Versions: versions, Rejected: s.Rejected}
+	}
+	return out
+}
@@ -0,0 +1,351 @@
+package schema
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"notification-service/internal/domain"
+	"reflect"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type fakeRegistry map[int]Schema
+
+func (r fakeRegistry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	s, ok := r[id]
+	if !ok {
+		return Schema{}, errors.New("schema not found")
+	}
+	return s, nil
+}
+
+var refundType = reflect.TypeOf(domain.RefundInfo{})
+
+// wire prefixes body with the Confluent magic byte and schema ID
+func wire(id int, body []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{magicByte}, uint32(id)), body...)
+}
+
+const refundProto = `
+syntax = "proto3";
+package billing.v1;
+
+// Audit is defined first, so RefundIssued is message index 1
+message Audit {
+  string actor = 1;
+  message Change {
+    string field = 1;
+  }
+}
+
+enum Currency {
+  CURRENCY_UNSPECIFIED = 0;
+  EUR = 1;
+  USD = 2;
+}
+
+message RefundIssued {
+  string refund_id = 1;
+  string transaction_id = 2;
+  string user_id = 3;
+  string user_email = 4;
+  int64 amount = 5;
+  sint64 coins_deducted = 6;
+  string processed_at = 7;
+  Currency currency = 8;
+  repeated int32 line_items = 9;
+  map<string, int64> balances = 10;
+  Audit audit = 11;
+  optional string provider = 12;
+  bool partial = 13;
+  double rate = 14;
+  bytes signature = 15;
+  oneof origin {
+    string reason = 16;
+    fixed32 ticket = 17;
+  }
+}
+`
+
+// protoPayload encodes a message of refundProto with the Protobuf library
+func protoPayload(t *testing.T, name protoreflect.Name, fill func(m *dynamicpb.Message, fields protoreflect.FieldDescriptors)) []byte {
+	t.Helper()
+	compiler := protocompile.Compiler{
+		Resolver: &protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{"refund.proto": refundProto}),
+		},
+	}
+	files, err := compiler.Compile(context.Background(), "refund.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := files[0].Messages().ByName(name)
+	if desc == nil {
+		desc = files[0].Messages().ByName("Audit").Messages().ByName(name)
+	}
+	m := dynamicpb.NewMessage(desc)
+	fill(m, desc.Fields())
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	registry := fakeRegistry{7: {ID: 7, Subject: "refunds-value", Version: 3, Type: Protobuf, Definition: refundProto}}
+
+	tests := []struct {
+		name    string
+		indexes []byte
+		message protoreflect.Name
+		fill    func(m *dynamicpb.Message, f protoreflect.FieldDescriptors)
+		target  reflect.Type
+		want    string
+	}{
+		{
+			name:    "zero values are filled in",
+			indexes: []byte{2, 2}, // [1]
+			message: "RefundIssued",
+			fill: func(m *dynamicpb.Message, f protoreflect.FieldDescriptors) {
+				m.Set(f.ByName("refund_id"), protoreflect.ValueOfString("rf-1"))
+				m.Set(f.ByName("amount"), protoreflect.ValueOfInt64(0))
+			},
+			target: refundType,
+			want: `{"amount":0,"balances":{},"coins_deducted":0,"currency":"CURRENCY_UNSPECIFIED","line_items":[],` +
+				`"partial":false,"processed_at":"","rate":0,"refund_id":"rf-1","signature":"","transaction_id":"","user_email":"","user_id":""}`,
+		},
+		{
+			name:    "every field set",
+			indexes: []byte{2, 2},
+			message: "RefundIssued",
+			fill: func(m *dynamicpb.Message, f protoreflect.FieldDescriptors) {
+				m.Set(f.ByName("refund_id"), protoreflect.ValueOfString("rf-1"))
+				m.Set(f.ByName("transaction_id"), protoreflect.ValueOfString("tx-1"))
+				m.Set(f.ByName("user_id"), protoreflect.ValueOfString("user-1"))
+				m.Set(f.ByName("user_email"), protoreflect.ValueOfString("user@example.com"))
+				m.Set(f.ByName("amount"), protoreflect.ValueOfInt64(1999))
+				m.Set(f.ByName("coins_deducted"), protoreflect.ValueOfInt64(-150))
+				m.Set(f.ByName("processed_at"), protoreflect.ValueOfString("2024-01-02T03:04:05Z"))
+				m.Set(f.ByName("currency"), protoreflect.ValueOfEnum(2))
+				items := m.Mutable(f.ByName("line_items")).List()
+				items.Append(protoreflect.ValueOfInt32(3))
+				items.Append(protoreflect.ValueOfInt32(-1))
+				balances := m.Mutable(f.ByName("balances")).Map()
+				balances.Set(protoreflect.ValueOfString("coins").MapKey(), protoreflect.ValueOfInt64(40))
+				balances.Set(protoreflect.ValueOfString("bonus").MapKey(), protoreflect.ValueOfInt64(0))
+				m.Mutable(f.ByName("audit")).Message().Set(f.ByName("audit").Message().Fields().ByName("actor"), protoreflect.ValueOfString("support"))
+				m.Set(f.ByName("provider"), protoreflect.ValueOfString(""))
+				m.Set(f.ByName("partial"), protoreflect.ValueOfBool(true))
+				m.Set(f.ByName("rate"), protoreflect.ValueOfFloat64(0.5))
+				m.Set(f.ByName("signature"), protoreflect.ValueOfBytes([]byte{1, 2}))
+				m.Set(f.ByName("reason"), protoreflect.ValueOfString("duplicate"))
+			},
+			target: refundType,
+			want: `{"amount":1999,"audit":{"actor":"support"},"balances":{"bonus":0,"coins":40},"coins_deducted":-150,` +
+				`"currency":"USD","line_items":[3,-1],"partial":true,"processed_at":"2024-01-02T03:04:05Z","provider":"",` +
+				`"rate":0.5,"reason":"duplicate","refund_id":"rf-1","signature":"AQI=","transaction_id":"tx-1",` +
+				`"user_email":"user@example.com","user_id":"user-1"}`,
+		},
+		{
+			name:    "first message",
+			indexes: []byte{0},
+			message: "Audit",
+			fill: func(m *dynamicpb.Message, f protoreflect.FieldDescriptors) {
+				m.Set(f.ByName("actor"), protoreflect.ValueOfString("support"))
+			},
+			want: `{"actor":"support"}`,
+		},
+		{
+			name:    "nested message",
+			indexes: []byte{4, 0, 0}, // [0, 0]
+			message: "Change",
+			fill: func(m *dynamicpb.Message, f protoreflect.FieldDescriptors) {
+				m.Set(f.ByName("field"), protoreflect.ValueOfString("amount"))
+			},
+			want: `{"field":"amount"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := append(tt.indexes, protoPayload(t, tt.message, tt.fill)...)
+			target := tt.target
+			if target == nil {
+				target = reflect.TypeOf(map[string]any{})
+			}
+
+			d := NewDecoder(registry)
+			got, err := d.Decode(context.Background(), domain.EventRefund, target, wire(7, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Decode() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if n := d.Stats()[domain.EventRefund].Versions["refunds-value v3"]; n != 1 {
+				t.Errorf("counted %d payloads of refunds-value v3, want 1", n)
+			}
+		})
+	}
+}
+
+const refundAvro = `{
+  "type": "record",
+  "name": "RefundIssued",
+  "namespace": "billing.v1",
+  "fields": [
+    {"name": "refund_id", "type": "string"},
+    {"name": "transaction_id", "type": "string"},
+    {"name": "user_id", "type": "string"},
+    {"name": "user_email", "type": "string"},
+    {"name": "amount", "type": "long"},
+    {"name": "coins_deducted", "type": "int"},
+    {"name": "processed_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "reason", "type": ["null", "string"], "default": null},
+    {"name": "currency", "type": {"type": "enum", "name": "Currency", "symbols": ["EUR", "USD"]}},
+    {"name": "line_items", "type": {"type": "array", "items": "int"}},
+    {"name": "balances", "type": {"type": "map", "values": "long"}},
+    {"name": "audit", "type": ["null", {"type": "record", "name": "Audit", "fields": [{"name": "actor", "type": "string"}]}]},
+    {"name": "partial", "type": "boolean"},
+    {"name": "rate", "type": "double"},
+    {"name": "signature", "type": {"type": "fixed", "name": "Signature", "size": 2}}
+  ]
+}`
+
+func TestDecodeAvro(t *testing.T) {
+	codec, err := goavro.NewCodec(refundAvro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := fakeRegistry{
+		3: {ID: 3, Subject: "refunds-value", Version: 1, Definition: refundAvro},
+		4: {ID: 4, Subject: "refunds-value", Version: 2, Definition: `{"type": "record", "name": "RefundIssued", "fields": [{"name": "refund_id", "type": "string"}]}`},
+	}
+
+	tests := []struct {
+		name  string
+		value map[string]any
+		want  string
+	}{
+		{
+			name: "zero values",
+			value: map[string]any{
+				"refund_id": "rf-1", "transaction_id": "", "user_id": "", "user_email": "",
+				"amount": int64(0), "coins_deducted": int32(0), "processed_at": int64(0), "reason": nil,
+				"currency": "EUR", "line_items": []any{}, "balances": map[string]any{}, "audit": nil,
+				"partial": false, "rate": 0.0, "signature": []byte{0, 0},
+			},
+			want: `{"amount":0,"audit":null,"balances":{},"coins_deducted":0,"currency":"EUR","line_items":[],` +
+				`"partial":false,"processed_at":0,"rate":0,"reason":null,"refund_id":"rf-1","signature":"AAA=",` +
+				`"transaction_id":"","user_email":"","user_id":""}`,
+		},
+		{
+			name: "every field set",
+			value: map[string]any{
+				"refund_id": "rf-1", "transaction_id": "tx-1", "user_id": "user-1", "user_email": "user@example.com",
+				"amount": int64(1999), "coins_deducted": int32(-150), "processed_at": int64(1704164645000),
+				"reason":     goavro.Union("string", "duplicate"),
+				"currency":   "USD",
+				"line_items": []any{int32(3), int32(-1)},
+				"balances":   map[string]any{"coins": int64(40)},
+				"audit":      goavro.Union("billing.v1.Audit", map[string]any{"actor": "support"}),
+				"partial":    true, "rate": 0.5, "signature": []byte{1, 2},
+			},
+			want: `{"amount":1999,"audit":{"actor":"support"},"balances":{"coins":40},"coins_deducted":-150,` +
+				`"currency":"USD","line_items":[3,-1],"partial":true,"processed_at":1704164645000,"rate":0.5,` +
+				`"reason":"duplicate","refund_id":"rf-1","signature":"AQI=","transaction_id":"tx-1",` +
+				`"user_email":"user@example.com","user_id":"user-1"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := codec.BinaryFromNative(nil, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := NewDecoder(registry).Decode(context.Background(), domain.EventRefund, reflect.TypeOf(map[string]any{}), wire(3, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Decode() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsInvalidPayloads(t *testing.T) {
+	codec, err := goavro.NewCodec(refundAvro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := codec.BinaryFromNative(nil, map[string]any{
+		"refund_id": "rf-1", "transaction_id": "tx-1", "user_id": "user-1", "user_email": "user@example.com",
+		"amount": int64(1999), "coins_deducted": int32(150), "processed_at": int64(0), "reason": nil,
+		"currency": "EUR", "line_items": []any{}, "balances": map[string]any{}, "audit": nil,
+		"partial": false, "rate": 0.0, "signature": []byte{0, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := fakeRegistry{
+		3: {ID: 3, Subject: "refunds-value", Version: 1, Definition: refundAvro},
+		4: {ID: 4, Subject: "refunds-value", Version: 2, Definition: `{"type": "record", "name": "RefundIssued", "fields": [{"name": "refund_id", "type": "string"}]}`},
+		5: {ID: 5, Type: Protobuf, Definition: refundProto},
+	}
+
+	anyType := reflect.TypeOf(map[string]any{})
+	tests := []struct {
+		name    string
+		target  reflect.Type
+		message []byte
+	}{
+		{"truncated header", anyType, []byte{magicByte, 0, 0}},
+		{"truncated payload", anyType, wire(3, valid[:len(valid)-3])},
+		{"trailing bytes", anyType, wire(3, append(valid, 0))},
+		{"field type incompatible with the event", refundType, wire(3, valid)},
+		{"schema missing a required field", refundType, wire(4, []byte{8, 'r', 'f', '-', '1'})},
+		{"unknown message index", refundType, wire(5, []byte{2, 10})},
+		{"wire type mismatch", refundType, wire(5, []byte{2, 2, 0x28, 0x01, 0x2a, 0x00})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(registry)
+			_, err := d.Decode(context.Background(), domain.EventRefund, tt.target, tt.message)
+			if !errors.Is(err, ErrInvalidPayload) {
+				t.Fatalf("Decode() error = %v, want ErrInvalidPayload", err)
+			}
+			if n := d.Stats()[domain.EventRefund].Rejected; n != 1 {
+				t.Errorf("counted %d rejected payloads, want 1", n)
+			}
+		})
+	}
+
+	// An unreachable registry may recover, so the error is not permanent
+	_, err = NewDecoder(fakeRegistry{}).Decode(context.Background(), domain.EventRefund, refundType, wire(9, valid))
+	if err == nil || errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Decode() error = %v, want a retryable error", err)
+	}
+}
+
+func TestDecodePassesJSONThrough(t *testing.T) {
+	message := []byte(`{"refund_id":"rf-1"}`)
+	d := NewDecoder(fakeRegistry{})
+	got, err := d.Decode(context.Background(), domain.EventRefund, refundType, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(message) {
+		t.Errorf("Decode() = %s, want %s", got, message)
+	}
+	if n := d.Stats()[domain.EventRefund].Versions["json"]; n != 1 {
+		t.Errorf("counted %d JSON payloads, want 1", n)
+	}
+}
@@ -0,0 +1,669 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var protoScalars = map[string]fieldType{
+	"double": typeFloat, "float": typeFloat,
+	"int32": typeInt, "int64": typeInt, "uint32": typeInt, "uint64": typeInt,
+	"sint32": typeInt, "sint64": typeInt, "fixed32": typeInt, "fixed64": typeInt,
+	"sfixed32": typeInt, "sfixed64": typeInt,
+	"bool": typeBool, "string": typeString, "bytes": typeBytes,
+}
+
+// protoFile is a parsed .proto schema. Imports are not followed, so every
+// message and enum a field uses must be defined in the same file.
+type protoFile struct {
+	messages []*protoMessage
+}
+
+type protoMessage struct {
+	name   string
+	fields map[int]*protoField
+	nested []*protoMessage
+}
+
+type protoField struct {
+	name     string
+	number   int
+	typeName string
+	repeated bool
+	// presence is set for fields whose absence is distinct from their
+	// default: proto2 and proto3 optional fields and oneof members
+	presence bool
+	// isMap fields are repeated entries of message with key = 1 and value = 2
+	isMap   bool
+	message *protoMessage
+	enum    *protoEnum
+}
+
+type protoEnum struct {
+	name   string
+	values map[int64]string
+}
+
+// message returns the message a Confluent payload names by its indexes:
+// the n-th top-level message, then the n-th message nested in it, and so on
+func (f *protoFile) message(indexes []int) (*protoMessage, error) {
+	messages := f.messages
+	var m *protoMessage
+	for _, i := range indexes {
+		if i < 0 || i >= len(messages) {
+			return nil, fmt.Errorf("schema has no message at index %v", indexes)
+		}
+		m = messages[i]
+		messages = m.nested
+	}
+	if m == nil {
+		return nil, fmt.Errorf("schema defines no message")
+	}
+	return m, nil
+}
+
+type protoParser struct {
+	tokens  []string
+	pos     int
+	pkg     string
+	proto3  bool
+	types   map[string]any
+	pending []pendingField
+}
+
+type pendingField struct {
+	field *protoField
+	scope string
+}
+
+func parseProto(definition string) (*protoFile, error) {
+	tokens, err := tokenizeProto(definition)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens, types: make(map[string]any)}
+	file := &protoFile{}
+
+	for p.pos < len(p.tokens) {
+		switch tok := p.next(); tok {
+		case "syntax":
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			p.proto3 = strings.Trim(p.next(), `"'`) == "proto3"
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "import", "option", "edition":
+			p.skipStatement()
+		case "package":
+			p.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "message":
+			m, err := p.parseMessage(p.pkg)
+			if err != nil {
+				return nil, err
+			}
+			file.messages = append(file.messages, m)
+		case "enum":
+			if err := p.parseEnum(p.pkg); err != nil {
+				return nil, err
+			}
+		case "service", "extend":
+			p.skipBlock()
+		case ";":
+		default:
+			return nil, fmt.Errorf("unexpected %q in Protobuf schema", tok)
+		}
+	}
+
+	for _, pf := range p.pending {
+		if err := p.resolve(pf); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (p *protoParser) parseMessage(scope string) (*protoMessage, error) {
+	m := &protoMessage{name: qualify(p.next(), scope), fields: make(map[int]*protoField)}
+	p.types[m.name] = m
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for {
+		switch tok := p.peek(); tok {
+		case "}":
+			p.next()
+			return m, nil
+		case "":
+			return nil, fmt.Errorf("message %s is not closed", m.name)
+		case "message":
+			p.next()
+			nested, err := p.parseMessage(m.name)
+			if err != nil {
+				return nil, err
+			}
+			m.nested = append(m.nested, nested)
+		case "enum":
+			p.next()
+			if err := p.parseEnum(m.name); err != nil {
+				return nil, err
+			}
+		case "oneof":
+			p.next()
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			for p.peek() != "}" && p.peek() != "" {
+				if p.peek() == "option" {
+					p.skipStatement()
+					continue
+				}
+				if err := p.parseField(m, true); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "extend":
+			p.skipBlock()
+		case ";":
+			p.next()
+		default:
+			if err := p.parseField(m, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parseField parses [label] type name = number [options];
+func (p *protoParser) parseField(m *protoMessage, oneof bool) error {
+	f := &protoField{presence: oneof || !p.proto3}
+	switch p.peek() {
+	case "repeated":
+		f.repeated = true
+		p.next()
+	case "optional", "required":
+		f.presence = true
+		p.next()
+	}
+
+	f.typeName = p.next()
+	if f.typeName == "map" {
+		if err := p.expect("<"); err != nil {
+			return err
+		}
+		// Map entries leave out a key or value equal to its default
+		key := &protoField{name: "key", number: 1, typeName: p.next()}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		value := &protoField{name: "value", number: 2, typeName: p.next()}
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+		entry := &protoMessage{name: m.name + ".MapEntry", fields: map[int]*protoField{1: key, 2: value}}
+		f.repeated, f.isMap, f.message = true, true, entry
+		p.pending = append(p.pending, pendingField{field: value, scope: m.name})
+	} else {
+		p.pending = append(p.pending, pendingField{field: f, scope: m.name})
+	}
+
+	f.name = p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return fmt.Errorf("field %s.%s has an invalid number", m.name, f.name)
+	}
+	f.number = number
+	if p.peek() == "[" {
+		p.skipBrackets()
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	m.fields[f.number] = f
+	return nil
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	e := &protoEnum{name: qualify(p.next(), scope), values: make(map[int64]string)}
+	p.types[e.name] = e
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case "":
+			return fmt.Errorf("enum %s is not closed", e.name)
+		case "option", "reserved":
+			p.skipStatement()
+		case ";":
+		default:
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := strconv.ParseInt(p.next(), 0, 64)
+			if err != nil {
+				return fmt.Errorf("enum value %s.%s has an invalid number", e.name, tok)
+			}
+			if _, ok := e.values[number]; !ok {
+				e.values[number] = tok
+			}
+			if p.peek() == "[" {
+				p.skipBrackets()
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// resolve finds the message or enum a field refers to, searching from the
+// innermost scope outwards as protoc does
+func (p *protoParser) resolve(pf pendingField) error {
+	f := pf.field
+	if _, ok := protoScalars[f.typeName]; ok {
+		return nil
+	}
+
+	candidates := []string{strings.TrimPrefix(f.typeName, ".")}
+	if !strings.HasPrefix(f.typeName, ".") {
+		candidates = nil
+		for scope := pf.scope; ; {
+			candidates = append(candidates, qualify(f.typeName, scope))
+			i := strings.LastIndex(scope, ".")
+			if i < 0 {
+				if scope != "" {
+					candidates = append(candidates, f.typeName)
+				}
+				break
+			}
+			scope = scope[:i]
+		}
+	}
+
+	for _, name := range candidates {
+		switch t := p.types[name].(type) {
+		case *protoMessage:
+			f.message = t
+			return nil
+		case *protoEnum:
+			f.enum = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown Protobuf type %q in %s", f.typeName, pf.scope)
+}
+
+func (p *protoParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(want string) error {
+	if got := p.next(); got != want {
+		return fmt.Errorf("expected %q in Protobuf schema, got %q", want, got)
+	}
+	return nil
+}
+
+// skipStatement skips to the end of a statement whose value may contain braces
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for tok := p.next(); tok != ""; tok = p.next() {
+		switch tok {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case ";":
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *protoParser) skipBlock() {
+	depth := 0
+	for tok := p.next(); tok != ""; tok = p.next() {
+		switch tok {
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *protoParser) skipBrackets() {
+	depth := 0
+	for tok := p.next(); tok != ""; tok = p.next() {
+		switch tok {
+		case "[":
+			depth++
+		case "]":
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func tokenizeProto(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment in Protobuf schema")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string in Protobuf schema")
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		case isProtoIdent(c):
+			j := i
+			for j < len(src) && isProtoIdent(src[j]) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoIdent(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (f *protoField) fieldType() fieldType {
+	switch {
+	case f.isMap:
+		return typeMap
+	case f.repeated:
+		return typeArray
+	case f.message != nil:
+		return typeRecord
+	case f.enum != nil:
+		return typeString
+	}
+	return protoScalars[f.typeName]
+}
+
+// wireType is the wire type of a single, unpacked value of the field
+func (f *protoField) wireType() int {
+	switch f.typeName {
+	case "fixed64", "sfixed64", "double":
+		return 1
+	case "fixed32", "sfixed32", "float":
+		return 5
+	case "string", "bytes":
+		return 2
+	}
+	if f.message != nil {
+		return 2
+	}
+	return 0
+}
+
+type protoCodec struct {
+	message *protoMessage
+}
+
+func (c protoCodec) fields() map[string]fieldType {
+	fields := make(map[string]fieldType, len(c.message.fields))
+	for _, f := range c.message.fields {
+		fields[f.name] = f.fieldType()
+	}
+	return fields
+}
+
+func (c protoCodec) decode(data []byte) (any, error) {
+	return c.message.decode(data)
+}
+
+// decode reads a message in Protobuf binary encoding. Fields keep their
+// names from the schema, enums decode to their names and unknown fields are
+// skipped. Encoders leave out proto3 fields equal to their default, so those
+// are filled in, as are empty repeated fields and maps.
+func (m *protoMessage) decode(data []byte) (map[string]any, error) {
+	out := make(map[string]any)
+	r := &wireReader{buf: data}
+	for !r.done() {
+		key, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		number, wireType := int(key>>3), int(key&7)
+
+		f, ok := m.fields[number]
+		if !ok {
+			if err := r.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Repeated scalars are usually packed into one length-delimited field
+		if f.repeated && wireType == 2 && f.wireType() != 2 {
+			packed, err := r.lengthDelimited()
+			if err != nil {
+				return nil, err
+			}
+			pr := &wireReader{buf: packed}
+			list, _ := out[f.name].([]any)
+			for !pr.done() {
+				v, err := f.decodeValue(pr)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+				}
+				list = append(list, v)
+			}
+			out[f.name] = list
+			continue
+		}
+
+		if wireType != f.wireType() {
+			return nil, fmt.Errorf("%s.%s: wire type %d does not match %s", m.name, f.name, wireType, f.typeName)
+		}
+		v, err := f.decodeValue(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", m.name, f.name, err)
+		}
+
+		switch {
+		case f.isMap:
+			entries, _ := out[f.name].(map[string]any)
+			if entries == nil {
+				entries = make(map[string]any)
+			}
+			entry := v.(map[string]any)
+			entries[fmt.Sprint(entry["key"])] = entry["value"]
+			out[f.name] = entries
+		case f.repeated:
+			list, _ := out[f.name].([]any)
+			out[f.name] = append(list, v)
+		default:
+			out[f.name] = v
+		}
+	}
+
+	for _, f := range m.fields {
+		if _, ok := out[f.name]; !ok {
+			if v, ok := f.defaultValue(); ok {
+				out[f.name] = v
+			}
+		}
+	}
+	return out, nil
+}
+
+// defaultValue is the value of a field missing from the payload; ok is false
+// for fields whose absence means unset, such as messages
+func (f *protoField) defaultValue() (any, bool) {
+	switch {
+	case f.isMap:
+		return map[string]any{}, true
+	case f.repeated:
+		return []any{}, true
+	case f.presence || f.message != nil:
+		return nil, false
+	case f.enum != nil:
+		if name, ok := f.enum.values[0]; ok {
+			return name, true
+		}
+		return int32(0), true
+	}
+
+	switch f.typeName {
+	case "string":
+		return "", true
+	case "bytes":
+		return []byte{}, true
+	case "bool":
+		return false, true
+	}
+	return 0, true
+}
+
+func (f *protoField) decodeValue(r *wireReader) (any, error) {
+	switch f.typeName {
+	case "double":
+		bits, err := r.fixed64()
+		return math.Float64frombits(bits), err
+	case "float":
+		bits, err := r.fixed32()
+		return math.Float32frombits(bits), err
+	case "fixed64":
+		return r.fixed64()
+	case "sfixed64":
+		v, err := r.fixed64()
+		return int64(v), err
+	case "fixed32":
+		return r.fixed32()
+	case "sfixed32":
+		v, err := r.fixed32()
+		return int32(v), err
+	case "string":
+		b, err := r.lengthDelimited()
+		return string(b), err
+	case "bytes":
+		return r.lengthDelimited()
+	}
+
+	if f.message != nil {
+		b, err := r.lengthDelimited()
+		if err != nil {
+			return nil, err
+		}
+		return f.message.decode(b)
+	}
+
+	v, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	switch f.typeName {
+	case "int32":
+		return int32(v), nil
+	case "int64":
+		return int64(v), nil
+	case "uint32":
+		return uint32(v), nil
+	case "uint64":
+		return v, nil
+	case "sint32", "sint64":
+		return int64(v>>1) ^ -int64(v&1), nil
+	case "bool":
+		return v != 0, nil
+	}
+	if f.enum != nil {
+		if name, ok := f.enum.values[int64(int32(v))]; ok {
+			return name, nil
+		}
+		return int32(v), nil
+	}
+	return nil, fmt.Errorf("unsupported Protobuf type %q", f.typeName)
+}
+
+// readMessageIndexes reads the message indexes Confluent puts before a
+// Protobuf payload: a zigzag count followed by zigzag indexes, where a count
+// of 0 stands for the first message
+func readMessageIndexes(data []byte) ([]int, []byte, error) {
+	r := &wireReader{buf: data}
+	count, err := r.zigzag()
+	if err != nil {
+		return nil, nil, err
+	}
+	if count == 0 {
+		return []int{0}, data[r.pos:], nil
+	}
+	if count < 0 || count > 100 {
+		return nil, nil, fmt.Errorf("invalid message index count %d", count)
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		v, err := r.zigzag()
+		if err != nil {
+			return nil, nil, err
+		}
+		indexes[i] = int(v)
+	}
+	return indexes, data[r.pos:], nil
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPayload is wrapped by every error that retrying cannot fix: an
+// unknown or incompatible schema, or a payload that does not match its schema
+var ErrInvalidPayload = errors.New("invalid payload")
+
+type Type string
+
+const (
+	Avro     Type = "AVRO"
+	Protobuf Type = "PROTOBUF"
+)
+
+// Schema is a schema as stored in the registry
+type Schema struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	// Type is empty for Avro, as in the Confluent registry API
+	Type       Type   `json:"schemaType"`
+	Definition string `json:"schema"`
+}
+
+// Registry looks up schemas by the ID embedded in wire-format payloads
+type Registry interface {
+	SchemaByID(ctx context.Context, id int) (Schema, error)
+}
+
+type httpRegistry struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewHTTPRegistry talks to a Confluent compatible schema registry
+func NewHTTPRegistry(baseURL, username, password string, timeout time.Duration) *httpRegistry {
+	return &httpRegistry{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (r *httpRegistry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	s := Schema{ID: id}
+	if err := r.get(ctx, fmt.Sprintf("/schemas/ids/%d", id), &s); err != nil {
+		return s, err
+	}
+
+	// The subject and version only label metrics, so a registry without
+	// this endpoint still works
+	var versions []struct {
+		Subject string `json:"subject"`
+		Version int    `json:"version"`
+	}
+	if err := r.get(ctx, fmt.Sprintf("/schemas/ids/%d/versions", id), &versions); err == nil && len(versions) > 0 {
+		s.Subject, s.Version = versions[0].Subject, versions[0].Version
+	}
+	s.ID = id
+	return s, nil
+}
+
+func (r *httpRegistry) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query schema registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read schema registry response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: schema registry has no %s", ErrInvalidPayload, path)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("schema registry returned status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode schema registry response: %w", err)
+	}
+	return nil
+}
+
+type dirRegistry struct {
+	dir string
+}
+
+// NewDirRegistry reads schemas from <dir>/<id>.json files holding the
+// registry's JSON representation: {"subject", "version", "schemaType", "schema"}
+func NewDirRegistry(dir string) *dirRegistry {
+	return &dirRegistry{dir: dir}
+}
+
+func (r *dirRegistry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, strconv.Itoa(id)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Schema{}, fmt.Errorf("%w: no schema with ID %d in %s", ErrInvalidPayload, id, r.dir)
+	}
+	if err != nil {
+		return Schema{}, fmt.Errorf("failed to read schema %d: %w", id, err)
+	}
+
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Schema{}, fmt.Errorf("%w: failed to decode schema %d: %v", ErrInvalidPayload, id, err)
+	}
+	s.ID = id
+	return s, nil
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errTruncated = errors.New("payload truncated")
+
+// wireReader reads the primitives shared by the Avro and Protobuf encodings
+type wireReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *wireReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *wireReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+// zigzag reads a zigzag encoded varint, as used by Avro ints and longs
+func (r *wireReader) zigzag() (int64, error) {
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *wireReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, errTruncated
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *wireReader) fixed32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *wireReader) fixed64() (uint64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// lengthDelimited reads a Protobuf length prefixed field
+func (r *wireReader) lengthDelimited() ([]byte, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)) {
+		return nil, errTruncated
+	}
+	return r.next(int(n))
+}
+
+// skip passes over a Protobuf field of the given wire type
+func (r *wireReader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case 0:
+		_, err = r.uvarint()
+	case 1:
+		_, err = r.next(8)
+	case 2:
+		_, err = r.lengthDelimited()
+	case 5:
+		_, err = r.next(4)
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return err
+}
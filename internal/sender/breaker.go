@@ -0,0 +1,45 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"notification-service/internal/health"
+	"notification-service/internal/retry"
+)
+
+// breakingEmailSender fails fast while the provider's circuit is open
+type breakingEmailSender struct {
+	EmailSender
+	breaker *health.Breaker
+}
+
+// WithCircuitBreaker wraps s so its failures are counted by breaker. Errors
+// of an open circuit wrap health.ErrCircuitOpen.
+func WithCircuitBreaker(s EmailSender, breaker *health.Breaker) EmailSender {
+	return &breakingEmailSender{EmailSender: s, breaker: breaker}
+}
+
+func (s *breakingEmailSender) SendEmail(ctx context.Context, to, subject, text, html string) (SendResult, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return SendResult{}, err
+	}
+	// A caller out of time says nothing about the server
+	if err := ctx.Err(); err != nil {
+		return SendResult{}, err
+	}
+	result, err := s.EmailSender.SendEmail(ctx, to, subject, text, html)
+	var reply *textproto.Error
+	switch {
+	case err == nil:
+	case errors.As(err, &reply) && reply.Code >= 500:
+		// A permanent SMTP reply, such as an unknown mailbox, comes from a server that is up
+		s.breaker.Record(nil)
+		return result, err
+	case retry.IsPermanent(err), errors.Is(err, context.Canceled):
+		// Rejected before the server was asked, or abandoned by the caller.
+		// A deadline that passes during the send is the server's timeout.
+		return result, err
+	}
+	return result, s.breaker.Record(err)
+}
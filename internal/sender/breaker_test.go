@@ -0,0 +1,54 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"notification-service/internal/health"
+	"notification-service/internal/retry"
+	"testing"
+)
+
+type failingEmailSender struct {
+	err error
+}
+
+func (f failingEmailSender) Name() string { return "fake" }
+
+func (f failingEmailSender) SendEmail(ctx context.Context, to, subject, text, html string) (SendResult, error) {
+	return SendResult{}, f.err
+}
+
+func TestCircuitBreakerCountsOnlyServerFailures(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		wantOpen bool
+	}{
+		{name: "connection refused", ctx: context.Background(), err: errors.New("dial tcp: connection refused"), wantOpen: true},
+		{name: "temporary reply", ctx: context.Background(), err: &textproto.Error{Code: 421, Msg: "service not available"}, wantOpen: true},
+		{name: "server timeout", ctx: context.Background(), err: fmt.Errorf("smtp: %w", context.DeadlineExceeded), wantOpen: true},
+		{name: "permanent reply", ctx: context.Background(), err: &textproto.Error{Code: 550, Msg: "mailbox unavailable"}},
+		{name: "invalid recipient", ctx: context.Background(), err: retry.Permanent(errors.New("invalid recipient address"))},
+		{name: "canceled during send", ctx: context.Background(), err: context.Canceled},
+		{name: "caller out of time", ctx: canceled, err: errors.New("never sent")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := health.NewBreaker("smtp", 2, func(ctx context.Context) error { return nil })
+			s := WithCircuitBreaker(failingEmailSender{err: tt.err}, breaker)
+			for range 3 {
+				s.SendEmail(tt.ctx, "user@example.com", "subject", "text", "")
+			}
+			if open := breaker.Allow() != nil; open != tt.wantOpen {
+				t.Errorf("circuit open = %v, want %v", open, tt.wantOpen)
+			}
+		})
+	}
+}
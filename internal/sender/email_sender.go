@@ -2,14 +2,33 @@ package sender
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"notification-service/internal/retry"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jordan-wright/email"
+	log "github.com/sirupsen/logrus"
 )
 
+// SendResult identifies a sent email. MessageID is the Message-ID header we
+// set; ProviderMessageID is the ID assigned by API-based providers, if any.
+// Both let bounces and provider events be matched to the log entry.
+type SendResult struct {
+	MessageID         string
+	ProviderMessageID string
+}
+
 type EmailSender interface {
-	SendEmail(ctx context.Context, to, subject, body string) error
+	// Name identifies the provider in email logs
+	Name() string
+	// SendEmail sends a multipart email; html may be empty for text-only mail
+	SendEmail(ctx context.Context, to, subject, text, html string) (SendResult, error)
 }
 
 type SMTPEmailSender struct {
@@ -24,15 +43,121 @@ func NewSMTPEmailSender(host, port, user, pass, from string) *SMTPEmailSender {
 	return &SMTPEmailSender{host: host, port: port, user: user, pass: pass, from: from}
 }
 
-func (s *SMTPEmailSender) SendEmail(ctx context.Context, to, subject, body string) error {
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
-	auth := smtp.PlainAuth("", s.user, s.pass, s.host)
+func (s *SMTPEmailSender) Name() string {
+	return "smtp"
+}
+
+// SendEmail delivers the email over a new connection, which is abandoned
+// once ctx is done
+func (s *SMTPEmailSender) SendEmail(ctx context.Context, to, subject, text, html string) (SendResult, error) {
+	messageID := s.newMessageID()
 
 	e := email.NewEmail()
 	e.From = s.from
 	e.To = []string{to}
 	e.Subject = subject
-	e.Text = []byte(body)
+	e.Text = []byte(text)
+	if html != "" {
+		e.HTML = []byte(html)
+	}
+	e.Headers.Set("Message-Id", messageID)
+
+	raw, err := e.Bytes()
+	if err != nil {
+		return SendResult{}, fmt.Errorf("failed to build email: %w", err)
+	}
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return SendResult{}, fmt.Errorf("invalid sender address: %w", err)
+	}
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return SendResult{}, retry.Permanent(fmt.Errorf("invalid recipient address: %w", err))
+	}
+
+	client, stop, err := s.dial(ctx)
+	if err != nil {
+		return SendResult{}, err
+	}
+	defer stop()
+	defer client.Close()
+
+	// The same conversation as smtp.SendMail
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return SendResult{}, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(smtp.PlainAuth("", s.user, s.pass, s.host)); err != nil {
+			return SendResult{}, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return SendResult{}, fmt.Errorf("sender rejected: %w", err)
+	}
+	if err := client.Rcpt(recipient.Address); err != nil {
+		return SendResult{}, fmt.Errorf("recipient rejected: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return SendResult{}, fmt.Errorf("failed to start message data: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return SendResult{}, fmt.Errorf("failed to write message data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return SendResult{}, fmt.Errorf("message rejected: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		log.WithError(err).Debug("SMTP server did not acknowledge QUIT")
+	}
+	return SendResult{MessageID: messageID}, nil
+}
+
+// Ping connects to the SMTP server and greets it without sending anything
+func (s *SMTPEmailSender) Ping(ctx context.Context) error {
+	client, stop, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer client.Close()
+	return client.Quit()
+}
+
+// dial connects to the server and greets it. The connection times out with
+// ctx; stop must be called once the client is no longer used.
+func (s *SMTPEmailSender) dial(ctx context.Context) (client *smtp.Client, stop func() bool, err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.host, s.port))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop = context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+
+	client, err = smtp.NewClient(conn, s.host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	if err := client.Hello("localhost"); err != nil {
+		stop()
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	return client, stop, nil
+}
 
-	return e.Send(addr, auth)
+// newMessageID builds an RFC 5322 Message-ID in the domain of the sender address
+func (s *SMTPEmailSender) newMessageID() string {
+	domain := s.host
+	if at := strings.LastIndex(s.from, "@"); at >= 0 {
+		domain = strings.Trim(s.from[at+1:], "> ")
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
 }
@@ -0,0 +1,51 @@
+package sender
+
+import (
+	"context"
+	"notification-service/internal/retry"
+	"sync"
+)
+
+type Push struct {
+	Platform string
+	Token    string
+	Title    string
+	Body     string
+}
+
+// FakePushSender keeps sent pushes in memory. Tokens marked invalid are
+// rejected with ErrInvalidToken, like a real provider would.
+type FakePushSender struct {
+	mu      sync.Mutex
+	sent    []Push
+	invalid map[string]bool
+}
+
+func NewFakePushSender() *FakePushSender {
+	return &FakePushSender{invalid: make(map[string]bool)}
+}
+
+func (s *FakePushSender) SendPush(ctx context.Context, platform, token, title, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.invalid[token] {
+		return retry.Permanent(ErrInvalidToken)
+	}
+	s.sent = append(s.sent, Push{Platform: platform, Token: token, Title: title, Body: body})
+	return nil
+}
+
+// MarkInvalid makes the fake reject token as unregistered
+func (s *FakePushSender) MarkInvalid(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalid[token] = true
+}
+
+// Sent returns a copy of the pushes sent so far
+func (s *FakePushSender) Sent() []Push {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Push(nil), s.sent...)
+}
@@ -0,0 +1,48 @@
+package sender
+
+import (
+	"context"
+	"sync"
+)
+
+type SMS struct {
+	To   string
+	Text string
+}
+
+// FakeSMSSender keeps sent messages in memory. It is meant for tests and
+// local runs without an SMS gateway.
+type FakeSMSSender struct {
+	mu   sync.Mutex
+	sent []SMS
+	err  error
+}
+
+func NewFakeSMSSender() *FakeSMSSender {
+	return &FakeSMSSender{}
+}
+
+func (s *FakeSMSSender) SendSMS(ctx context.Context, to, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, SMS{To: to, Text: text})
+	return nil
+}
+
+// FailWith makes every following SendSMS call return err; nil restores success
+func (s *FakeSMSSender) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Sent returns a copy of the messages sent so far
+func (s *FakeSMSSender) Sent() []SMS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMS(nil), s.sent...)
+}
@@ -0,0 +1,97 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notification-service/internal/retry"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned when the push provider reports that a device
+// token is unknown or no longer registered. Such tokens should be pruned.
+var ErrInvalidToken = errors.New("invalid device token")
+
+type PushSender interface {
+	SendPush(ctx context.Context, platform, token, title, body string) error
+}
+
+// HTTPPushSender talks to a push provider over HTTP/2. The provider URL can
+// point to a local stub, in which case plain HTTP/1.1 is used.
+type HTTPPushSender struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewHTTPPushSender(url, apiKey string, timeout time.Duration) *HTTPPushSender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+
+	return &HTTPPushSender{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+type pushRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+type pushErrorResponse struct {
+	Reason string `json:"reason"`
+}
+
+// invalidTokenReasons are the provider reasons that mean the token will never work again
+var invalidTokenReasons = map[string]bool{
+	"badDeviceToken":  true,
+	"unregistered":    true,
+	"invalidToken":    true,
+	"notRegistered":   true,
+	"deviceTokenGone": true,
+}
+
+func (s *HTTPPushSender) SendPush(ctx context.Context, platform, token, title, body string) error {
+	payload, err := json.Marshal(pushRequest{Token: token, Platform: platform, Title: title, Body: body})
+	if err != nil {
+		return fmt.Errorf("failed to encode push request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call push provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusGone {
+		return retry.Permanent(ErrInvalidToken)
+	}
+	var perr pushErrorResponse
+	if json.Unmarshal(respBody, &perr) == nil && invalidTokenReasons[strings.TrimSpace(perr.Reason)] {
+		return retry.Permanent(fmt.Errorf("%w: %s", ErrInvalidToken, perr.Reason))
+	}
+	return fmt.Errorf("push provider returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+}
@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"notification-service/internal/retry"
+	"time"
+)
+
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, text string) error
+}
+
+// HTTPSMSSender delivers SMS through an HTTP gateway that accepts
+// a JSON body of the form {"from": "...", "to": "...", "text": "..."}
+type HTTPSMSSender struct {
+	url    string
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+func NewHTTPSMSSender(url, apiKey, from string, timeout time.Duration) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		url:    url,
+		apiKey: apiKey,
+		from:   from,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+type smsRequest struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+func (s *HTTPSMSSender) SendSMS(ctx context.Context, to, text string) error {
+	payload, err := json.Marshal(smsRequest{From: s.from, To: to, Text: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode sms request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create sms request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call sms gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("sms gateway returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+		// The gateway rejected the request itself, such as an invalid number
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"notification-service/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// eventCategories maps event types to the preference category they fall under
+var eventCategories = map[domain.EventType]domain.Category{
+	domain.EventPurchase: domain.CategoryTransactional,
+	domain.EventRefund:   domain.CategoryTransactional,
+}
+
+// checkPreferences loads the user's preferences and reports whether the
+// notification may be sent. Opt-outs of mandatory events are overridden
+// and recorded.
+func (s *notificationService) checkPreferences(ctx context.Context, eventType domain.EventType, userID, transactionID string) (domain.NotificationPreferences, bool, error) {
+	if s.preferences == nil || userID == "" {
+		return domain.DefaultPreferences(userID), true, nil
+	}
+
+	prefs, err := s.preferences.Get(ctx, userID)
+	if err != nil {
+		log.WithError(err).WithField("user_id", userID).Error("Failed to load notification preferences")
+		return prefs, false, err
+	}
+
+	category := eventCategories[eventType]
+	if prefs.OptedIn(category) {
+		return prefs, true, nil
+	}
+
+	if !s.mandatoryEvents[eventType] {
+		log.WithFields(log.Fields{
+			"user_id":        userID,
+			"event_type":     eventType,
+			"category":       category,
+			"transaction_id": transactionID,
+		}).Info("User opted out, notification not sent")
+		return prefs, false, nil
+	}
+
+	override := domain.PreferenceOverride{
+		UserID:        userID,
+		EventType:     eventType,
+		TransactionID: transactionID,
+		Category:      category,
+		Reason:        "mandatory transactional notification",
+	}
+	if err := s.preferences.RecordOverride(ctx, override); err != nil {
+		log.WithError(err).WithField("user_id", userID).Error("Failed to record preference override")
+		return prefs, false, err
+	}
+	log.WithFields(log.Fields{
+		"user_id":        userID,
+		"event_type":     eventType,
+		"transaction_id": transactionID,
+	}).Info("Opt-out overridden for mandatory notification")
+	return prefs, true, nil
+}
@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
-	"fmt"
+	"encoding/hex"
+	"errors"
 	"notification-service/internal/domain"
+	"notification-service/internal/health"
+	"notification-service/internal/retry"
 	"notification-service/internal/sender"
-	"notification-service/internal/validator"
+	"notification-service/internal/templates"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -17,167 +22,644 @@ type EmailRepository interface {
 	SaveLog(ctx context.Context, log domain.EmailLog) error
 }
 
+// NotificationLogRepository defines the interface for channel-agnostic notification log data access
+type NotificationLogRepository interface {
+	SaveLog(ctx context.Context, log domain.NotificationLog) error
+	NotifiedChannels(ctx context.Context, transactionID string, eventType domain.EventType, refundID string) ([]domain.Channel, error)
+}
+
+// DeviceTokenRepository defines the interface for the push device token registry
+type DeviceTokenRepository interface {
+	ListByUser(ctx context.Context, userID string) ([]domain.DeviceToken, error)
+	Delete(ctx context.Context, token string) error
+}
+
+// WebhookPublisher forwards notified events to partner webhooks
+type WebhookPublisher interface {
+	Publish(eventType domain.EventType, transactionID, refundID string, data any) error
+}
+
+// AlertEvaluator watches the event stream for conditions finance should hear about
+type AlertEvaluator interface {
+	Observe(ctx context.Context, event any)
+}
+
+// PreferenceRepository defines the interface for user notification preferences
+type PreferenceRepository interface {
+	Get(ctx context.Context, userID string) (domain.NotificationPreferences, error)
+	RecordOverride(ctx context.Context, override domain.PreferenceOverride) error
+}
+
+// SuppressionRepository defines the interface for the recipient suppression list
+type SuppressionRepository interface {
+	IsSuppressed(ctx context.Context, address string) (bool, error)
+}
+
+// BodyRepository defines the interface for storing rendered messages for audit
+type BodyRepository interface {
+	Save(ctx context.Context, body domain.EmailBody) (string, error)
+}
+
+// RetryPolicies selects how sends of an event type on a channel are retried
+type RetryPolicies interface {
+	For(eventType domain.EventType, channel domain.Channel) retry.Policy
+}
+
+// DefaultRetryPolicy is used for every channel unless WithRetryPolicies is
+// set; its AttemptTimeout also bounds the sends of policies without one
+var DefaultRetryPolicy = retry.Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, AttemptTimeout: 10 * time.Second}
+
+// ChannelRouter decides which channels an event is delivered through
+type ChannelRouter interface {
+	Route(eventType domain.EventType, country string) []domain.Channel
+}
+
 type notificationService struct {
-	emailSender     sender.EmailSender
-	emailRepository EmailRepository
+	emailSender               sender.EmailSender
+	emailRepository           EmailRepository
+	smsSender                 sender.SMSSender
+	pushSender                sender.PushSender
+	deviceTokenRepository     DeviceTokenRepository
+	router                    ChannelRouter
+	notificationLogRepository NotificationLogRepository
+	webhooks                  WebhookPublisher
+	alerts                    AlertEvaluator
+	preferences               PreferenceRepository
+	mandatoryEvents           map[domain.EventType]bool
+	suppressions              SuppressionRepository
+	bodies                    BodyRepository
+	statuses                  StatusPublisher
+	retries                   RetryPolicies
+
+	mu sync.Mutex
+	// unsaved are the outcomes of sends whose logs could not be saved
+	unsaved map[sentKey][]outcome
 }
 
-func NewNotificationService(emailSender sender.EmailSender, emailRepository EmailRepository) *notificationService {
-	return &notificationService{emailSender: emailSender, emailRepository: emailRepository}
+// sentKey identifies the notification about an event on one channel. An
+// event is its type and transaction, and its refund for refunds.
+type sentKey struct {
+	transactionID string
+	eventType     domain.EventType
+	refundID      string
+	channel       domain.Channel
 }
 
-func (s *notificationService) ProcessPurchase(ctx context.Context, purchase domain.PurchaseInfo) error {
-	if err := validator.ValidatePurchaseInfo(purchase); err != nil {
-		log.WithFields(log.Fields{
-			"error":          err,
-			"purchase":       purchase,
-			"transaction_id": purchase.TransactionID,
-		}).Error("Purchase info validation failed")
-		return fmt.Errorf("validation error: %w", err)
-	}
-
-	subject := "Покупка монет успешно завершена!"
-	var body string
-	if purchase.ProductID != "" {
-		body = fmt.Sprintf(
-			"Здравствуйте!\n\nВы успешно приобрели товар (ID: %s).\nКоличество монет: %d\nID вашей транзакции: %s\n\nСпасибо за покупку!",
-			purchase.ProductID,
-			purchase.CoinsPurchased,
-			purchase.TransactionID,
-		)
-	} else {
-		body = fmt.Sprintf(
-			"Здравствуйте!\n\nВы успешно приобрели %d монет.\nID вашей транзакции: %s\n\nСпасибо за покупку!",
-			purchase.CoinsPurchased,
-			purchase.TransactionID,
-		)
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	// Retry sending email up to 3 times with exponential backoff
-	maxAttempts := 3
-	initialDelay := 1 * time.Second
-	var err error
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		err = s.emailSender.SendEmail(ctx, purchase.UserEmail, subject, body)
-		if err == nil {
-			if attempt > 1 {
-				log.WithFields(log.Fields{
-					"attempt":      attempt,
-					"max_attempts": maxAttempts,
-					"email":        purchase.UserEmail,
-				}).Info("Email sent successfully after retry")
+func keyOf(n domain.Notification, channel domain.Channel) sentKey {
+	return sentKey{transactionID: n.TransactionID, eventType: n.Type, refundID: n.RefundID, channel: channel}
+}
+
+// outcome is the result of one send, to be logged and published
+type outcome struct {
+	email    *domain.EmailLog // nil once saved, and for channels other than email
+	entry    domain.NotificationLog
+	attempts int
+	cause    error
+}
+
+// Option configures optional dependencies of the notification service
+type Option func(*notificationService)
+
+func WithSMSSender(smsSender sender.SMSSender) Option {
+	return func(s *notificationService) { s.smsSender = smsSender }
+}
+
+func WithPushSender(pushSender sender.PushSender, deviceTokenRepository DeviceTokenRepository) Option {
+	return func(s *notificationService) {
+		s.pushSender = pushSender
+		s.deviceTokenRepository = deviceTokenRepository
+	}
+}
+
+func WithRouter(router ChannelRouter) Option {
+	return func(s *notificationService) { s.router = router }
+}
+
+func WithNotificationLogRepository(repository NotificationLogRepository) Option {
+	return func(s *notificationService) { s.notificationLogRepository = repository }
+}
+
+func WithWebhookPublisher(webhooks WebhookPublisher) Option {
+	return func(s *notificationService) { s.webhooks = webhooks }
+}
+
+func WithAlertEvaluator(alerts AlertEvaluator) Option {
+	return func(s *notificationService) { s.alerts = alerts }
+}
+
+// WithPreferences makes the service honour user preferences. Opt-outs are
+// overridden for the mandatory event types, and every override is recorded.
+func WithPreferences(preferences PreferenceRepository, mandatoryEvents []domain.EventType) Option {
+	return func(s *notificationService) {
+		s.preferences = preferences
+		s.mandatoryEvents = make(map[domain.EventType]bool, len(mandatoryEvents))
+		for _, eventType := range mandatoryEvents {
+			s.mandatoryEvents[eventType] = true
+		}
+	}
+}
+
+func WithSuppressionList(suppressions SuppressionRepository) Option {
+	return func(s *notificationService) { s.suppressions = suppressions }
+}
+
+func WithBodyStore(bodies BodyRepository) Option {
+	return func(s *notificationService) { s.bodies = bodies }
+}
+
+// WithRetryPolicies replaces the default retry policy of every channel
+func WithRetryPolicies(retries RetryPolicies) Option {
+	return func(s *notificationService) { s.retries = retries }
+}
+
+func NewNotificationService(emailSender sender.EmailSender, emailRepository EmailRepository, opts ...Option) *notificationService {
+	s := &notificationService{
+		emailSender:     emailSender,
+		emailRepository: emailRepository,
+		unsaved:         make(map[sentKey][]outcome),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ProcessEvent notifies the user about an event of any registered type and
+// forwards it to the alert rules and partner webhooks
+func (s *notificationService) ProcessEvent(ctx context.Context, n domain.Notification) error {
+	if s.alerts != nil {
+		s.alerts.Observe(ctx, n.Payload)
+	}
+	if err := s.notify(ctx, n); err != nil {
+		return err
+	}
+	if n.WebhookData != nil {
+		s.publishWebhook(n.Type, n.TransactionID, n.RefundID, n.WebhookData)
+	}
+	return nil
+}
+
+// notify has no deadline of its own: every send is bounded by the attempt
+// timeout of its retry policy, and the waits between attempts by the policy
+func (s *notificationService) notify(ctx context.Context, n domain.Notification) error {
+	prefs, allowed, err := s.checkPreferences(ctx, n.Type, n.UserID, n.TransactionID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return nil
+	}
+
+	msg, err := templates.Render(n.Template, prefs.PreferredLanguage, n.TemplateData)
+	if err != nil {
+		log.WithError(err).WithField("event_type", n.Type).Error("Failed to render notification")
+		return err
+	}
+	subject, body := msg.Subject, msg.Text
+
+	channels := s.resolveChannels(n.Type, n.Country, n.UserID, n.Phone, prefs)
+	handled, err := s.handledChannels(ctx, n)
+	if err != nil {
+		return err
+	}
+	shortText := msg.Short
+	if channels[domain.ChannelSMS] && !handled[domain.ChannelSMS] {
+		if err := s.sendSMS(ctx, n, shortText); err != nil {
+			return err
+		}
+	}
+	if channels[domain.ChannelPush] {
+		// A push already sent means the user has devices
+		hasDevices := handled[domain.ChannelPush]
+		if !hasDevices {
+			hasDevices, err = s.sendPush(ctx, n, subject, shortText)
+			if err != nil {
+				return err
 			}
-			break
 		}
+		if !hasDevices && !channels[domain.ChannelEmail] {
+			log.WithFields(log.Fields{
+				"event_type": n.Type,
+				"user_id":    n.UserID,
+			}).Warn("User has no registered devices, falling back to email")
+			channels[domain.ChannelEmail] = true
+		}
+	}
+	if !channels[domain.ChannelEmail] || handled[domain.ChannelEmail] {
+		return nil
+	}
+
+	suppressed, err := s.isSuppressed(ctx, n.Email)
+	if err != nil {
+		return err
+	}
 
-		// If error and not last attempt - retry
-		if attempt < maxAttempts {
+	var result sender.SendResult
+	attempts := 0
+	started := time.Now()
+	if !suppressed {
+		policy := s.retryPolicy(n.Type, domain.ChannelEmail)
+		attempts, err = policy.Do(ctx, func(ctx context.Context) error {
+			var sendErr error
+			result, sendErr = s.emailSender.SendEmail(ctx, n.Email, subject, body, msg.HTML)
+			return sendErr
+		}, func(attempt int, err error, delay time.Duration) {
 			log.WithFields(log.Fields{
 				"attempt":      attempt,
-				"max_attempts": maxAttempts,
+				"max_attempts": policy.MaxAttempts,
 				"error":        err,
-				"email":        purchase.UserEmail,
+				"email":        n.Email,
+				"event_type":   n.Type,
+				"delay":        delay,
 			}).Warn("Failed to send email, retrying...")
-
-			time.Sleep(initialDelay)
-			initialDelay *= 2
+		})
+		if err == nil && attempts > 1 {
+			log.WithFields(log.Fields{
+				"attempt":      attempts,
+				"max_attempts": policy.MaxAttempts,
+				"email":        n.Email,
+				"event_type":   n.Type,
+			}).Info("Email sent successfully after retry")
 		}
 	}
 
+	if errors.Is(err, health.ErrCircuitOpen) {
+		// Not a failed email: the consumer pauses and hands the event over again
+		log.WithError(err).WithField("event_type", n.Type).Warn("Email provider is unavailable, email not sent")
+		return err
+	}
+	if ctx.Err() != nil {
+		// Shutting down: the event is handled again, not logged as failed
+		return err
+	}
+
 	logEntry := domain.EmailLog{
-		TransactionID:  purchase.TransactionID,
-		RecipientEmail: purchase.UserEmail,
-		Subject:        subject,
+		TransactionID:     n.TransactionID,
+		EventType:         n.Type,
+		RefundID:          n.RefundID,
+		UserID:            n.UserID,
+		RecipientEmail:    n.Email,
+		Subject:           subject,
+		MessageID:         result.MessageID,
+		Attempts:          attempts,
+		Provider:          s.emailSender.Name(),
+		ProviderMessageID: result.ProviderMessageID,
+		TemplateName:      msg.Name,
+		TemplateVersion:   msg.Version,
+		Locale:            msg.Locale,
+		Duration:          time.Since(started),
+		BodyHash:          hashBody(body),
 	}
 
-	if err != nil {
-		log.WithError(err).Error("Failed to send confirmation email via SMTP")
+	if suppressed {
+		log.WithFields(log.Fields{
+			"transaction_id": n.TransactionID,
+			"event_type":     n.Type,
+		}).Info("Recipient is suppressed, email not sent")
+		logEntry.Status = domain.StatusSuppressed
+	} else if err != nil {
+		log.WithError(err).WithField("event_type", n.Type).Error("Failed to send email via SMTP")
 		logEntry.Status = domain.StatusFailed
 		logEntry.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
 	} else {
-		log.WithField("email", purchase.UserEmail).Info("Confirmation email sent successfully via SMTP")
+		log.WithFields(log.Fields{
+			"email":      n.Email,
+			"event_type": n.Type,
+		}).Info("Email sent successfully via SMTP")
 		logEntry.Status = domain.StatusSent
 	}
 
-	if err := s.emailRepository.SaveLog(ctx, logEntry); err != nil {
-		log.WithError(err).Error("Failed to save email log to database")
-		return err
+	if !suppressed {
+		logEntry.BodyID = s.storeBody(ctx, subject, body, msg.HTML)
 	}
 
-	return nil
+	notificationLog := domain.NotificationLog{
+		TransactionID: n.TransactionID,
+		EventType:     n.Type,
+		RefundID:      n.RefundID,
+		Channel:       domain.ChannelEmail,
+		Recipient:     n.Email,
+		Status:        logEntry.Status,
+		ErrorMessage:  logEntry.ErrorMessage,
+		BodyID:        logEntry.BodyID,
+	}
+	return s.saveOutcomes(ctx, keyOf(n, domain.ChannelEmail), outcome{email: &logEntry, entry: notificationLog, attempts: attempts, cause: err})
 }
 
-func (s *notificationService) ProcessRefund(ctx context.Context, refund domain.RefundInfo) error {
-	amountDollars := float64(refund.Amount) / 100.0
-
-	subject := "Возврат средств обработан"
-	body := fmt.Sprintf(
-		"Здравствуйте!\n\nВаш возврат средств был успешно обработан.\n\n"+
-			"Сумма возврата: $%.2f\n"+
-			"Списано монет: %d\n"+
-			"ID транзакции: %s\n"+
-			"ID возврата: %s\n\n",
-		amountDollars,
-		refund.CoinsDeducted,
-		refund.TransactionID,
-		refund.RefundID,
-	)
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	// Retry sending email up to 3 times with exponential backoff
-	maxAttempts := 3
-	initialDelay := 1 * time.Second
-	var err error
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		err = s.emailSender.SendEmail(ctx, refund.UserEmail, subject, body)
+// handledChannels returns the channels the event was already notified on,
+// so handling it again after a failure sends nothing twice. Sends whose logs
+// could not be saved in this process are logged now instead.
+func (s *notificationService) handledChannels(ctx context.Context, n domain.Notification) (map[domain.Channel]bool, error) {
+	handled := make(map[domain.Channel]bool)
+	if s.notificationLogRepository != nil {
+		channels, err := s.notificationLogRepository.NotifiedChannels(ctx, n.TransactionID, n.Type, n.RefundID)
+		if err != nil {
+			log.WithError(err).WithField("transaction_id", n.TransactionID).Error("Failed to look up notified channels")
+			return nil, err
+		}
+		for _, ch := range channels {
+			handled[ch] = true
+		}
+	}
+
+	for _, ch := range []domain.Channel{domain.ChannelEmail, domain.ChannelSMS, domain.ChannelPush} {
+		key := keyOf(n, ch)
+		s.mu.Lock()
+		outcomes, ok := s.unsaved[key]
+		delete(s.unsaved, key)
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+		handled[ch] = true
+		if err := s.saveOutcomes(ctx, key, outcomes...); err != nil {
+			return nil, err
+		}
+	}
+	return handled, nil
+}
+
+// saveOutcomes logs the sends of one channel and publishes their status.
+// Outcomes whose logs fail are kept, so handling the event again saves them
+// instead of sending again.
+func (s *notificationService) saveOutcomes(ctx context.Context, key sentKey, outcomes ...outcome) error {
+	var failed []outcome
+	var firstErr error
+	for _, o := range outcomes {
+		err := s.saveOutcome(ctx, &o)
 		if err == nil {
-			if attempt > 1 {
-				log.WithFields(log.Fields{
-					"attempt":      attempt,
-					"max_attempts": maxAttempts,
-					"email":        refund.UserEmail,
-				}).Info("Refund email sent successfully after retry")
-			}
-			break
+			s.publishStatus(ctx, o.entry, o.attempts, o.cause)
+			continue
+		}
+		failed = append(failed, o)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(failed) > 0 {
+		s.mu.Lock()
+		s.unsaved[key] = append(s.unsaved[key], failed...)
+		s.mu.Unlock()
+	}
+	return firstErr
+}
+
+func (s *notificationService) saveOutcome(ctx context.Context, o *outcome) error {
+	if o.email != nil {
+		if err := s.emailRepository.SaveLog(ctx, *o.email); err != nil {
+			log.WithError(err).Error("Failed to save email log to database")
+			return err
+		}
+		o.email = nil
+	}
+	return s.saveNotificationLog(ctx, o.entry)
+}
+
+// resolveChannels applies the routing rules and the user's preferences to an
+// event. The preferred channel wins over routing when it can be delivered,
+// and quiet hours hold back SMS and push. SMS and push are replaced with
+// email when the recipient or the sender is missing.
+func (s *notificationService) resolveChannels(eventType domain.EventType, country, userID, phone string, prefs domain.NotificationPreferences) map[domain.Channel]bool {
+	channels := map[domain.Channel]bool{domain.ChannelEmail: true}
+	if s.router != nil {
+		channels = map[domain.Channel]bool{}
+		for _, ch := range s.router.Route(eventType, country) {
+			channels[ch] = true
 		}
+	}
 
-		if attempt < maxAttempts {
+	if prefs.PreferredChannel != "" && s.canDeliver(prefs.PreferredChannel, userID, phone) {
+		channels = map[domain.Channel]bool{prefs.PreferredChannel: true}
+	}
+
+	quiet := prefs.InQuietHours(time.Now())
+	for _, ch := range []domain.Channel{domain.ChannelSMS, domain.ChannelPush} {
+		if !channels[ch] {
+			continue
+		}
+		if quiet || !s.canDeliver(ch, userID, phone) {
+			log.WithFields(log.Fields{
+				"event_type":  eventType,
+				"country":     country,
+				"channel":     ch,
+				"quiet_hours": quiet,
+			}).Warn("Channel cannot be used for this notification, falling back to email")
+			delete(channels, ch)
+			channels[domain.ChannelEmail] = true
+		}
+	}
+	return channels
+}
+
+// canDeliver reports whether a sender and a recipient exist for the channel
+func (s *notificationService) canDeliver(ch domain.Channel, userID, phone string) bool {
+	switch ch {
+	case domain.ChannelEmail:
+		return true
+	case domain.ChannelSMS:
+		return phone != "" && s.smsSender != nil
+	case domain.ChannelPush:
+		return userID != "" && s.pushSender != nil
+	}
+	return false
+}
+
+func (s *notificationService) sendSMS(ctx context.Context, n domain.Notification, text string) error {
+	suppressed, err := s.isSuppressed(ctx, n.Phone)
+	if err != nil {
+		return err
+	}
+
+	attempts := 0
+	if !suppressed {
+		policy := s.retryPolicy(n.Type, domain.ChannelSMS)
+		attempts, err = policy.Do(ctx, func(ctx context.Context) error {
+			return s.smsSender.SendSMS(ctx, n.Phone, text)
+		}, func(attempt int, err error, delay time.Duration) {
 			log.WithFields(log.Fields{
 				"attempt":      attempt,
-				"max_attempts": maxAttempts,
+				"max_attempts": policy.MaxAttempts,
 				"error":        err,
-				"email":        refund.UserEmail,
-			}).Warn("Failed to send refund email, retrying...")
-
-			time.Sleep(initialDelay)
-			initialDelay *= 2
+				"event_type":   n.Type,
+				"delay":        delay,
+			}).Warn("Failed to send SMS, retrying...")
+		})
+		if ctx.Err() != nil {
+			return err
 		}
 	}
 
-	logEntry := domain.EmailLog{
-		TransactionID:  refund.TransactionID,
-		RecipientEmail: refund.UserEmail,
-		Subject:        subject,
+	logEntry := domain.NotificationLog{
+		TransactionID: n.TransactionID,
+		EventType:     n.Type,
+		RefundID:      n.RefundID,
+		Channel:       domain.ChannelSMS,
+		Recipient:     n.Phone,
 	}
 
-	if err != nil {
-		log.WithError(err).Error("Failed to send refund email via SMTP")
+	if !suppressed {
+		logEntry.BodyID = s.storeBody(ctx, "", text, "")
+	}
+
+	if suppressed {
+		log.WithField("transaction_id", n.TransactionID).Info("Recipient is suppressed, SMS not sent")
+		logEntry.Status = domain.StatusSuppressed
+	} else if err != nil {
+		log.WithError(err).WithField("event_type", n.Type).Error("Failed to send SMS")
 		logEntry.Status = domain.StatusFailed
 		logEntry.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
 	} else {
-		log.WithField("email", refund.UserEmail).Info("Refund email sent successfully via SMTP")
+		log.WithFields(log.Fields{
+			"event_type":     n.Type,
+			"transaction_id": n.TransactionID,
+		}).Info("SMS sent successfully")
 		logEntry.Status = domain.StatusSent
 	}
 
-	if err := s.emailRepository.SaveLog(ctx, logEntry); err != nil {
-		log.WithError(err).Error("Failed to save refund email log to database")
-		return err
+	return s.saveOutcomes(ctx, keyOf(n, domain.ChannelSMS), outcome{entry: logEntry, attempts: attempts, cause: err})
+}
+
+// sendPush delivers a push to every device registered by the user and
+// reports whether the user has any left. Tokens rejected by the provider as
+// invalid are removed from the registry, so a user whose every token was
+// invalid has no devices.
+func (s *notificationService) sendPush(ctx context.Context, n domain.Notification, title, body string) (bool, error) {
+	tokens, err := s.deviceTokenRepository.ListByUser(ctx, n.UserID)
+	if err != nil {
+		log.WithError(err).WithField("user_id", n.UserID).Error("Failed to load device tokens")
+		return false, err
+	}
+	if len(tokens) == 0 {
+		return false, nil
 	}
 
+	policy := s.retryPolicy(n.Type, domain.ChannelPush)
+	bodyID := s.storeBody(ctx, title, body, "")
+	// Every device is tried before the logs are saved, so a failed save
+	// never leaves some devices to be notified twice
+	outcomes := make([]outcome, 0, len(tokens))
+	hasDevices := false
+	for _, t := range tokens {
+		logEntry := domain.NotificationLog{
+			TransactionID: n.TransactionID,
+			EventType:     n.Type,
+			RefundID:      n.RefundID,
+			Channel:       domain.ChannelPush,
+			Recipient:     t.Token,
+			BodyID:        bodyID,
+		}
+
+		attempts, err := policy.Do(ctx, func(ctx context.Context) error {
+			return s.pushSender.SendPush(ctx, t.Platform, t.Token, title, body)
+		}, func(attempt int, err error, delay time.Duration) {
+			log.WithFields(log.Fields{
+				"attempt":      attempt,
+				"max_attempts": policy.MaxAttempts,
+				"error":        err,
+				"event_type":   n.Type,
+				"platform":     t.Platform,
+				"delay":        delay,
+			}).Warn("Failed to send push notification, retrying...")
+		})
+		if ctx.Err() != nil {
+			// The devices already sent to are logged, the others are tried on the next handling
+			if saveErr := s.saveOutcomes(ctx, keyOf(n, domain.ChannelPush), outcomes...); saveErr != nil {
+				return true, saveErr
+			}
+			return true, err
+		}
+		if err != nil {
+			logEntry.Status = domain.StatusFailed
+			logEntry.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
+
+			if errors.Is(err, sender.ErrInvalidToken) {
+				log.WithFields(log.Fields{
+					"user_id":  n.UserID,
+					"platform": t.Platform,
+				}).Info("Pruning invalid device token")
+				if err := s.deviceTokenRepository.Delete(ctx, t.Token); err != nil {
+					log.WithError(err).Error("Failed to delete invalid device token")
+				}
+			} else {
+				log.WithError(err).WithFields(log.Fields{
+					"event_type": n.Type,
+					"platform":   t.Platform,
+				}).Error("Failed to send push notification")
+				hasDevices = true
+			}
+		} else {
+			logEntry.Status = domain.StatusSent
+			hasDevices = true
+		}
+		outcomes = append(outcomes, outcome{entry: logEntry, attempts: attempts, cause: err})
+	}
+
+	log.WithFields(log.Fields{
+		"event_type":     n.Type,
+		"transaction_id": n.TransactionID,
+		"devices":        len(tokens),
+	}).Info("Push notifications processed")
+	return hasDevices, s.saveOutcomes(ctx, keyOf(n, domain.ChannelPush), outcomes...)
+}
+
+// storeBody keeps the rendered message of any channel for audit. A failure
+// is logged but does not fail the notification, which has already been sent.
+func (s *notificationService) storeBody(ctx context.Context, subject, text, html string) string {
+	if s.bodies == nil {
+		return ""
+	}
+	id, err := s.bodies.Save(ctx, domain.EmailBody{Subject: subject, Text: text, HTML: html})
+	if err != nil {
+		log.WithError(err).Error("Failed to store message body")
+		return ""
+	}
+	return id
+}
+
+// retryPolicy returns the policy of a channel. An open circuit is never
+// retried: the consumer pauses until the provider is back.
+func (s *notificationService) retryPolicy(eventType domain.EventType, channel domain.Channel) retry.Policy {
+	policy := DefaultRetryPolicy
+	if s.retries != nil {
+		policy = s.retries.For(eventType, channel)
+	}
+	classify := policy.Retryable
+	if classify == nil {
+		classify = retry.IsRetryable
+	}
+	policy.Retryable = func(err error) bool {
+		return !errors.Is(err, health.ErrCircuitOpen) && classify(err)
+	}
+	if policy.AttemptTimeout <= 0 {
+		policy.AttemptTimeout = DefaultRetryPolicy.AttemptTimeout
+	}
+	return policy
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *notificationService) isSuppressed(ctx context.Context, address string) (bool, error) {
+	if s.suppressions == nil {
+		return false, nil
+	}
+	suppressed, err := s.suppressions.IsSuppressed(ctx, address)
+	if err != nil {
+		log.WithError(err).Error("Failed to check suppression list")
+		return false, err
+	}
+	return suppressed, nil
+}
+
+func (s *notificationService) saveNotificationLog(ctx context.Context, entry domain.NotificationLog) error {
+	if s.notificationLogRepository == nil {
+		return nil
+	}
+	if err := s.notificationLogRepository.SaveLog(ctx, entry); err != nil {
+		log.WithError(err).WithField("channel", entry.Channel).Error("Failed to save notification log to database")
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification-service/internal/domain"
+	"notification-service/internal/health"
+	"notification-service/internal/retry"
+	"notification-service/internal/sender"
+	"notification-service/internal/templates"
+	"sync"
+	"testing"
+)
+
+type fakeEmailSender struct {
+	err  error
+	sent []string
+}
+
+func (f *fakeEmailSender) Name() string { return "fake" }
+
+func (f *fakeEmailSender) SendEmail(ctx context.Context, to, subject, text, html string) (sender.SendResult, error) {
+	if f.err != nil {
+		return sender.SendResult{}, f.err
+	}
+	f.sent = append(f.sent, to)
+	return sender.SendResult{MessageID: "message-1"}, nil
+}
+
+type fakeSMSSender struct {
+	sent []string
+}
+
+func (f *fakeSMSSender) SendSMS(ctx context.Context, to, text string) error {
+	f.sent = append(f.sent, to)
+	return nil
+}
+
+type fakeEmailLogs struct {
+	err  error
+	logs []domain.EmailLog
+}
+
+func (f *fakeEmailLogs) SaveLog(ctx context.Context, l domain.EmailLog) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.logs = append(f.logs, l)
+	return nil
+}
+
+// fakeNotificationLogs answers NotifiedChannels from the logs it saved
+type fakeNotificationLogs struct {
+	mu   sync.Mutex
+	logs []domain.NotificationLog
+}
+
+func (f *fakeNotificationLogs) SaveLog(ctx context.Context, l domain.NotificationLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs = append(f.logs, l)
+	return nil
+}
+
+func (f *fakeNotificationLogs) NotifiedChannels(ctx context.Context, transactionID string, eventType domain.EventType, refundID string) ([]domain.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var channels []domain.Channel
+	for _, l := range f.logs {
+		if l.TransactionID == transactionID && l.EventType == eventType && l.RefundID == refundID && l.Status != domain.StatusFailed {
+			channels = append(channels, l.Channel)
+		}
+	}
+	return channels, nil
+}
+
+type fixedRouter []domain.Channel
+
+func (r fixedRouter) Route(eventType domain.EventType, country string) []domain.Channel {
+	return r
+}
+
+func purchase() domain.Notification {
+	return domain.Notification{
+		Type:          domain.EventPurchase,
+		TransactionID: "tx-1",
+		UserID:        "user-1",
+		Email:         "user@example.com",
+		Phone:         "+77011234567",
+		Template:      templates.PurchaseConfirmation,
+		TemplateData:  templates.PurchaseData{ProductID: "coins_100", CoinsPurchased: 100, TransactionID: "tx-1"},
+	}
+}
+
+func refund(refundID string) domain.Notification {
+	return domain.Notification{
+		Type:          domain.EventRefund,
+		TransactionID: "tx-1",
+		RefundID:      refundID,
+		UserID:        "user-1",
+		Email:         "user@example.com",
+		Phone:         "+77011234567",
+		Template:      templates.RefundProcessed,
+		TemplateData:  templates.RefundData{AmountDollars: 5, CoinsDeducted: 50, TransactionID: "tx-1", RefundID: refundID},
+	}
+}
+
+func TestProcessEventNotifiesEveryRefundOfATransaction(t *testing.T) {
+	email := &fakeEmailSender{}
+	sms := &fakeSMSSender{}
+	logs := &fakeNotificationLogs{}
+	s := NewNotificationService(email, &fakeEmailLogs{},
+		WithSMSSender(sms),
+		WithRouter(fixedRouter{domain.ChannelSMS, domain.ChannelEmail}),
+		WithNotificationLogRepository(logs),
+	)
+
+	for _, n := range []domain.Notification{refund("refund-1"), refund("refund-2"), refund("refund-1")} {
+		if err := s.ProcessEvent(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The redelivered first refund is not notified again
+	if len(sms.sent) != 2 || len(email.sent) != 2 {
+		t.Errorf("sent %d SMS and %d emails, want two of each", len(sms.sent), len(email.sent))
+	}
+}
+
+func TestProcessEventSkipsChannelsSentBeforeOpenCircuit(t *testing.T) {
+	email := &fakeEmailSender{err: health.ErrCircuitOpen}
+	sms := &fakeSMSSender{}
+	logs := &fakeNotificationLogs{}
+	s := NewNotificationService(email, &fakeEmailLogs{},
+		WithSMSSender(sms),
+		WithRouter(fixedRouter{domain.ChannelSMS, domain.ChannelEmail}),
+		WithNotificationLogRepository(logs),
+	)
+
+	if err := s.ProcessEvent(context.Background(), purchase()); !errors.Is(err, health.ErrCircuitOpen) {
+		t.Fatalf("ProcessEvent() = %v, want ErrCircuitOpen", err)
+	}
+
+	// The consumer hands the event over again once the provider is back
+	email.err = nil
+	if err := s.ProcessEvent(context.Background(), purchase()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sms.sent) != 1 || len(email.sent) != 1 {
+		t.Errorf("sent %d SMS and %d emails, want one of each", len(sms.sent), len(email.sent))
+	}
+}
+
+func TestProcessEventSavesUnsavedLogInsteadOfSendingAgain(t *testing.T) {
+	email := &fakeEmailSender{}
+	emailLogs := &fakeEmailLogs{err: errors.New("database down")}
+	logs := &fakeNotificationLogs{}
+	s := NewNotificationService(email, emailLogs, WithNotificationLogRepository(logs))
+
+	if err := s.ProcessEvent(context.Background(), purchase()); err == nil {
+		t.Fatal("ProcessEvent() succeeded, want the log error")
+	}
+
+	emailLogs.err = nil
+	if err := s.ProcessEvent(context.Background(), purchase()); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+	if len(emailLogs.logs) != 1 || emailLogs.logs[0].Status != domain.StatusSent || len(logs.logs) != 1 {
+		t.Errorf("saved %d email logs and %d notification logs, want one sent email", len(emailLogs.logs), len(logs.logs))
+	}
+
+	// Handling it once more finds the email in the log
+	if err := s.ProcessEvent(context.Background(), purchase()); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(email.sent))
+	}
+}
+
+func TestNotificationIDIdentifiesOneRecipientOfOneEvent(t *testing.T) {
+	push := domain.NotificationLog{TransactionID: "tx-1", EventType: domain.EventRefund, RefundID: "refund-1", Channel: domain.ChannelPush, Recipient: "token-1"}
+	id := notificationID(push)
+	if notificationID(push) != id {
+		t.Error("the ID of one notification changed")
+	}
+
+	otherDevice, otherRefund := push, push
+	otherDevice.Recipient = "token-2"
+	otherRefund.RefundID = "refund-2"
+	for _, entry := range []domain.NotificationLog{otherDevice, otherRefund} {
+		if notificationID(entry) == id {
+			t.Errorf("%+v shares the ID of %+v", entry, push)
+		}
+	}
+}
+
+// cancelingEmailSender stands for a send interrupted by shutdown
+type cancelingEmailSender struct {
+	cancel context.CancelFunc
+}
+
+func (f *cancelingEmailSender) Name() string { return "fake" }
+
+func (f *cancelingEmailSender) SendEmail(ctx context.Context, to, subject, text, html string) (sender.SendResult, error) {
+	f.cancel()
+	return sender.SendResult{}, ctx.Err()
+}
+
+func TestProcessEventLeavesInterruptedSendsUnlogged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	emailLogs := &fakeEmailLogs{}
+	logs := &fakeNotificationLogs{}
+	s := NewNotificationService(&cancelingEmailSender{cancel: cancel}, emailLogs, WithNotificationLogRepository(logs))
+
+	if err := s.ProcessEvent(ctx, purchase()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessEvent() = %v, want context.Canceled", err)
+	}
+	if len(emailLogs.logs) != 0 || len(logs.logs) != 0 {
+		t.Errorf("logged %d emails and %d notifications, want the send to be tried again instead", len(emailLogs.logs), len(logs.logs))
+	}
+}
+
+// fakePushSender fails the tokens in errs
+type fakePushSender struct {
+	errs map[string]error
+	sent []string
+}
+
+func (f *fakePushSender) SendPush(ctx context.Context, platform, token, title, body string) error {
+	if err := f.errs[token]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, token)
+	return nil
+}
+
+type fakeDeviceTokens struct {
+	tokens []domain.DeviceToken
+}
+
+func (f *fakeDeviceTokens) ListByUser(ctx context.Context, userID string) ([]domain.DeviceToken, error) {
+	return f.tokens, nil
+}
+
+func (f *fakeDeviceTokens) Delete(ctx context.Context, token string) error {
+	for i, t := range f.tokens {
+		if t.Token == token {
+			f.tokens = append(f.tokens[:i], f.tokens[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestProcessEventFallsBackToEmailWithoutValidDevices(t *testing.T) {
+	invalid := retry.Permanent(fmt.Errorf("provider rejected token: %w", sender.ErrInvalidToken))
+	tests := []struct {
+		name      string
+		tokens    []string
+		errs      map[string]error
+		wantEmail bool
+	}{
+		{name: "no devices", wantEmail: true},
+		{name: "every token invalid", tokens: []string{"token-1", "token-2"}, errs: map[string]error{"token-1": invalid, "token-2": invalid}, wantEmail: true},
+		{name: "one token invalid", tokens: []string{"token-1", "token-2"}, errs: map[string]error{"token-1": invalid}},
+		{name: "valid token failed", tokens: []string{"token-1", "token-2"}, errs: map[string]error{"token-1": invalid, "token-2": retry.Permanent(errors.New("payload too large"))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &fakeEmailSender{}
+			devices := &fakeDeviceTokens{}
+			for _, token := range tt.tokens {
+				devices.tokens = append(devices.tokens, domain.DeviceToken{UserID: "user-1", Token: token, Platform: "ios"})
+			}
+			s := NewNotificationService(email, &fakeEmailLogs{},
+				WithPushSender(&fakePushSender{errs: tt.errs}, devices),
+				WithRouter(fixedRouter{domain.ChannelPush}),
+				WithNotificationLogRepository(&fakeNotificationLogs{}),
+			)
+
+			if err := s.ProcessEvent(context.Background(), purchase()); err != nil {
+				t.Fatal(err)
+			}
+			if sent := len(email.sent) == 1; sent != tt.wantEmail {
+				t.Errorf("email sent = %v, want %v", sent, tt.wantEmail)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"notification-service/internal/domain"
+	"notification-service/internal/sender"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// StatusPublisher tells other services about the outcome of notifications
+type StatusPublisher interface {
+	PublishStatus(ctx context.Context, status domain.NotificationStatus) error
+}
+
+// notificationNamespace derives notification IDs from what identifies a notification
+var notificationNamespace = uuid.MustParse("187f0f9d-240e-466c-a2c4-bf4e56d542b5")
+
+func WithStatusPublisher(publisher StatusPublisher) Option {
+	return func(s *notificationService) { s.statuses = publisher }
+}
+
+// notificationID is the same every time the notification of an event to
+// one recipient is reported, so consumers can deduplicate statuses. Every
+// refund of a transaction and every device of a push is a notification.
+func notificationID(entry domain.NotificationLog) string {
+	name := strings.Join([]string{entry.TransactionID, string(entry.EventType), entry.RefundID, string(entry.Channel), entry.Recipient}, "\x00")
+	return uuid.NewSHA1(notificationNamespace, []byte(name)).String()
+}
+
+// publishStatus reports the terminal outcome of a notification on one
+// channel. The publisher retries failed deliveries itself; an error here is
+// logged and the status dropped, so it never causes the notification to be
+// sent again.
+func (s *notificationService) publishStatus(ctx context.Context, entry domain.NotificationLog, attempt int, cause error) {
+	if s.statuses == nil {
+		return
+	}
+	status := domain.NotificationStatus{
+		NotificationID: notificationID(entry),
+		EventType:      entry.EventType,
+		TransactionID:  entry.TransactionID,
+		RefundID:       entry.RefundID,
+		Channel:        entry.Channel,
+		Status:         entry.Status,
+		Attempt:        attempt,
+		Timestamp:      time.Now(),
+	}
+	if entry.Status == domain.StatusFailed {
+		status.ErrorClass = errorClass(cause)
+	}
+	if err := s.statuses.PublishStatus(ctx, status); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"event_type":     entry.EventType,
+			"transaction_id": entry.TransactionID,
+			"channel":        entry.Channel,
+		}).Error("Failed to publish notification status")
+	}
+}
+
+// errorClass reduces a send error to a reason other services can act on
+// without seeing the error text, which may contain recipients
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, sender.ErrInvalidToken):
+		return "invalid_recipient"
+	default:
+		return "provider_error"
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"notification-service/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *notificationService) publishWebhook(eventType domain.EventType, transactionID, refundID string, data any) {
+	if s.webhooks == nil {
+		return
+	}
+	if err := s.webhooks.Publish(eventType, transactionID, refundID, data); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"event_type":     eventType,
+			"transaction_id": transactionID,
+			"refund_id":      refundID,
+		}).Error("Failed to queue webhook event")
+	}
+}
@@ -0,0 +1,186 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"notification-service/internal/cloudevents"
+	"notification-service/internal/domain"
+	"notification-service/internal/logwriter"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventType is the CloudEvents type of the published events
+const EventType = "notification_status"
+
+const (
+	retryBackoff    = time.Second
+	maxRetryBackoff = time.Minute
+)
+
+// KafkaPublisher produces notification status events as structured mode
+// CloudEvents, keyed by transaction ID so the events of a transaction stay
+// in order.
+//
+// Events are delivered in the background. The message being handled when
+// an event was published is only committed after the event was delivered.
+// A failed delivery is produced again with backoff until it succeeds, so a
+// broken status topic delays commits but never fails the message, which
+// would send its notifications again. The ID of an event is derived from
+// the notification and its status, so consumers can drop duplicates.
+type KafkaPublisher struct {
+	producer *kafka.Producer
+	topic    string
+	source   string
+	done     chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	retrying map[*pendingEvent]*time.Timer
+}
+
+// pendingEvent is an event awaiting its delivery report
+type pendingEvent struct {
+	message  *kafka.Message
+	complete func(error)
+	attempts int
+}
+
+// NewKafkaPublisher expects a producer with enable.idempotence set, so
+// librdkafka's own retries never duplicate an event
+func NewKafkaPublisher(producer *kafka.Producer, topic, source string) *KafkaPublisher {
+	p := &KafkaPublisher{
+		producer: producer,
+		topic:    topic,
+		source:   source,
+		done:     make(chan struct{}),
+		retrying: make(map[*pendingEvent]*time.Timer),
+	}
+	go p.reportDeliveries()
+	return p
+}
+
+func (p *KafkaPublisher) PublishStatus(ctx context.Context, status domain.NotificationStatus) error {
+	event, err := cloudevents.New(cloudevents.NewID(EventType, status.NotificationID, string(status.Status)), EventType, p.source, status)
+	if err != nil {
+		return err
+	}
+	event.Subject = status.TransactionID
+	event.Time = status.Timestamp.UTC()
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification status: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("failed to produce notification status: publisher is closed")
+	}
+
+	pending := &pendingEvent{
+		message: &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &p.topic, Partition: kafka.PartitionAny},
+			Key:            []byte(status.TransactionID),
+			Value:          value,
+			Headers:        []kafka.Header{{Key: "content-type", Value: []byte(cloudevents.ContentType)}},
+		},
+		complete: logwriter.Track(ctx),
+	}
+	pending.message.Opaque = pending
+	if err := p.produce(pending); err != nil {
+		p.retryLocked(pending, err)
+	}
+	return nil
+}
+
+// produce hands pending to the producer; p.mu must be held so the producer
+// is not closed meanwhile
+func (p *KafkaPublisher) produce(pending *pendingEvent) error {
+	pending.attempts++
+	if err := p.producer.Produce(pending.message, nil); err != nil {
+		return fmt.Errorf("failed to produce notification status: %w", err)
+	}
+	return nil
+}
+
+// retry produces pending again after a backoff
+func (p *KafkaPublisher) retry(pending *pendingEvent, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retryLocked(pending, err)
+}
+
+// retryLocked is retry with p.mu held. Once the publisher is closed the
+// event is given up and its message acknowledged, as sending the
+// notification again is worse than losing its status.
+func (p *KafkaPublisher) retryLocked(pending *pendingEvent, err error) {
+	logger := log.WithError(err).WithFields(log.Fields{"topic": p.topic, "attempt": pending.attempts})
+	if p.closed {
+		logger.Error("Giving up notification status event on shutdown")
+		pending.complete(nil)
+		return
+	}
+
+	delay := min(retryBackoff<<min(pending.attempts-1, 6), maxRetryBackoff)
+	logger.WithField("retry_in", delay).Warn("Failed to deliver notification status, retrying...")
+	p.retrying[pending] = time.AfterFunc(delay, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// Close produces it one last time itself
+		if _, ok := p.retrying[pending]; !ok {
+			return
+		}
+		delete(p.retrying, pending)
+		if err := p.produce(pending); err != nil {
+			p.retryLocked(pending, err)
+		}
+	})
+}
+
+func (p *KafkaPublisher) reportDeliveries() {
+	defer close(p.done)
+	for e := range p.producer.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			pending, ok := ev.Opaque.(*pendingEvent)
+			if !ok {
+				continue
+			}
+			if err := ev.TopicPartition.Error; err != nil {
+				p.retry(pending, err)
+				continue
+			}
+			pending.complete(nil)
+		case kafka.Error:
+			log.WithError(ev).Warn("Kafka producer error")
+		}
+	}
+}
+
+// Close produces the events awaiting a retry once more, waits up to timeout
+// for outstanding delivery reports and closes the producer. Events that
+// fail from then on are given up; the messages of events still undelivered
+// after timeout are never committed.
+func (p *KafkaPublisher) Close(timeout time.Duration) {
+	p.mu.Lock()
+	p.closed = true
+	for pending, timer := range p.retrying {
+		timer.Stop()
+		if err := p.produce(pending); err != nil {
+			p.retryLocked(pending, err)
+		}
+	}
+	clear(p.retrying)
+	p.mu.Unlock()
+
+	if remaining := p.producer.Flush(int(timeout.Milliseconds())); remaining > 0 {
+		log.WithField("events", remaining).Warn("Notification status events were not delivered before shutdown")
+	}
+	p.producer.Close()
+	<-p.done
+}
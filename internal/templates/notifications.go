@@ -0,0 +1,56 @@
+package templates
+
+// PurchaseData is the input of the purchase_confirmation template
+type PurchaseData struct {
+	ProductID      string
+	CoinsPurchased int
+	TransactionID  string
+}
+
+// RefundData is the input of the refund_processed template
+type RefundData struct {
+	AmountDollars float64
+	CoinsDeducted int64
+	TransactionID string
+	RefundID      string
+}
+
+func init() {
+	register(PurchaseConfirmation, DefaultLocale, 1,
+		"Покупка монет успешно завершена!",
+		"Здравствуйте!\n\n"+
+			"{{if .ProductID}}Вы успешно приобрели товар (ID: {{.ProductID}}).\nКоличество монет: {{.CoinsPurchased}}\n"+
+			"{{else}}Вы успешно приобрели {{.CoinsPurchased}} монет.\n{{end}}"+
+			"ID вашей транзакции: {{.TransactionID}}\n\nСпасибо за покупку!",
+		"Покупка {{.CoinsPurchased}} монет успешно завершена. ID транзакции: {{.TransactionID}}",
+	)
+
+	register(RefundProcessed, DefaultLocale, 1,
+		"Возврат средств обработан",
+		"Здравствуйте!\n\nВаш возврат средств был успешно обработан.\n\n"+
+			"Сумма возврата: ${{printf \"%.2f\" .AmountDollars}}\n"+
+			"Списано монет: {{.CoinsDeducted}}\n"+
+			"ID транзакции: {{.TransactionID}}\n"+
+			"ID возврата: {{.RefundID}}\n\n",
+		"Возврат ${{printf \"%.2f\" .AmountDollars}} обработан. Списано монет: {{.CoinsDeducted}}. ID возврата: {{.RefundID}}",
+	)
+
+	register(PurchaseConfirmation, "en", 1,
+		"Your coin purchase is complete!",
+		"Hello!\n\n"+
+			"{{if .ProductID}}You have purchased product {{.ProductID}}.\nCoins: {{.CoinsPurchased}}\n"+
+			"{{else}}You have purchased {{.CoinsPurchased}} coins.\n{{end}}"+
+			"Your transaction ID: {{.TransactionID}}\n\nThank you for your purchase!",
+		"Your purchase of {{.CoinsPurchased}} coins is complete. Transaction ID: {{.TransactionID}}",
+	)
+
+	register(RefundProcessed, "en", 1,
+		"Your refund has been processed",
+		"Hello!\n\nYour refund has been processed.\n\n"+
+			"Refund amount: ${{printf \"%.2f\" .AmountDollars}}\n"+
+			"Coins deducted: {{.CoinsDeducted}}\n"+
+			"Transaction ID: {{.TransactionID}}\n"+
+			"Refund ID: {{.RefundID}}\n\n",
+		"Refund of ${{printf \"%.2f\" .AmountDollars}} processed. Coins deducted: {{.CoinsDeducted}}. Refund ID: {{.RefundID}}",
+	)
+}
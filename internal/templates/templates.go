@@ -0,0 +1,131 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// DefaultLocale is the language every template is written in; a template
+// is rendered in it when the user's language is missing or unknown
+const DefaultLocale = "ru"
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+const (
+	PurchaseConfirmation = "purchase_confirmation"
+	RefundProcessed      = "refund_processed"
+)
+
+// Rendered is a notification ready to be sent. HTML is the text version in
+// the common email layout; Short is used for SMS and push.
+type Rendered struct {
+	Name    string
+	Version int
+	Locale  string
+	Subject string
+	Text    string
+	HTML    string
+	Short   string
+}
+
+// htmlLayout wraps the paragraphs of a text email; html/template escapes them
+var htmlLayout = htmltemplate.Must(htmltemplate.New("layout").Parse(`<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: Arial, sans-serif; font-size: 14px; color: #222;">
+{{range .Paragraphs}}<p>{{range $i, $line := .}}{{if $i}}<br>{{end}}{{$line}}{{end}}</p>
+{{end}}</body>
+</html>
+`))
+
+type definition struct {
+	version int
+	subject *template.Template
+	text    *template.Template
+	short   *template.Template
+}
+
+// registry holds the translations of every template by locale
+var registry = map[string]map[string]definition{}
+
+// register parses a translation of a template and panics on errors, so
+// broken templates fail at startup
+func register(name, locale string, version int, subject, text, short string) {
+	if registry[name] == nil {
+		registry[name] = make(map[string]definition)
+	}
+	registry[name][locale] = definition{
+		version: version,
+		subject: template.Must(template.New(name + ".subject").Parse(subject)),
+		text:    template.Must(template.New(name + ".text").Parse(text)),
+		short:   template.Must(template.New(name + ".short").Parse(short)),
+	}
+}
+
+// Render executes the named template in the language of locale, such as
+// "en" or "en-US", falling back to DefaultLocale
+func Render(name, locale string, data any) (Rendered, error) {
+	translations, ok := registry[name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	locale = language(locale)
+	tmpl, ok := translations[locale]
+	if !ok {
+		locale = DefaultLocale
+		tmpl = translations[locale]
+	}
+
+	r := Rendered{Name: name, Version: tmpl.version, Locale: locale}
+	var err error
+	if r.Subject, err = execute(tmpl.subject, data); err != nil {
+		return Rendered{}, err
+	}
+	if r.Text, err = execute(tmpl.text, data); err != nil {
+		return Rendered{}, err
+	}
+	if r.Short, err = execute(tmpl.short, data); err != nil {
+		return Rendered{}, err
+	}
+	if r.HTML, err = renderHTML(r); err != nil {
+		return Rendered{}, err
+	}
+	return r, nil
+}
+
+// language returns the primary language subtag of a locale, lower-cased
+func language(locale string) string {
+	locale, _, _ = strings.Cut(strings.TrimSpace(locale), "-")
+	locale, _, _ = strings.Cut(locale, "_")
+	return strings.ToLower(locale)
+}
+
+func renderHTML(r Rendered) (string, error) {
+	var paragraphs [][]string
+	for _, p := range strings.Split(strings.TrimSpace(r.Text), "\n\n") {
+		paragraphs = append(paragraphs, strings.Split(p, "\n"))
+	}
+
+	var buf bytes.Buffer
+	err := htmlLayout.Execute(&buf, struct {
+		Locale     string
+		Subject    string
+		Paragraphs [][]string
+	}{r.Locale, r.Subject, paragraphs})
+	if err != nil {
+		return "", fmt.Errorf("failed to render html layout: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func execute(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,45 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	data := PurchaseData{CoinsPurchased: 100, TransactionID: "tx-1"}
+	tests := []struct {
+		locale      string
+		wantLocale  string
+		wantSubject string
+	}{
+		{locale: "", wantLocale: "ru", wantSubject: "Покупка монет"},
+		{locale: "ru", wantLocale: "ru", wantSubject: "Покупка монет"},
+		{locale: "en", wantLocale: "en", wantSubject: "Your coin purchase"},
+		{locale: "en-US", wantLocale: "en", wantSubject: "Your coin purchase"},
+		{locale: " EN_gb", wantLocale: "en", wantSubject: "Your coin purchase"},
+		{locale: "kk", wantLocale: "ru", wantSubject: "Покупка монет"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			r, err := Render(PurchaseConfirmation, tt.locale, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Locale != tt.wantLocale || !strings.HasPrefix(r.Subject, tt.wantSubject) {
+				t.Errorf("Render(%q) = %s %q, want %s %q...", tt.locale, r.Locale, r.Subject, tt.wantLocale, tt.wantSubject)
+			}
+			if !strings.Contains(r.HTML, `lang="`+tt.wantLocale+`"`) {
+				t.Errorf("HTML is not marked as %s", tt.wantLocale)
+			}
+		})
+	}
+}
+
+func TestEveryTemplateHasDefaultLocale(t *testing.T) {
+	for name, translations := range registry {
+		if _, ok := translations[DefaultLocale]; !ok {
+			t.Errorf("template %s has no %s translation", name, DefaultLocale)
+		}
+	}
+}
@@ -17,10 +17,13 @@ var (
 	ErrInvalidUserID        = errors.New("user ID is invalid")
 	ErrInvalidCoins         = errors.New("coins purchased must be greater than 0")
 	ErrEmailTooLong         = errors.New("email is too long")
+	ErrInvalidPhoneFormat   = errors.New("phone number must be in E.164 format")
 )
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
+var phoneRegex = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
 const (
 	MaxEmailLength = 255
 )
@@ -38,6 +41,14 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
+// ValidatePhone checks that phone is an E.164 number such as +77011234567
+func ValidatePhone(phone string) error {
+	if !phoneRegex.MatchString(phone) {
+		return ErrInvalidPhoneFormat
+	}
+	return nil
+}
+
 func ValidateTransactionID(transactionID string) error {
 	transactionID = strings.TrimSpace(transactionID)
 	if transactionID == "" {
@@ -77,5 +88,19 @@ func ValidatePurchaseInfo(purchaseInfo domain.PurchaseInfo) error {
 	if err := ValidateCoins(purchaseInfo.CoinsPurchased); err != nil {
 		return err
 	}
+	if purchaseInfo.UserPhone != "" {
+		if err := ValidatePhone(purchaseInfo.UserPhone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateRefundInfo(refundInfo domain.RefundInfo) error {
+	if refundInfo.UserPhone != "" {
+		if err := ValidatePhone(refundInfo.UserPhone); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,363 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"notification-service/internal/cloudevents"
+	"notification-service/internal/domain"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SignatureHeader carries "t=<unix timestamp>,v1=<hex HMAC-SHA256>" where
+	// the HMAC is computed over "<timestamp>.<raw body>" with the endpoint secret.
+	// Receivers should reject requests whose timestamp is too old.
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Event-Id"
+)
+
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// Repository defines the interface for webhook endpoint and delivery data access
+type Repository interface {
+	ListActiveEndpoints(ctx context.Context, eventType domain.EventType) ([]domain.WebhookEndpoint, error)
+	RecordSuccess(ctx context.Context, endpointID string) error
+	RecordFailure(ctx context.Context, endpointID string, disableAfter int) (bool, error)
+	SaveDelivery(ctx context.Context, d domain.WebhookDelivery) error
+}
+
+type Config struct {
+	MaxAttempts    int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	RequestTimeout time.Duration
+	// DisableAfter is the number of consecutive failed deliveries after which an endpoint is disabled
+	DisableAfter int
+	Workers      int
+	QueueSize    int
+	// Source is the source attribute of the CloudEvents posted to subscribers
+	Source string
+}
+
+// Dispatcher delivers events to subscribed endpoints in the background.
+// Every (event, endpoint) delivery is queued on its own and waits for its
+// retries on a timer, so a slow or failing endpoint holds up no other.
+type Dispatcher struct {
+	repo       Repository
+	cfg        Config
+	client     *http.Client
+	queue      chan cloudevents.Event
+	deliveries chan *delivery
+	ctx        context.Context
+	cancel     context.CancelFunc
+	// fanout ends once the queued events are split into deliveries,
+	// workers once every delivery is finished
+	fanout  sync.WaitGroup
+	workers sync.WaitGroup
+	pending sync.WaitGroup
+	once    sync.Once
+	done    chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	retrying map[*delivery]*time.Timer
+}
+
+// delivery is one event on its way to one endpoint
+type delivery struct {
+	endpoint domain.WebhookEndpoint
+	event    cloudevents.Event
+	body     []byte
+	log      domain.WebhookDelivery
+	err      error
+}
+
+func NewDispatcher(repo Repository, cfg Config) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		repo:       repo,
+		cfg:        cfg,
+		client:     &http.Client{Timeout: cfg.RequestTimeout},
+		queue:      make(chan cloudevents.Event, cfg.QueueSize),
+		deliveries: make(chan *delivery, cfg.QueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		retrying:   make(map[*delivery]*time.Timer),
+	}
+	d.fanout.Add(1)
+	go d.fanOut()
+	for i := 0; i < cfg.Workers; i++ {
+		d.workers.Add(1)
+		go d.worker()
+	}
+	return d
+}
+
+// Publish queues an event for delivery as a structured mode CloudEvent
+// without blocking the caller. The event ID is derived from the event type,
+// transaction and refund, so an event published again keeps its ID.
+func (d *Dispatcher) Publish(eventType domain.EventType, transactionID, refundID string, data any) error {
+	id := cloudevents.NewID(string(eventType), transactionID, refundID)
+	event, err := cloudevents.New(id, string(eventType), d.cfg.Source, data)
+	if err != nil {
+		return err
+	}
+	event.Subject = transactionID
+
+	select {
+	case d.queue <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting events and makes one more attempt for every queued
+// delivery without waiting for retries. When ctx is done first, requests in
+// flight are aborted and the remaining deliveries are dropped.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.once.Do(func() {
+		close(d.queue)
+
+		d.mu.Lock()
+		d.closed = true
+		interrupted := make([]*delivery, 0, len(d.retrying))
+		for dl, timer := range d.retrying {
+			timer.Stop()
+			interrupted = append(interrupted, dl)
+		}
+		clear(d.retrying)
+		d.mu.Unlock()
+
+		go func() {
+			for _, dl := range interrupted {
+				d.finish(dl, true)
+			}
+			d.fanout.Wait()
+			d.pending.Wait()
+			close(d.deliveries)
+			d.workers.Wait()
+			close(d.done)
+		}()
+	})
+
+	select {
+	case <-d.done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return ctx.Err()
+	}
+}
+
+// fanOut splits every queued event into its deliveries
+func (d *Dispatcher) fanOut() {
+	defer d.fanout.Done()
+	dropped := 0
+	for event := range d.queue {
+		if d.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		d.split(event)
+	}
+	if dropped > 0 {
+		log.WithField("events", dropped).Warn("Dropped queued webhook events on shutdown")
+	}
+}
+
+// split queues a delivery of event to every subscribed endpoint
+func (d *Dispatcher) split(event cloudevents.Event) {
+	endpoints, err := d.repo.ListActiveEndpoints(d.ctx, domain.EventType(event.Type))
+	if err != nil {
+		log.WithError(err).WithField("event_id", event.ID).Error("Failed to load webhook endpoints")
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).WithField("event_id", event.ID).Error("Failed to encode webhook event")
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		d.pending.Add(1)
+		d.deliveries <- &delivery{
+			endpoint: endpoint,
+			event:    event,
+			body:     body,
+			log: domain.WebhookDelivery{
+				EndpointID:   endpoint.ID,
+				SubscriberID: endpoint.SubscriberID,
+				EventID:      event.ID,
+				EventType:    domain.EventType(event.Type),
+			},
+		}
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.workers.Done()
+	dropped := 0
+	for dl := range d.deliveries {
+		if d.ctx.Err() != nil && dl.log.Attempts == 0 {
+			dropped++
+			d.pending.Done()
+			continue
+		}
+		d.attempt(d.ctx, dl)
+	}
+	if dropped > 0 {
+		log.WithField("deliveries", dropped).Warn("Dropped queued webhook deliveries on shutdown")
+	}
+}
+
+// attempt posts the event once and either finishes the delivery or
+// schedules its next attempt
+func (d *Dispatcher) attempt(ctx context.Context, dl *delivery) {
+	dl.log.Attempts++
+	dl.log.ResponseStatus, dl.err = d.post(ctx, dl.endpoint, dl.event.ID, dl.body)
+	if dl.err == nil || dl.log.Attempts >= d.cfg.MaxAttempts || ctx.Err() != nil {
+		// Shutdown says nothing about the endpoint, so it does not count as its failure
+		d.finish(dl, ctx.Err() != nil)
+		return
+	}
+
+	if !d.retryLater(dl) {
+		d.finish(dl, true)
+	}
+}
+
+// retryLater queues dl again after a backoff; it returns false once the
+// dispatcher is closed
+func (d *Dispatcher) retryLater(dl *delivery) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+
+	delay := d.backoff(dl.log.Attempts)
+	log.WithFields(log.Fields{
+		"attempt":       dl.log.Attempts,
+		"max_attempts":  d.cfg.MaxAttempts,
+		"error":         dl.err,
+		"subscriber_id": dl.endpoint.SubscriberID,
+		"delay":         delay,
+	}).Warn("Failed to deliver webhook, retrying...")
+	d.retrying[dl] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		// Close finishes it itself
+		_, ok := d.retrying[dl]
+		delete(d.retrying, dl)
+		d.mu.Unlock()
+		if ok {
+			d.deliveries <- dl
+		}
+	})
+	return true
+}
+
+// finish records the outcome of a delivery
+func (d *Dispatcher) finish(dl *delivery, interrupted bool) {
+	defer d.pending.Done()
+	ctx := context.WithoutCancel(d.ctx)
+	endpoint := dl.endpoint
+	delivery := dl.log
+	err := dl.err
+
+	if err != nil && interrupted {
+		delivery.Status = domain.WebhookFailed
+		delivery.ErrorMessage = "delivery interrupted by shutdown: " + err.Error()
+		log.WithError(err).WithFields(log.Fields{
+			"subscriber_id": endpoint.SubscriberID,
+			"event_id":      dl.event.ID,
+		}).Warn("Webhook delivery interrupted by shutdown")
+	} else if err != nil {
+		delivery.Status = domain.WebhookFailed
+		delivery.ErrorMessage = err.Error()
+		log.WithError(err).WithFields(log.Fields{
+			"subscriber_id": endpoint.SubscriberID,
+			"event_id":      dl.event.ID,
+		}).Error("Failed to deliver webhook")
+
+		disabled, rerr := d.repo.RecordFailure(ctx, endpoint.ID, d.cfg.DisableAfter)
+		if rerr != nil {
+			log.WithError(rerr).Error("Failed to record webhook endpoint failure")
+		} else if disabled {
+			log.WithFields(log.Fields{
+				"subscriber_id": endpoint.SubscriberID,
+				"endpoint_id":   endpoint.ID,
+			}).Warn("Webhook endpoint disabled after repeated failures")
+		}
+	} else {
+		delivery.Status = domain.WebhookDelivered
+		if endpoint.ConsecutiveFailures > 0 {
+			if err := d.repo.RecordSuccess(ctx, endpoint.ID); err != nil {
+				log.WithError(err).Error("Failed to reset webhook endpoint failures")
+			}
+		}
+	}
+
+	if err := d.repo.SaveDelivery(ctx, delivery); err != nil {
+		log.WithError(err).Error("Failed to save webhook delivery log")
+	}
+}
+
+// post sends a signed request and returns the response status code
+func (d *Dispatcher) post(ctx context.Context, endpoint domain.WebhookEndpoint, eventID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", cloudevents.ContentType)
+	req.Header.Set(EventIDHeader, eventID)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call webhook endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns an exponential delay with full jitter for the given attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > d.cfg.MaxDelay {
+		delay = d.cfg.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// Sign builds the signature header value for body sent at ts
+func Sign(secret string, ts time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
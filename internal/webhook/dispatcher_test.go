@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"notification-service/internal/domain"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	endpoints []domain.WebhookEndpoint
+
+	mu         sync.Mutex
+	deliveries []domain.WebhookDelivery
+}
+
+func (f *fakeRepository) ListActiveEndpoints(ctx context.Context, eventType domain.EventType) ([]domain.WebhookEndpoint, error) {
+	return f.endpoints, nil
+}
+
+func (f *fakeRepository) RecordSuccess(ctx context.Context, endpointID string) error { return nil }
+
+func (f *fakeRepository) RecordFailure(ctx context.Context, endpointID string, disableAfter int) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeRepository) SaveDelivery(ctx context.Context, d domain.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveries = append(f.deliveries, d)
+	return nil
+}
+
+func (f *fakeRepository) delivered(endpointID string) []domain.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var deliveries []domain.WebhookDelivery
+	for _, d := range f.deliveries {
+		if d.EndpointID == endpointID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries
+}
+
+func TestDispatcherDeliversPastFailingEndpoint(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	var mu sync.Mutex
+	var ids []string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		ids = append(ids, event.ID)
+		mu.Unlock()
+	}))
+	defer healthy.Close()
+
+	repo := &fakeRepository{endpoints: []domain.WebhookEndpoint{
+		{ID: "failing", URL: failing.URL},
+		{ID: "healthy", URL: healthy.URL},
+	}}
+	// One worker retrying the failing endpoint for an hour must not hold up the other
+	d := NewDispatcher(repo, Config{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour, RequestTimeout: time.Second, Workers: 1, QueueSize: 10, Source: "test"})
+
+	for _, refundID := range []string{"refund-1", "refund-2", "refund-1"} {
+		if err := d.Publish(domain.EventRefund, "tx-1", refundID, map[string]string{"refund_id": refundID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(repo.delivered("healthy")) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.delivered("healthy"); len(got) != 3 {
+		t.Fatalf("delivered %d events to the healthy endpoint, want 3", len(got))
+	}
+	for _, dl := range repo.delivered("failing") {
+		if dl.Status != domain.WebhookFailed || dl.Attempts != 1 {
+			t.Errorf("failing endpoint delivery = %+v, want one failed attempt interrupted by Close", dl)
+		}
+	}
+
+	// The same refund published again keeps its ID
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ids) != 3 || ids[0] != ids[2] || ids[0] == ids[1] {
+		t.Errorf("event IDs = %v, want the first refund to keep its ID", ids)
+	}
+}
@@ -3,19 +3,35 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
 	"syscall"
 
+	"notification-service/internal/admin"
+	"notification-service/internal/alert"
+	"notification-service/internal/bounce"
 	"notification-service/internal/config"
 	"notification-service/internal/consumer"
+	"notification-service/internal/deliveryevent"
+	"notification-service/internal/domain"
+	"notification-service/internal/erasure"
+	"notification-service/internal/events"
 	"notification-service/internal/handler"
+	"notification-service/internal/health"
+	"notification-service/internal/logwriter"
+	"notification-service/internal/partition"
 	"notification-service/internal/repository"
+	"notification-service/internal/retention"
+	"notification-service/internal/retry"
+	"notification-service/internal/routing"
+	"notification-service/internal/schema"
 	"notification-service/internal/sender"
 	"notification-service/internal/service"
+	"notification-service/internal/status"
+	"notification-service/internal/webhook"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -23,7 +39,6 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 )
@@ -67,14 +82,21 @@ func main() {
 		migrationDBURL = dbURL + "?x-migrations-table=notification_schema_migrations"
 	}
 
-	m, err := migrate.New("file://db/migrations", migrationDBURL)
+	m, err := migrate.New(migrationsURL, migrationDBURL)
 	if err != nil {
 		log.WithError(err).Fatal("Could not create migration instance")
 	}
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.WithError(err).Fatal("Could not apply migration")
+	if len(os.Args) > 1 {
+		// Subcommands never migrate: a one-off purge must not start a long schema change
+		if err := checkSchema(m, migrationsURL); err != nil {
+			log.WithError(err).Fatal("Refusing to run command")
+		}
+	} else {
+		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+			log.WithError(err).Fatal("Could not apply migration")
+		}
+		log.Info("Database migration successfully applied")
 	}
-	log.Info("Database migration successfully applied")
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -95,7 +117,26 @@ func main() {
 	defer db.Close()
 	log.Info("Successfully connected to the PostgreSQL database")
 
-	emailRepository := repository.NewPostgresEmailRepository(db)
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, db, os.Args[1], os.Args[2:]); err != nil {
+			log.WithError(err).Fatal("Command failed")
+		}
+		return
+	}
+
+	var fieldCipher repository.FieldCipher
+	keyring, err := loadKeyring(context.Background(), cfg.Encryption, db)
+	if err != nil {
+		log.WithError(err).Fatal("Could not load encryption keys")
+	}
+	if keyring != nil {
+		fieldCipher = keyring
+	} else {
+		log.Warn("PII_MASTER_KEY is not set, recipients are stored in plaintext")
+	}
+
+	emailRepository := repository.NewPostgresEmailRepository(db, fieldCipher)
+	notificationLogRepository := repository.NewPostgresNotificationLogRepository(db, fieldCipher)
 
 	// 3. Create Email Sender
 	smtpHost := os.Getenv("SMTP_HOST")
@@ -108,50 +149,272 @@ func main() {
 		log.Fatal("SMTP environment variables are not set")
 	}
 
-	emailSender := sender.NewSMTPEmailSender(smtpHost, smtpPort, smtpUser, smtpPass, mailFrom)
+	smtpSender := sender.NewSMTPEmailSender(smtpHost, smtpPort, smtpUser, smtpPass, mailFrom)
+	var emailSender sender.EmailSender = smtpSender
 
-	// 4. Create Notification Service
-	notificationService := service.NewNotificationService(emailSender, emailRepository)
+	// The consumer probes the open circuit and pauses until SMTP is back
+	var smtpBreaker *health.Breaker
+	if cfg.FlowControl.Enabled {
+		smtpBreaker = health.NewBreaker("smtp", cfg.FlowControl.SMTPFailureThreshold, smtpSender.Ping)
+		emailSender = sender.WithCircuitBreaker(smtpSender, smtpBreaker)
+	}
 
-	// 5. Create Handler
-	purchaseHandler := handler.NewPurchaseHandler(notificationService)
-	refundHandler := handler.NewRefundHandler(notificationService)
+	serviceOptions := []service.Option{service.WithNotificationLogRepository(notificationLogRepository)}
 
-	// 6. Setup Kafka Consumer
-	kafkaServers := os.Getenv("KAFKA_BOOTSTRAP_SERVERS")
-	if kafkaServers == "" {
-		log.Fatal("KAFKA_BOOTSTRAP_SERVERS is not set")
+	switch cfg.SMS.Provider {
+	case "http":
+		if cfg.SMS.GatewayURL == "" {
+			log.Fatal("SMS_GATEWAY_URL is not set")
+		}
+		serviceOptions = append(serviceOptions, service.WithSMSSender(
+			sender.NewHTTPSMSSender(cfg.SMS.GatewayURL, cfg.SMS.APIKey, cfg.SMS.From, cfg.SMS.Timeout),
+		))
+	case "fake":
+		log.Warn("Using in-memory SMS sender, SMS will not be delivered")
+		serviceOptions = append(serviceOptions, service.WithSMSSender(sender.NewFakeSMSSender()))
+	case "":
+	default:
+		log.WithField("provider", cfg.SMS.Provider).Fatal("Unknown SMS_PROVIDER")
 	}
-	kafkaServers = strings.Trim(kafkaServers, "\"")
-	log.WithField("kafka_servers", kafkaServers).Info("Connecting to Kafka")
 
-	configMap := &kafka.ConfigMap{
-		"bootstrap.servers": kafkaServers,
-		"group.id":          "notification_service_group",
-		"auto.offset.reset": "earliest",
+	deviceTokenRepository := repository.NewPostgresDeviceTokenRepository(db)
+	switch cfg.Push.Provider {
+	case "http":
+		if cfg.Push.ProviderURL == "" {
+			log.Fatal("PUSH_PROVIDER_URL is not set")
+		}
+		serviceOptions = append(serviceOptions, service.WithPushSender(
+			sender.NewHTTPPushSender(cfg.Push.ProviderURL, cfg.Push.APIKey, cfg.Push.Timeout),
+			deviceTokenRepository,
+		))
+	case "fake":
+		log.Warn("Using in-memory push sender, push notifications will not be delivered")
+		serviceOptions = append(serviceOptions, service.WithPushSender(
+			sender.NewFakePushSender(),
+			deviceTokenRepository,
+		))
+	case "":
+	default:
+		log.WithField("provider", cfg.Push.Provider).Fatal("Unknown PUSH_PROVIDER")
 	}
-	log.WithField("config", fmt.Sprintf("%+v", configMap)).Debug("Kafka consumer config")
 
-	kafkaConsumer, err := kafka.NewConsumer(configMap)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to create Kafka consumer")
+	if cfg.Admin.Token == "" {
+		log.Fatal("ADMIN_TOKEN is not set, refusing to serve the admin API without authentication")
+	}
+	adminServer := admin.NewServer(cfg.Admin.Addr, cfg.Admin.Token)
+	adminServer.RegisterDevices(deviceTokenRepository)
+
+	var webhookDispatcher *webhook.Dispatcher
+	if cfg.Webhook.Enabled {
+		webhookRepository := repository.NewPostgresWebhookRepository(db)
+		webhookDispatcher = webhook.NewDispatcher(webhookRepository, webhook.Config{
+			MaxAttempts:    cfg.Webhook.MaxAttempts,
+			BaseDelay:      cfg.Webhook.BaseDelay,
+			MaxDelay:       cfg.Webhook.MaxDelay,
+			RequestTimeout: cfg.Webhook.RequestTimeout,
+			DisableAfter:   cfg.Webhook.DisableAfter,
+			Workers:        cfg.Webhook.Workers,
+			QueueSize:      cfg.Webhook.QueueSize,
+			Source:         cfg.Events.Source,
+		})
+		serviceOptions = append(serviceOptions, service.WithWebhookPublisher(webhookDispatcher))
+		adminServer.RegisterWebhookDeliveries(webhookRepository)
+		adminServer.RegisterWebhookEndpoints(webhookRepository)
+	}
+
+	preferenceRepository := repository.NewPostgresPreferenceRepository(db)
+	mandatoryEvents := make([]domain.EventType, 0, len(cfg.Preferences.MandatoryEvents))
+	for _, eventType := range cfg.Preferences.MandatoryEvents {
+		mandatoryEvents = append(mandatoryEvents, domain.EventType(strings.TrimSpace(eventType)))
 	}
+	serviceOptions = append(serviceOptions, service.WithPreferences(preferenceRepository, mandatoryEvents))
+	adminServer.RegisterPreferences(preferenceRepository)
 
-	topic := "successful_payments"
-	kafkaConsumerWrapper, err := consumer.NewKafkaConsumer(kafkaConsumer, topic, purchaseHandler)
+	suppressionRepository := repository.NewPostgresSuppressionRepository(db)
+	serviceOptions = append(serviceOptions, service.WithSuppressionList(suppressionRepository))
+	adminServer.RegisterSuppressions(suppressionRepository)
+
+	var deliveryEventProviders []deliveryevent.Provider
+	if cfg.DeliveryEvents.MailgunSigningKey != "" {
+		deliveryEventProviders = append(deliveryEventProviders, deliveryevent.NewMailgunProvider(cfg.DeliveryEvents.MailgunSigningKey, cfg.DeliveryEvents.MaxAge))
+	}
+	if cfg.DeliveryEvents.GenericSecret != "" {
+		deliveryEventProviders = append(deliveryEventProviders, deliveryevent.NewGenericProvider(cfg.DeliveryEvents.GenericSecret, cfg.DeliveryEvents.MaxAge))
+	}
+	if len(deliveryEventProviders) > 0 {
+		receiver := deliveryevent.NewReceiver(deliveryEventProviders, repository.NewPostgresDeliveryEventRepository(db), suppressionRepository)
+		adminServer.HandlePublic("POST /webhooks/delivery-events/{provider}", receiver)
+	}
+
+	if cfg.Erasure.PseudonymKey == "" {
+		log.Fatal("ERASURE_PSEUDONYM_KEY is not set, erased recipients cannot be pseudonymized")
+	}
+	erasureRepository := repository.NewPostgresErasureRepository(db, fieldCipher)
+	eraser := erasure.NewEraser(erasureRepository, cfg.Erasure.PseudonymKey)
+	adminServer.RegisterErasures(eraser, erasureRepository)
+
+	var bodyRepository retention.BodyRepository
+	if cfg.Audit.StoreBodies {
+		bodies := repository.NewPostgresBodyRepository(db, cfg.Audit.BodyRetention)
+		serviceOptions = append(serviceOptions, service.WithBodyStore(bodies))
+		adminServer.RegisterEmailBodies(bodies)
+		bodyRepository = bodies
+	}
+
+	var alertEvaluator *alert.Evaluator
+	if cfg.Alert.WebhookURL != "" {
+		var notifier alert.Notifier
+		switch cfg.Alert.Format {
+		case "slack":
+			notifier = alert.NewSlackNotifier(cfg.Alert.WebhookURL, cfg.Alert.Timeout)
+		case "json":
+			notifier = alert.NewWebhookNotifier(cfg.Alert.WebhookURL, cfg.Alert.Timeout)
+		default:
+			log.WithField("format", cfg.Alert.Format).Fatal("Unknown ALERT_WEBHOOK_FORMAT")
+		}
+
+		var rules []alert.Rule
+		if cfg.Alert.HighValueCoins > 0 {
+			rules = append(rules, &alert.HighValuePurchaseRule{
+				Threshold:    cfg.Alert.HighValueCoins,
+				CooldownTime: cfg.Alert.HighValueCooldown,
+			})
+		}
+		if cfg.Alert.RefundSpikeThreshold > 0 {
+			rules = append(rules, &alert.RefundSpikeRule{
+				Threshold:    cfg.Alert.RefundSpikeThreshold,
+				Window:       cfg.Alert.RefundSpikeWindow,
+				CooldownTime: cfg.Alert.RefundSpikeCooldown,
+			})
+		}
+		alertEvaluator = alert.NewEvaluator(rules, []alert.Notifier{notifier})
+		serviceOptions = append(serviceOptions, service.WithAlertEvaluator(alertEvaluator))
+	}
+
+	routingRules, err := routing.ParseRules(cfg.Routing.Rules)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create Kafka consumer wrapper")
+		log.WithError(err).Fatal("Could not parse NOTIFICATION_ROUTING_RULES")
 	}
+	serviceOptions = append(serviceOptions, service.WithRouter(routing.NewRouter(routingRules)))
+
+	retryPolicies, err := retry.ParsePolicies(cfg.Retry.Policies, retry.Policy{
+		MaxAttempts:    cfg.Retry.MaxAttempts,
+		BaseDelay:      cfg.Retry.BaseDelay,
+		MaxDelay:       cfg.Retry.MaxDelay,
+		AttemptTimeout: cfg.Retry.AttemptTimeout,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("Could not parse RETRY_POLICIES")
+	}
+	serviceOptions = append(serviceOptions, service.WithRetryPolicies(retryPolicies))
 
-	refundTopic := "refund_events"
-	refundConsumer, err := kafka.NewConsumer(configMap)
+	var statusPublisher *status.KafkaPublisher
+	if cfg.StatusEvents.Topic != "" {
+		statusPublisher, err = newStatusPublisher(cfg.StatusEvents.Topic, cfg.Events.Source)
+		if err != nil {
+			log.WithError(err).Fatal("Could not create notification status publisher")
+		}
+		serviceOptions = append(serviceOptions, service.WithStatusPublisher(statusPublisher))
+	}
+
+	var emailLogs service.EmailRepository = emailRepository
+	var logWriter *logwriter.Writer
+	if cfg.EmailLogWriter.Batched {
+		logWriter = logwriter.NewWriter(emailRepository, logwriter.Config{
+			BatchSize:     cfg.EmailLogWriter.BatchSize,
+			FlushInterval: cfg.EmailLogWriter.FlushInterval,
+			BufferSize:    cfg.EmailLogWriter.BufferSize,
+			RetryDelay:    cfg.EmailLogWriter.RetryDelay,
+		})
+		emailLogs = logWriter
+	}
+
+	// 4. Create Notification Service
+	notificationService := service.NewNotificationService(emailSender, emailLogs, serviceOptions...)
+
+	// 5. Register event types; each is routed to a generic handler
+	var registryOptions []events.Option
+	if cfg.Events.DisallowUnknownFields {
+		registryOptions = append(registryOptions, events.DisallowUnknownFields())
+	}
+	eventRegistry := events.NewRegistry(registryOptions...)
+	events.RegisterBuiltin(eventRegistry)
+
+	// Avro and Protobuf payloads are decoded against the schema registry
+	var payloadDecoder handler.PayloadDecoder
+	switch {
+	case cfg.SchemaRegistry.URL != "":
+		decoder := schema.NewDecoder(schema.NewHTTPRegistry(cfg.SchemaRegistry.URL, cfg.SchemaRegistry.Username, cfg.SchemaRegistry.Password, cfg.SchemaRegistry.Timeout))
+		adminServer.RegisterPayloadStats(decoder)
+		payloadDecoder = decoder
+	case cfg.SchemaRegistry.Dir != "":
+		decoder := schema.NewDecoder(schema.NewDirRegistry(cfg.SchemaRegistry.Dir))
+		adminServer.RegisterPayloadStats(decoder)
+		payloadDecoder = decoder
+	}
+	userDeletedHandler := handler.NewUserDeletedHandler(eraser)
+
+	// 6. Setup the message source
+	source, err := newMessageSource(cfg.Source)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create refund Kafka consumer")
+		log.WithError(err).Fatal("Failed to create message source")
+	}
+
+	var deadLetters consumer.DeadLetterSink
+	if cfg.Events.DeadLetters {
+		deadLetterRepository := repository.NewPostgresDeadLetterRepository(db, fieldCipher)
+		adminServer.RegisterDeadLetters(deadLetterRepository)
+		deadLetters = deadLetterRepository
 	}
 
-	refundConsumerWrapper, err := consumer.NewKafkaConsumer(refundConsumer, refundTopic, refundHandler)
+	// One consumer reads every topic. CloudEvents are dispatched on their type
+	// attribute, plain payloads on the topic they arrived on.
+	dispatcher := handler.NewDispatcher()
+	routes := make([]consumer.Route, 0, len(eventRegistry.Definitions())+1+len(cfg.Events.CloudEventTopics))
+	for _, definition := range eventRegistry.Definitions() {
+		dispatcher.Handle(definition.CloudEventType, definition.Topic, handler.NewEventHandler(definition, notificationService, payloadDecoder))
+		routes = append(routes, consumer.Route{
+			Topic:   definition.Topic,
+			Handler: dispatcher,
+			Policy:  consumer.ErrorPolicy{MaxAttempts: 1, Action: consumer.Skip, DeadLetters: deadLetters},
+		})
+	}
+	// An erasure must not be lost: a failing one holds its partition, or is
+	// redelivered by sources that can redeliver single messages
+	erasurePolicy := consumer.ErrorPolicy{MaxAttempts: 5, Backoff: time.Second, Action: consumer.Hold, DeadLetters: deadLetters}
+	dispatcher.Handle("user_deleted", "user_deleted", userDeletedHandler)
+	routes = append(routes, consumer.Route{
+		Topic:   "user_deleted",
+		Handler: dispatcher,
+		Policy:  erasurePolicy,
+	})
+	// Shared topics may carry erasures too, so they get the stricter policy
+	for _, topic := range cfg.Events.CloudEventTopics {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			routes = append(routes, consumer.Route{Topic: topic, Handler: dispatcher, Policy: erasurePolicy})
+		}
+	}
+
+	kafkaConsumerWrapper, err := consumer.NewKafkaConsumer(source, routes...)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create refund Kafka consumer wrapper")
+		log.WithError(err).Fatal("Failed to create Kafka consumer wrapper")
+	}
+	adminServer.RegisterConsumerStats(kafkaConsumerWrapper)
+	adminServer.RegisterConsumerFlow(kafkaConsumerWrapper)
+
+	if cfg.FlowControl.Enabled {
+		kafkaConsumerWrapper.PauseWhenUnhealthy(cfg.FlowControl.CheckInterval,
+			consumer.HealthCheck{Name: "smtp", Check: smtpBreaker.Check},
+			consumer.HealthCheck{Name: "postgres", Check: func(ctx context.Context) error {
+				// Batched email logs keep being retried while the database is down
+				if logWriter != nil {
+					if err := logWriter.Err(); err != nil {
+						return err
+					}
+				}
+				return db.PingContext(ctx)
+			}},
+		)
 	}
 
 	// 7. Graceful shutdown setup
@@ -161,7 +424,7 @@ func main() {
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 
-	// 8. Start consumer in goroutine
+	// 8. Start consumer in goroutines
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -171,11 +434,54 @@ func main() {
 		}
 	}()
 
-	wg.Add(1)
+	if cfg.Bounce.Maildir != "" {
+		bounceProcessor := bounce.NewProcessor(bounce.NewMaildir(cfg.Bounce.Maildir), emailRepository, suppressionRepository, cfg.Bounce.PollInterval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bounceProcessor.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.WithError(err).Error("Bounce processor stopped with error")
+			}
+		}()
+	}
+
+	if cfg.Retention.Enabled || (bodyRepository != nil && cfg.Audit.BodyRetention > 0) {
+		retentionCfg := cfg.Retention
+		if !cfg.Retention.Enabled {
+			// Only expire stored email bodies, keep every email log
+			retentionCfg.TTLs = ""
+		}
+		retentionWorker, err := newRetentionWorker(retentionCfg, db, bodyRepository)
+		if err != nil {
+			log.WithError(err).Fatal("Could not create retention worker")
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := retentionWorker.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.WithError(err).Error("Retention worker stopped with error")
+			}
+		}()
+	}
+
+	if cfg.Partitions.Enabled {
+		partitionMaintainer := partition.NewMaintainer(repository.NewPostgresPartitionRepository(db), partition.Config{
+			Interval:    cfg.Partitions.Interval,
+			MonthsAhead: cfg.Partitions.MonthsAhead,
+			Retention:   cfg.Partitions.Retention,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := partitionMaintainer.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.WithError(err).Error("Partition maintainer stopped with error")
+			}
+		}()
+	}
+
 	go func() {
-		defer wg.Done()
-		if err := refundConsumerWrapper.Start(ctx); err != nil {
-			log.WithError(err).Error("Refund Kafka consumer stopped with error")
+		if err := adminServer.Start(); err != nil {
+			log.WithError(err).Error("Admin server stopped with error")
 		}
 	}()
 
@@ -201,13 +507,39 @@ func main() {
 		log.Warn("Shutdown timeout exceeded, forcing exit")
 	}
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := adminServer.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("Error shutting down admin server")
+	}
+
+	// Flush queued email logs before the consumers commit their final offsets
+	if logWriter != nil {
+		if err := logWriter.Close(shutdownCtx); err != nil {
+			log.WithError(err).Error("Error flushing email logs")
+		}
+	}
+
+	// Wait for the delivery reports of status events the final offsets depend on
+	if statusPublisher != nil {
+		statusPublisher.Close(cfg.StatusEvents.FlushTimeout)
+	}
+
 	// Close resources explicitly
 	if err := kafkaConsumerWrapper.Close(); err != nil {
 		log.WithError(err).Error("Error closing Kafka consumer")
 	}
 
-	if err := refundConsumerWrapper.Close(); err != nil {
-		log.WithError(err).Error("Error closing refund Kafka consumer")
+	if alertEvaluator != nil {
+		if err := alertEvaluator.Close(shutdownCtx); err != nil {
+			log.WithError(err).Error("Error sending queued alerts")
+		}
+	}
+
+	if webhookDispatcher != nil {
+		if err := webhookDispatcher.Close(shutdownCtx); err != nil {
+			log.WithError(err).Error("Error delivering queued webhooks")
+		}
 	}
 
 	if err := db.Close(); err != nil {
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"notification-service/internal/config"
+	"notification-service/internal/consumer"
+	"notification-service/internal/status"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	log "github.com/sirupsen/logrus"
+)
+
+// newMessageSource connects to the broker selected by MESSAGE_SOURCE
+func newMessageSource(cfg config.Source) (consumer.MessageSource, error) {
+	switch cfg.Kind {
+	case "kafka":
+		return newKafkaSource()
+	case "jetstream":
+		log.WithFields(log.Fields{"url": cfg.NATSURL, "stream": cfg.NATSStream}).Info("Connecting to NATS JetStream")
+		return consumer.NewJetStreamSource(consumer.JetStreamConfig{
+			URL:        cfg.NATSURL,
+			Stream:     cfg.NATSStream,
+			Durable:    cfg.NATSDurable,
+			AckWait:    cfg.NATSAckWait,
+			MaxDeliver: cfg.MaxDeliver,
+			Batch:      cfg.Batch,
+		})
+	case "redis":
+		log.WithField("addr", cfg.RedisAddr).Info("Connecting to Redis streams")
+		return consumer.NewRedisSource(consumer.RedisConfig{
+			Addr:       cfg.RedisAddr,
+			Password:   cfg.RedisPassword,
+			DB:         cfg.RedisDB,
+			TLS:        cfg.RedisTLS,
+			Group:      cfg.RedisGroup,
+			Consumer:   cfg.RedisConsumer,
+			ClaimIdle:  cfg.RedisClaimIdle,
+			MaxDeliver: cfg.MaxDeliver,
+			Batch:      cfg.Batch,
+		})
+	default:
+		return nil, fmt.Errorf("unknown MESSAGE_SOURCE %q", cfg.Kind)
+	}
+}
+
+func kafkaBootstrapServers() (string, error) {
+	kafkaServers := os.Getenv("KAFKA_BOOTSTRAP_SERVERS")
+	if kafkaServers == "" {
+		return "", fmt.Errorf("KAFKA_BOOTSTRAP_SERVERS is not set")
+	}
+	return strings.Trim(kafkaServers, "\""), nil
+}
+
+func newKafkaSource() (consumer.MessageSource, error) {
+	kafkaServers, err := kafkaBootstrapServers()
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("kafka_servers", kafkaServers).Info("Connecting to Kafka")
+
+	configMap := &kafka.ConfigMap{
+		"bootstrap.servers": kafkaServers,
+		"group.id":          "notification_service_group",
+		"auto.offset.reset": "earliest",
+		// Offsets are stored by the consumer once the email logs of a message are durable
+		"enable.auto.offset.store": false,
+	}
+	log.WithField("config", fmt.Sprintf("%+v", configMap)).Debug("Kafka consumer config")
+
+	kafkaConsumer, err := kafka.NewConsumer(configMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	return consumer.NewKafkaSource(kafkaConsumer), nil
+}
+
+// newStatusPublisher produces status events through an idempotent producer,
+// so retries after a lost acknowledgement never duplicate an event
+func newStatusPublisher(topic, source string) (*status.KafkaPublisher, error) {
+	kafkaServers, err := kafkaBootstrapServers()
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  kafkaServers,
+		"enable.idempotence": true,
+		"acks":               "all",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+	return status.NewKafkaPublisher(producer, topic, source), nil
+}